@@ -5,18 +5,24 @@ import (
 	"os"
 	"strconv"
 	"strings"
+	"time"
 
 	passport "github.com/leeprovoost/go-rest-api-template/internal/passport"
+	"github.com/leeprovoost/go-rest-api-template/pkg/blob"
 	vparse "github.com/leeprovoost/go-rest-api-template/pkg/version"
 )
 
 func main() {
 	env := strings.ToUpper(os.Getenv("ENV"))
 	port := os.Getenv("PORT")
+	grpcPort := os.Getenv("GRPC_PORT")
 	versionPath := os.Getenv("VERSION")
 	corsOrigins := os.Getenv("CORS_ORIGINS")
 	rateLimit, _ := strconv.ParseFloat(os.Getenv("RATE_LIMIT"), 64)
 	rateBurst, _ := strconv.Atoi(os.Getenv("RATE_BURST"))
+	idempotencyTTL, _ := time.ParseDuration(os.Getenv("IDEMPOTENCY_TTL"))
+	attachmentDir := os.Getenv("ATTACHMENT_DIR")
+	maxAttachment, _ := strconv.ParseInt(os.Getenv("MAX_ATTACHMENT_SIZE"), 10, 64)
 
 	// Configure structured logging
 	var logger *slog.Logger
@@ -46,15 +52,29 @@ func main() {
 	// Initialise data storage
 	userStore := passport.NewUserService(passport.CreateMockDataSet())
 	passportStore := passport.NewPassportService(passport.CreateMockPassportDataSet())
+	visaStore := passport.NewVisaService(passport.CreateMockVisaDataSet())
+	var blobs blob.Store
+	if attachmentDir != "" {
+		fs, err := blob.NewFS(attachmentDir)
+		if err != nil {
+			logger.Error("can't use attachment directory", "path", attachmentDir, "error", err)
+			os.Exit(1)
+		}
+		blobs = fs
+	}
 
 	// Create and run server
-	srv := passport.NewServer(userStore, passportStore, logger, passport.ServerOptions{
-		Version:     version,
-		Env:         env,
-		Port:        port,
-		CORSOrigins: corsOrigins,
-		RateLimit:   rateLimit,
-		RateBurst:   rateBurst,
+	srv := passport.NewServer(userStore, passportStore, visaStore, logger, passport.ServerOptions{
+		Version:        version,
+		Env:            env,
+		Port:           port,
+		GRPCPort:       grpcPort,
+		CORSOrigins:    corsOrigins,
+		RateLimit:      rateLimit,
+		RateBurst:      rateBurst,
+		IdempotencyTTL: idempotencyTTL,
+		Blobs:          blobs,
+		MaxAttachment:  maxAttachment,
 	})
 	if err := srv.Run(); err != nil {
 		logger.Error("server error", "error", err)
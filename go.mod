@@ -3,12 +3,19 @@ module github.com/leeprovoost/go-rest-api-template
 go 1.23.0
 
 require (
+	github.com/graphql-go/graphql v0.8.1
 	github.com/stretchr/testify v1.9.0
+	golang.org/x/text v0.19.0
 	golang.org/x/time v0.9.0
+	google.golang.org/genproto/googleapis/rpc v0.0.0-20241015192408-796eee8c2d53
+	google.golang.org/grpc v1.69.4
+	google.golang.org/protobuf v1.35.1
 )
 
 require (
 	github.com/davecgh/go-spew v1.1.1 // indirect
 	github.com/pmezard/go-difflib v1.0.0 // indirect
+	golang.org/x/net v0.30.0 // indirect
+	golang.org/x/sys v0.26.0 // indirect
 	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
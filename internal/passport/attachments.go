@@ -0,0 +1,229 @@
+package passport
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/leeprovoost/go-rest-api-template/pkg/blob"
+	"github.com/leeprovoost/go-rest-api-template/pkg/status"
+	"github.com/leeprovoost/go-rest-api-template/pkg/validate"
+)
+
+// defaultMaxAttachmentBytes is the largest attachment accepted unless
+// ServerOptions.MaxAttachment says otherwise.
+const defaultMaxAttachmentBytes = 10 << 20
+
+// attachmentTypes are the content types attachments may have, as sniffed
+// from their first bytes: scans of the photo page and the documents they
+// come in.
+var attachmentTypes = map[string]bool{
+	"image/jpeg":      true,
+	"image/png":       true,
+	"application/pdf": true,
+}
+
+// errAttachmentTooLarge is returned by limitReader once its limit is
+// exceeded.
+var errAttachmentTooLarge = errors.New("attachment too large")
+
+// limitReader reads from r but fails with errAttachmentTooLarge after more
+// than n bytes, so that an upload can be streamed to the blob store without
+// knowing its size first.
+type limitReader struct {
+	r io.Reader
+	n int64
+}
+
+func (l *limitReader) Read(p []byte) (int, error) {
+	n, err := l.r.Read(p)
+	l.n -= int64(n)
+	if l.n < 0 {
+		return n, errAttachmentTooLarge
+	}
+	return n, err
+}
+
+// attachmentResponse describes an attachment. The contents are downloaded
+// from GET /passports/{id}/attachments/{aid}.
+type attachmentResponse struct {
+	ID          string    `json:"id"`
+	PassportID  string    `json:"passportId"`
+	Filename    string    `json:"filename"`
+	ContentType string    `json:"contentType"`
+	Size        int64     `json:"size"`
+	SHA256      string    `json:"sha256"`
+	CreatedAt   time.Time `json:"createdAt"`
+}
+
+// attachmentKey is the blob key of an attachment of a passport.
+func attachmentKey(passportID, id string) string {
+	return "passports/" + passportID + "/attachments/" + id
+}
+
+func newAttachmentResponse(info blob.Info) attachmentResponse {
+	return attachmentResponse{
+		ID:          path.Base(info.Key),
+		PassportID:  info.Metadata["passportId"],
+		Filename:    info.Metadata["filename"],
+		ContentType: info.ContentType,
+		Size:        info.Size,
+		SHA256:      info.SHA256,
+		CreatedAt:   info.ModTime,
+	}
+}
+
+// handleUploadAttachment stores the file in the "file" part of a
+// multipart/form-data request. Its type is sniffed from its content rather
+// than taken from the client, and it is streamed to the blob store, which
+// computes its size and checksum.
+func (s *Server) handleUploadAttachment(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if mt, _, err := mime.ParseMediaType(r.Header.Get("Content-Type")); err != nil || mt != "multipart/form-data" {
+		respondError(w, status.CodeUnsupportedMediaType, "Content-Type must be multipart/form-data")
+		return
+	}
+	if _, err := s.passportStore.GetPassport(r.Context(), id); err != nil {
+		respondError(w, status.CodePassportNotFound, "can't find passport")
+		return
+	}
+	// Leave room for the multipart headers and boundaries around the file.
+	r.Body = http.MaxBytesReader(w, r.Body, s.maxAttachmentBytes+64<<10)
+	mr, err := r.MultipartReader()
+	if err != nil {
+		respondError(w, status.CodeMalformedRequest, "can't read multipart body: "+err.Error())
+		return
+	}
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			respondValidationErrors(w, validate.Errors{{Pointer: "/file", Rule: "required", Message: "file is required"}})
+			return
+		}
+		if err != nil {
+			s.respondUploadError(w, err)
+			return
+		}
+		if part.FormName() == "file" {
+			s.storeAttachment(w, r, id, part.FileName(), part)
+			return
+		}
+	}
+}
+
+// storeAttachment sniffs the type of the file in body and stores it.
+func (s *Server) storeAttachment(w http.ResponseWriter, r *http.Request, passportID, filename string, body io.Reader) {
+	head := make([]byte, 512)
+	n, err := io.ReadFull(body, head)
+	if err != nil && err != io.ErrUnexpectedEOF {
+		if err == io.EOF {
+			respondValidationErrors(w, validate.Errors{{Pointer: "/file", Rule: "required", Message: "file must not be empty"}})
+			return
+		}
+		s.respondUploadError(w, err)
+		return
+	}
+	head = head[:n]
+	contentType, _, _ := mime.ParseMediaType(http.DetectContentType(head))
+	if !attachmentTypes[contentType] {
+		respondError(w, status.CodeAttachmentTypeUnsupported, "attachments must be JPEG, PNG or PDF, not "+contentType)
+		return
+	}
+
+	filename = path.Base(strings.ReplaceAll(filename, `\`, "/"))
+	if filename == "." || filename == "/" {
+		filename = "attachment"
+	}
+	id := generateID()
+	info, err := s.blobs.Put(r.Context(), attachmentKey(passportID, id),
+		&limitReader{r: io.MultiReader(bytes.NewReader(head), body), n: s.maxAttachmentBytes},
+		contentType,
+		map[string]string{"passportId": passportID, "filename": filename},
+	)
+	if err != nil {
+		s.respondUploadError(w, err)
+		return
+	}
+	s.logger.Info("attachment stored", "passportId", passportID, "id", id, "size", info.Size, "sha256", info.SHA256)
+	respond(w, http.StatusCreated, newAttachmentResponse(info))
+}
+
+// respondUploadError responds to a failure to read or store an upload.
+func (s *Server) respondUploadError(w http.ResponseWriter, err error) {
+	var tooLarge *http.MaxBytesError
+	if errors.Is(err, errAttachmentTooLarge) || errors.As(err, &tooLarge) {
+		respondError(w, status.CodeRequestTooLarge, fmt.Sprintf("attachment must not be larger than %d bytes", s.maxAttachmentBytes))
+		return
+	}
+	s.logger.Error("failed to store attachment", "error", err)
+	respondError(w, status.CodeInternal, "failed to store attachment")
+}
+
+func (s *Server) handleListAttachments(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if _, err := s.passportStore.GetPassport(r.Context(), id); err != nil {
+		respondError(w, status.CodePassportNotFound, "can't find passport")
+		return
+	}
+	infos, err := s.blobs.List(r.Context(), attachmentKey(id, ""))
+	if err != nil {
+		s.logger.Error("failed to list attachments", "passportId", id, "error", err)
+		respondError(w, status.CodeInternal, "failed to list attachments")
+		return
+	}
+	attachments := make([]attachmentResponse, len(infos))
+	for i, info := range infos {
+		attachments[i] = newAttachmentResponse(info)
+	}
+	respond(w, http.StatusOK, map[string]any{
+		"attachments": attachments,
+		"count":       len(attachments),
+	})
+}
+
+// handleDownloadAttachment streams the contents of an attachment. Range and
+// conditional requests are handled by http.ServeContent; the ETag and
+// Repr-Digest headers carry the SHA-256 checksum of the whole file.
+func (s *Server) handleDownloadAttachment(w http.ResponseWriter, r *http.Request) {
+	rc, info, err := s.blobs.Open(r.Context(), attachmentKey(r.PathValue("id"), r.PathValue("aid")))
+	if errors.Is(err, blob.ErrNotFound) {
+		respondError(w, status.CodeAttachmentNotFound, "can't find attachment")
+		return
+	}
+	if err != nil {
+		s.logger.Error("failed to open attachment", "error", err)
+		respondError(w, status.CodeInternal, "failed to open attachment")
+		return
+	}
+	defer rc.Close()
+
+	w.Header().Set("Content-Type", info.ContentType)
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": info.Metadata["filename"]}))
+	w.Header().Set("ETag", `"`+info.SHA256+`"`)
+	if sum, err := hex.DecodeString(info.SHA256); err == nil {
+		w.Header().Set("Repr-Digest", "sha-256=:"+base64.StdEncoding.EncodeToString(sum)+":")
+	}
+	http.ServeContent(w, r, "", info.ModTime, rc)
+}
+
+func (s *Server) handleDeleteAttachment(w http.ResponseWriter, r *http.Request) {
+	err := s.blobs.Delete(r.Context(), attachmentKey(r.PathValue("id"), r.PathValue("aid")))
+	if errors.Is(err, blob.ErrNotFound) {
+		respondError(w, status.CodeAttachmentNotFound, "can't find attachment")
+		return
+	}
+	if err != nil {
+		s.logger.Error("failed to delete attachment", "error", err)
+		respondError(w, status.CodeInternal, "something went wrong")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,124 @@
+package passport
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"log/slog"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/leeprovoost/go-rest-api-template/pkg/status"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// photoPage is the start of a PNG file, enough for content sniffing.
+var photoPage = append([]byte("\x89PNG\r\n\x1a\n"), bytes.Repeat([]byte{0xAB}, 1000)...)
+
+// upload posts content as the part named field of a multipart form.
+func upload(t *testing.T, handler http.Handler, target, field, filename string, content []byte) *httptest.ResponseRecorder {
+	t.Helper()
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	fw, err := mw.CreateFormFile(field, filename)
+	require.NoError(t, err)
+	_, err = fw.Write(content)
+	require.NoError(t, err)
+	require.NoError(t, mw.Close())
+
+	r := httptest.NewRequest(http.MethodPost, target, &body)
+	r.Header.Set("Content-Type", mw.FormDataContentType())
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	return w
+}
+
+func TestAttachmentLifecycle(t *testing.T) {
+	handler := newTestHandler()
+
+	w := upload(t, handler, "/passports/012345678/attachments", "file", `C:\scans\photo page.png`, photoPage)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	var att attachmentResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &att))
+	sum := sha256.Sum256(photoPage)
+	assert.Equal(t, hex.EncodeToString(sum[:]), att.SHA256)
+	assert.Equal(t, int64(len(photoPage)), att.Size)
+	assert.Equal(t, "image/png", att.ContentType)
+	assert.Equal(t, "photo page.png", att.Filename)
+	assert.Equal(t, "012345678", att.PassportID)
+
+	_, body := getJSON(t, handler, "/passports/012345678/attachments")
+	assert.EqualValues(t, 1, body["count"])
+
+	target := "/passports/012345678/attachments/" + att.ID
+	r := httptest.NewRequest(http.MethodGet, target, nil)
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, photoPage, w.Body.Bytes())
+	assert.Equal(t, "image/png", w.Header().Get("Content-Type"))
+	assert.Equal(t, `"`+att.SHA256+`"`, w.Header().Get("ETag"))
+	assert.Equal(t, `attachment; filename="photo page.png"`, w.Header().Get("Content-Disposition"))
+
+	r = httptest.NewRequest(http.MethodGet, target, nil)
+	r.Header.Set("Range", "bytes=0-7")
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	assert.Equal(t, http.StatusPartialContent, w.Code)
+	assert.Equal(t, photoPage[:8], w.Body.Bytes())
+	assert.Equal(t, "bytes 0-7/1008", w.Header().Get("Content-Range"))
+
+	r = httptest.NewRequest(http.MethodGet, target, nil)
+	r.Header.Set("If-None-Match", `"`+att.SHA256+`"`)
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	assert.Equal(t, http.StatusNotModified, w.Code)
+
+	r = httptest.NewRequest(http.MethodDelete, target, nil)
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	assert.Equal(t, http.StatusNoContent, w.Code)
+
+	r = httptest.NewRequest(http.MethodGet, target, nil)
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Contains(t, w.Body.String(), string(status.CodeAttachmentNotFound))
+}
+
+func TestUploadAttachmentErrors(t *testing.T) {
+	srv := NewServer(
+		NewUserService(CreateMockDataSet()),
+		NewPassportService(CreateMockPassportDataSet()),
+		NewVisaService(CreateMockVisaDataSet()),
+		slog.Default(),
+		ServerOptions{Env: "LOCAL", MaxAttachment: 512},
+	)
+	handler := srv.middleware(srv.routes())
+
+	w := upload(t, handler, "/passports/012345678/attachments", "file", "notes.txt", []byte("just some text"))
+	assert.Equal(t, http.StatusUnsupportedMediaType, w.Code)
+	assert.Contains(t, w.Body.String(), string(status.CodeAttachmentTypeUnsupported))
+
+	w = upload(t, handler, "/passports/012345678/attachments", "file", "scan.png", photoPage)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+	assert.Contains(t, w.Body.String(), "must not be larger than 512 bytes")
+
+	w = upload(t, handler, "/passports/012345678/attachments", "scan", "scan.png", photoPage[:100])
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	assert.Contains(t, w.Body.String(), "file is required")
+
+	w = upload(t, handler, "/passports/nope/attachments", "file", "scan.png", photoPage[:100])
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	w = sendJSON(handler, http.MethodPost, "/passports/012345678/attachments", `{}`)
+	assert.Equal(t, http.StatusUnsupportedMediaType, w.Code)
+
+	// Nothing was stored by the failed uploads.
+	_, body := getJSON(t, handler, "/passports/012345678/attachments")
+	assert.EqualValues(t, 0, body["count"])
+}
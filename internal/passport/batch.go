@@ -0,0 +1,217 @@
+package passport
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/leeprovoost/go-rest-api-template/pkg/status"
+	"github.com/leeprovoost/go-rest-api-template/pkg/validate"
+)
+
+// maxBatchOperations bounds the number of operations in one batch request.
+const maxBatchOperations = 50
+
+// batchRequest is the body of POST /batch.
+type batchRequest struct {
+	Atomic     bool             `json:"atomic"`
+	Operations []batchOperation `json:"operations" validate:"required"`
+}
+
+func (req batchRequest) Validate(s *validate.Scope) {
+	if len(req.Operations) > maxBatchOperations {
+		s.Fail("operations", "max", fmt.Sprintf("must contain at most %d items", maxBatchOperations))
+	}
+}
+
+// batchOperation is a single sub-request. Path may include a query string.
+type batchOperation struct {
+	Method  string            `json:"method" validate:"required,oneof=GET POST PUT DELETE"`
+	Path    string            `json:"path"`
+	Headers map[string]string `json:"headers,omitempty"`
+	Body    json.RawMessage   `json:"body,omitempty"`
+}
+
+// Validate checks that the operation targets one of the API's own routes,
+// other than /batch itself.
+func (op batchOperation) Validate(s *validate.Scope) {
+	u, err := url.Parse(op.Path)
+	switch {
+	case err != nil || !strings.HasPrefix(op.Path, "/") || u.Host != "":
+		s.Fail("path", "absolute", "must be an absolute path")
+	case path.Clean(u.Path) == "/batch":
+		s.Fail("path", "recursive", "must not be /batch")
+	}
+}
+
+// batchResult is the response to a single operation.
+type batchResult struct {
+	Status  int               `json:"status"`
+	Headers map[string]string `json:"headers,omitempty"`
+	Body    json.RawMessage   `json:"body,omitempty"`
+}
+
+// batchResponse is the response to POST /batch. Committed is only set for
+// atomic batches.
+type batchResponse struct {
+	Atomic    bool          `json:"atomic"`
+	Committed *bool         `json:"committed,omitempty"`
+	Results   []batchResult `json:"results"`
+	Count     int           `json:"count"`
+}
+
+// handleBatch runs a list of operations through the API's own routes and
+// returns the result of each. Operations run in order. In atomic mode they
+// share a storage transaction: the first operation that fails rolls back
+// everything before it, and the remaining operations are not run.
+func (s *Server) handleBatch(w http.ResponseWriter, r *http.Request) {
+	var req batchRequest
+	if !s.decode(w, r, &req, status.CodeMalformedRequest) {
+		return
+	}
+	if errs := validate.Struct(req); len(errs) > 0 {
+		respondValidationErrors(w, errs)
+		return
+	}
+
+	ctx := r.Context()
+	var tx *storeTx
+	if req.Atomic {
+		var err error
+		ctx, tx, err = s.beginTx(ctx)
+		if errors.Is(err, errTxUnsupported) {
+			respondError(w, status.CodeTransactionsUnsupported, "atomic batches are not supported by this storage backend")
+			return
+		}
+		if err != nil {
+			s.logger.Error("failed to begin transaction", "error", err)
+			respondError(w, status.CodeInternal, "failed to begin transaction")
+			return
+		}
+		defer tx.Rollback()
+	}
+
+	results := make([]batchResult, len(req.Operations))
+	failed := -1
+	for i, op := range req.Operations {
+		if failed >= 0 {
+			results[i] = abortedResult(failed)
+			continue
+		}
+		results[i] = s.dispatch(ctx, r, op)
+		if req.Atomic && results[i].Status >= http.StatusBadRequest {
+			failed = i
+		}
+	}
+
+	resp := batchResponse{Atomic: req.Atomic, Results: results, Count: len(results)}
+	if req.Atomic {
+		committed := failed < 0
+		if committed {
+			if err := tx.Commit(); err != nil {
+				s.logger.Error("failed to commit transaction", "error", err)
+				respondError(w, status.CodeInternal, "failed to commit transaction")
+				return
+			}
+		}
+		resp.Committed = &committed
+		s.logger.Info("batch", "operations", len(results), "atomic", true, "committed", committed)
+	}
+	respond(w, http.StatusOK, resp)
+}
+
+// dispatch runs op through the server's handler, middleware included, and
+// records the response. The sub-request comes from the same client as the
+// parent: it has its address, so it counts against the same rate limit, and
+// inherits its Accept, Authorization and X-Forwarded-For headers unless op
+// sets its own.
+func (s *Server) dispatch(ctx context.Context, parent *http.Request, op batchOperation) batchResult {
+	sub, err := http.NewRequestWithContext(ctx, op.Method, op.Path, bytes.NewReader(op.Body))
+	if err != nil {
+		return batchResult{Status: http.StatusBadRequest}
+	}
+	sub.RemoteAddr = parent.RemoteAddr
+	for _, k := range []string{"Accept", "Authorization", "X-Forwarded-For"} {
+		if v := parent.Header.Get(k); v != "" {
+			sub.Header.Set(k, v)
+		}
+	}
+	if len(op.Body) > 0 {
+		sub.Header.Set("Content-Type", "application/json")
+	}
+	for k, v := range op.Headers {
+		sub.Header.Set(k, v)
+	}
+
+	rec := &batchRecorder{header: make(http.Header)}
+	s.handler.ServeHTTP(rec, sub)
+
+	res := batchResult{Status: rec.statusCode}
+	if res.Status == 0 {
+		res.Status = http.StatusOK
+	}
+	if len(rec.header) > 0 {
+		res.Headers = make(map[string]string, len(rec.header))
+		for k, v := range rec.header {
+			res.Headers[k] = strings.Join(v, ", ")
+		}
+	}
+	if body := bytes.TrimSpace(rec.body.Bytes()); len(body) > 0 {
+		if json.Valid(body) {
+			res.Body = body
+		} else {
+			res.Body, _ = json.Marshal(string(body))
+		}
+	}
+	return res
+}
+
+// outsideTx rejects requests that run inside a transaction, that is in an
+// atomic batch. It guards the routes whose effects can't be rolled back:
+// they write to the blob store or start or stop background operations.
+func (s *Server) outsideTx(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if txFrom(r.Context()) != nil {
+			respondError(w, status.CodeNotTransactional, "this operation can't be rolled back, so it can't run in an atomic batch")
+			return
+		}
+		next(w, r)
+	}
+}
+
+// abortedResult is the result of an operation that was not run because the
+// operation at index failed did not succeed.
+func abortedResult(failed int) batchResult {
+	body, _ := json.Marshal(status.New(status.CodeBatchAborted, fmt.Sprintf("operation %d failed", failed)))
+	return batchResult{Status: status.CodeBatchAborted.HTTPStatus(), Body: body}
+}
+
+// batchRecorder buffers the response to a batch operation.
+type batchRecorder struct {
+	header     http.Header
+	statusCode int
+	body       bytes.Buffer
+}
+
+func (rec *batchRecorder) Header() http.Header {
+	return rec.header
+}
+
+func (rec *batchRecorder) WriteHeader(code int) {
+	if rec.statusCode == 0 {
+		rec.statusCode = code
+	}
+}
+
+func (rec *batchRecorder) Write(b []byte) (int, error) {
+	if rec.statusCode == 0 {
+		rec.statusCode = http.StatusOK
+	}
+	return rec.body.Write(b)
+}
@@ -0,0 +1,229 @@
+package passport
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/leeprovoost/go-rest-api-template/internal/passport/models"
+	"github.com/leeprovoost/go-rest-api-template/pkg/status"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func postBatch(t *testing.T, handler http.Handler, body string) (*httptest.ResponseRecorder, batchResponse) {
+	t.Helper()
+	r := httptest.NewRequest(http.MethodPost, "/batch", strings.NewReader(body))
+	r.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	var resp batchResponse
+	if w.Code == http.StatusOK {
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	}
+	return w, resp
+}
+
+const newUserJSON = `{"firstName":"Apple","lastName":"Jack","dateOfBirth":"1972-03-07T00:00:00Z","locationOfBirth":"Cambridge"}`
+
+func TestBatchRunsEachOperation(t *testing.T) {
+	handler := newTestHandler()
+	w, resp := postBatch(t, handler, `{"operations":[
+		{"method":"GET","path":"/users/0?fields=firstName"},
+		{"method":"POST","path":"/users","body":`+newUserJSON+`},
+		{"method":"GET","path":"/users/99"},
+		{"method":"GET","path":"/users?limit=1"}
+	]}`)
+
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.False(t, resp.Atomic)
+	assert.Nil(t, resp.Committed)
+	require.Equal(t, 4, resp.Count)
+
+	assert.Equal(t, http.StatusOK, resp.Results[0].Status)
+	assert.JSONEq(t, `{"firstName":"John"}`, string(resp.Results[0].Body))
+	assert.Equal(t, http.StatusCreated, resp.Results[1].Status)
+	assert.Equal(t, http.StatusNotFound, resp.Results[2].Status)
+	assert.Contains(t, string(resp.Results[2].Body), string(status.CodeUserNotFound))
+	assert.Contains(t, resp.Results[3].Headers["Link"], `rel="next"`)
+	assert.Equal(t, "3", resp.Results[3].Headers["X-Total-Count"])
+
+	// The failed lookup did not undo the non-atomic create.
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/users/2", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestBatchAtomicCommits(t *testing.T) {
+	handler := newTestHandler()
+	w, resp := postBatch(t, handler, `{"atomic":true,"operations":[
+		{"method":"POST","path":"/users","body":`+newUserJSON+`},
+		{"method":"POST","path":"/users/2/passports","body":{"id":"111111111","dateOfIssue":"2020-01-15T00:00:00Z","dateOfExpiry":"2030-01-15T00:00:00Z","authority":"HMPO"}}
+	]}`)
+
+	require.Equal(t, http.StatusOK, w.Code)
+	require.NotNil(t, resp.Committed)
+	assert.True(t, *resp.Committed)
+	assert.Equal(t, http.StatusCreated, resp.Results[0].Status)
+	assert.Equal(t, http.StatusCreated, resp.Results[1].Status)
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/passports/111111111", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestBatchAtomicRollsBack(t *testing.T) {
+	handler := newTestHandler()
+	w, resp := postBatch(t, handler, `{"atomic":true,"operations":[
+		{"method":"POST","path":"/users","body":`+newUserJSON+`},
+		{"method":"DELETE","path":"/passports/012345678"},
+		{"method":"GET","path":"/users/99"},
+		{"method":"DELETE","path":"/users/1"}
+	]}`)
+
+	require.Equal(t, http.StatusOK, w.Code)
+	require.NotNil(t, resp.Committed)
+	assert.False(t, *resp.Committed)
+	assert.Equal(t, http.StatusCreated, resp.Results[0].Status)
+	assert.Equal(t, http.StatusNoContent, resp.Results[1].Status)
+	assert.Equal(t, http.StatusNotFound, resp.Results[2].Status)
+	assert.Equal(t, http.StatusFailedDependency, resp.Results[3].Status)
+	assert.Contains(t, string(resp.Results[3].Body), string(status.CodeBatchAborted))
+
+	for path, want := range map[string]int{
+		"/users/2":             http.StatusNotFound,
+		"/users/1":             http.StatusOK,
+		"/passports/012345678": http.StatusOK,
+	} {
+		w = httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		assert.Equal(t, want, w.Code, path)
+	}
+
+	// The search index no longer knows about the rolled-back user.
+	assert.Zero(t, doSearch(t, handler, "apple").Total)
+}
+
+func TestBatchAtomicIdempotencyKeys(t *testing.T) {
+	handler := newTestHandler()
+
+	// The response to a rolled-back operation is not stored.
+	_, resp := postBatch(t, handler, `{"atomic":true,"operations":[
+		{"method":"POST","path":"/users","headers":{"Idempotency-Key":"k1"},"body":`+newUserJSON+`},
+		{"method":"GET","path":"/users/99"}
+	]}`)
+	require.NotNil(t, resp.Committed)
+	assert.False(t, *resp.Committed)
+	w := postWithKey(handler, "/users", "k1", newUserJSON)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Empty(t, w.Header().Get("Idempotent-Replayed"))
+	getJSON(t, handler, "/users/2")
+
+	// The response to a committed one is.
+	_, resp = postBatch(t, handler, `{"atomic":true,"operations":[
+		{"method":"POST","path":"/users","headers":{"Idempotency-Key":"k2"},"body":`+newUserJSON+`}
+	]}`)
+	assert.True(t, *resp.Committed)
+	w = postWithKey(handler, "/users", "k2", newUserJSON)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t, "true", w.Header().Get("Idempotent-Replayed"))
+	assert.JSONEq(t, string(resp.Results[0].Body), w.Body.String())
+}
+
+func TestBatchAtomicRejectsIrreversibleOperations(t *testing.T) {
+	handler := newTestHandler()
+
+	w, resp := postBatch(t, handler, `{"atomic":true,"operations":[
+		{"method":"POST","path":"/users","body":`+newUserJSON+`},
+		{"method":"DELETE","path":"/passports/012345678/attachments/a1"}
+	]}`)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.False(t, *resp.Committed)
+	assert.Equal(t, http.StatusUnprocessableEntity, resp.Results[1].Status)
+	assert.Contains(t, string(resp.Results[1].Body), string(status.CodeNotTransactional))
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/users/2", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	for _, op := range []string{
+		`{"method":"POST","path":"/passports/012345678/attachments"}`,
+		`{"method":"POST","path":"/v2/exports","body":{}}`,
+		`{"method":"POST","path":"/imports","body":{"users":[]}}`,
+		`{"method":"DELETE","path":"/operations/nope"}`,
+	} {
+		_, resp = postBatch(t, handler, `{"atomic":true,"operations":[`+op+`]}`)
+		require.Len(t, resp.Results, 1, op)
+		assert.Contains(t, string(resp.Results[0].Body), string(status.CodeNotTransactional), op)
+	}
+
+	// Outside atomic batches they run as usual.
+	_, resp = postBatch(t, handler, `{"operations":[{"method":"DELETE","path":"/operations/nope"}]}`)
+	assert.Contains(t, string(resp.Results[0].Body), string(status.CodeOperationNotFound))
+}
+
+func TestBatchOperationsRunThroughMiddleware(t *testing.T) {
+	var logs bytes.Buffer
+	srv := NewServer(
+		NewUserService(CreateMockDataSet()),
+		NewPassportService(CreateMockPassportDataSet()),
+		NewVisaService(CreateMockVisaDataSet()),
+		slog.New(slog.NewTextHandler(&logs, nil)),
+		ServerOptions{RateLimit: 0.001, RateBurst: 2},
+	)
+
+	// The batch takes one token and the first operation the other.
+	_, resp := postBatch(t, srv.handler, `{"operations":[
+		{"method":"GET","path":"/users/0"},
+		{"method":"GET","path":"/users/1"}
+	]}`)
+	require.Len(t, resp.Results, 2)
+	assert.Equal(t, http.StatusOK, resp.Results[0].Status)
+	assert.Equal(t, http.StatusTooManyRequests, resp.Results[1].Status)
+	assert.Contains(t, string(resp.Results[1].Body), string(status.CodeRateLimited))
+	assert.Contains(t, logs.String(), "path=/users/0 status=200")
+	assert.Contains(t, logs.String(), "path=/users/1 status=429")
+}
+
+func TestBatchAtomicUnsupported(t *testing.T) {
+	type plainUserStore struct{ models.UserStorage }
+	srv := NewServer(
+		plainUserStore{NewUserService(CreateMockDataSet())},
+		NewPassportService(CreateMockPassportDataSet()),
+		NewVisaService(CreateMockVisaDataSet()),
+		slog.Default(),
+		ServerOptions{},
+	)
+	w, _ := postBatch(t, srv.middleware(srv.routes()), `{"atomic":true,"operations":[{"method":"GET","path":"/users"}]}`)
+
+	assert.Equal(t, http.StatusNotImplemented, w.Code)
+	assert.Contains(t, w.Body.String(), string(status.CodeTransactionsUnsupported))
+}
+
+func TestBatchValidation(t *testing.T) {
+	handler := newTestHandler()
+
+	w, _ := postBatch(t, handler, `{"operations":[]}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+
+	w, _ = postBatch(t, handler, `{"operations":[
+		{"method":"PATCH","path":"/users/0"},
+		{"method":"GET","path":"users"},
+		{"method":"POST","path":"/batch"}
+	]}`)
+	require.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	var body status.Response
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	assert.Equal(t, []string{
+		"operations[0].method must be one of GET, POST, PUT, DELETE",
+		"operations[1].path must be an absolute path",
+		"operations[2].path must not be /batch",
+	}, body.Errors)
+	assert.Equal(t, "/operations/1/path", body.Fields[1].Pointer)
+
+	w, _ = postBatch(t, handler, `not json`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
@@ -3,29 +3,41 @@ package passport
 import (
 	"context"
 	"fmt"
+	"maps"
 	"sort"
 	"time"
 
 	"github.com/leeprovoost/go-rest-api-template/internal/passport/models"
+	"github.com/leeprovoost/go-rest-api-template/pkg/civil"
+	"github.com/leeprovoost/go-rest-api-template/pkg/query"
 )
 
 // Compile-time proof of interface implementation.
-var _ models.PassportStorage = (*PassportService)(nil)
+var (
+	_ models.PassportStorage = (*PassportService)(nil)
+	_ models.Transactor      = (*PassportService)(nil)
+)
 
 // PassportService is an in-memory implementation of models.PassportStorage.
+// It is safe for concurrent use.
 type PassportService struct {
 	PassportList map[string]models.Passport
+	transitions  map[string][]models.Transition
+	tx           txLock
 }
 
 // NewPassportService creates a new PassportService with the given data.
 func NewPassportService(list map[string]models.Passport) models.PassportStorage {
 	return &PassportService{
 		PassportList: list,
+		transitions:  make(map[string][]models.Transition),
 	}
 }
 
-// ListPassportsByUser returns all passports belonging to a user, sorted by ID.
-func (s *PassportService) ListPassportsByUser(_ context.Context, userID int) ([]models.Passport, error) {
+// ListPassportsByUser returns the passports belonging to a user that match q,
+// sorted by q.Sort and then by ID.
+func (s *PassportService) ListPassportsByUser(ctx context.Context, userID int, q query.Query) ([]models.Passport, error) {
+	defer s.tx.rlock(ctx)()
 	var passports []models.Passport
 	for _, p := range s.PassportList {
 		if p.UserID == userID {
@@ -38,11 +50,33 @@ func (s *PassportService) ListPassportsByUser(_ context.Context, userID int) ([]
 	sort.Slice(passports, func(i, j int) bool {
 		return passports[i].ID < passports[j].ID
 	})
-	return passports, nil
+	return query.Apply(q, passports), nil
+}
+
+// ListPassportsByUsers returns the passports of the given users in a single
+// pass over the store, keyed by user ID and sorted by passport ID.
+func (s *PassportService) ListPassportsByUsers(ctx context.Context, userIDs []int) (map[int][]models.Passport, error) {
+	defer s.tx.rlock(ctx)()
+	result := make(map[int][]models.Passport, len(userIDs))
+	for _, id := range userIDs {
+		result[id] = []models.Passport{}
+	}
+	for _, p := range s.PassportList {
+		if list, ok := result[p.UserID]; ok {
+			result[p.UserID] = append(list, p)
+		}
+	}
+	for _, list := range result {
+		sort.Slice(list, func(i, j int) bool {
+			return list[i].ID < list[j].ID
+		})
+	}
+	return result, nil
 }
 
 // GetPassport returns a single passport by ID.
-func (s *PassportService) GetPassport(_ context.Context, id string) (models.Passport, error) {
+func (s *PassportService) GetPassport(ctx context.Context, id string) (models.Passport, error) {
+	defer s.tx.rlock(ctx)()
 	p, ok := s.PassportList[id]
 	if !ok {
 		return models.Passport{}, fmt.Errorf("passport %q not found", id)
@@ -51,52 +85,148 @@ func (s *PassportService) GetPassport(_ context.Context, id string) (models.Pass
 }
 
 // AddPassport stores a new passport. The client provides the passport ID.
-func (s *PassportService) AddPassport(_ context.Context, p models.Passport) (models.Passport, error) {
+func (s *PassportService) AddPassport(ctx context.Context, p models.Passport) (models.Passport, error) {
+	defer s.tx.lock(ctx)()
 	if _, exists := s.PassportList[p.ID]; exists {
-		return models.Passport{}, fmt.Errorf("passport %q already exists", p.ID)
+		return models.Passport{}, fmt.Errorf("%w: %q", models.ErrPassportExists, p.ID)
+	}
+	if p.Status == "" {
+		p.Status = models.StatusIssued
+	}
+	if err := s.checkActive(p); err != nil {
+		return models.Passport{}, err
 	}
 	s.PassportList[p.ID] = p
 	return p, nil
 }
 
 // UpdatePassport replaces an existing passport.
-func (s *PassportService) UpdatePassport(_ context.Context, p models.Passport) (models.Passport, error) {
-	if _, ok := s.PassportList[p.ID]; !ok {
+func (s *PassportService) UpdatePassport(ctx context.Context, p models.Passport) (models.Passport, error) {
+	defer s.tx.lock(ctx)()
+	old, ok := s.PassportList[p.ID]
+	if !ok {
 		return p, fmt.Errorf("passport %q not found", p.ID)
 	}
+	p.Status, p.Replaces, p.ReplacedBy = old.Status, old.Replaces, old.ReplacedBy
+	if err := s.checkActive(p); err != nil {
+		return p, err
+	}
 	s.PassportList[p.ID] = p
 	return s.PassportList[p.ID], nil
 }
 
+// checkActive returns an error if p is active and another active passport of
+// the same user was issued for the same state (see models.SameIssuer). The
+// caller holds the lock.
+func (s *PassportService) checkActive(p models.Passport) error {
+	now := time.Now()
+	if !p.Active(now) {
+		return nil
+	}
+	for _, other := range s.PassportList {
+		if other.ID != p.ID && other.UserID == p.UserID && models.SameIssuer(other.Authority, p.Authority) && other.Active(now) {
+			return fmt.Errorf("%w: %q from %s", models.ErrActivePassportExists, other.ID, other.Authority)
+		}
+	}
+	return nil
+}
+
 // DeletePassport removes a passport by ID.
-func (s *PassportService) DeletePassport(_ context.Context, id string) error {
+func (s *PassportService) DeletePassport(ctx context.Context, id string) error {
+	defer s.tx.lock(ctx)()
 	if _, ok := s.PassportList[id]; !ok {
 		return fmt.Errorf("passport %q not found", id)
 	}
 	delete(s.PassportList, id)
+	delete(s.transitions, id)
 	return nil
 }
 
+// TransitionPassport moves a passport to a new status and records the
+// transition.
+func (s *PassportService) TransitionPassport(ctx context.Context, id string, t models.Transition) (models.Transition, error) {
+	defer s.tx.lock(ctx)()
+	p, ok := s.PassportList[id]
+	if !ok {
+		return models.Transition{}, fmt.Errorf("passport %q not found", id)
+	}
+	if !p.Status.CanTransitionTo(t.To) {
+		return models.Transition{}, fmt.Errorf("%w: passport %q is %s and can't become %s", models.ErrInvalidTransition, id, p.Status, t.To)
+	}
+	t.From = p.Status
+	p.Status = t.To
+	if t.To == models.StatusReplaced {
+		p.ReplacedBy = t.ReplacedBy
+	}
+	s.PassportList[id] = p
+	s.transitions[id] = append(s.transitions[id], t)
+	return t, nil
+}
+
+// ListTransitions returns the transitions of a passport, oldest first.
+func (s *PassportService) ListTransitions(ctx context.Context, id string) ([]models.Transition, error) {
+	defer s.tx.rlock(ctx)()
+	if _, ok := s.PassportList[id]; !ok {
+		return nil, fmt.Errorf("passport %q not found", id)
+	}
+	return append([]models.Transition{}, s.transitions[id]...), nil
+}
+
+// PassportStats counts the passports by authority, and the expiries of
+// issued passports by month, in one pass.
+func (s *PassportService) PassportStats(ctx context.Context, from time.Time, months int) (models.PassportStats, error) {
+	defer s.tx.rlock(ctx)()
+	stats := models.PassportStats{
+		Total:           len(s.PassportList),
+		ByAuthority:     make(map[string]int),
+		ExpiriesByMonth: make([]int, months),
+	}
+	from = from.UTC()
+	for _, p := range s.PassportList {
+		stats.ByAuthority[p.Authority]++
+		if p.Status != models.StatusIssued && p.Status != "" {
+			continue
+		}
+		expiry := p.DateOfExpiry
+		month := (expiry.Year-from.Year())*12 + int(expiry.Month) - int(from.Month())
+		if month >= 0 && month < months {
+			stats.ExpiriesByMonth[month]++
+		}
+	}
+	return stats, nil
+}
+
+// Begin starts a transaction. Other callers wait until it is committed or
+// rolled back.
+func (s *PassportService) Begin(ctx context.Context) (context.Context, models.Tx, error) {
+	return s.tx.begin(ctx, func() func() {
+		list, transitions := maps.Clone(s.PassportList), maps.Clone(s.transitions)
+		return func() { s.PassportList, s.transitions = list, transitions }
+	})
+}
+
 // CreateMockPassportDataSet returns test passport data.
 func CreateMockPassportDataSet() map[string]models.Passport {
 	list := make(map[string]models.Passport)
-	doi, _ := time.Parse(time.RFC3339, "2020-01-15T00:00:00Z")
-	doe, _ := time.Parse(time.RFC3339, "2030-01-15T00:00:00Z")
+	doi, _ := civil.ParseDate("2020-01-15")
+	doe, _ := civil.ParseDate("2030-01-15")
 	list["012345678"] = models.Passport{
 		ID:           "012345678",
 		DateOfIssue:  doi,
 		DateOfExpiry: doe,
 		Authority:    "HMPO",
 		UserID:       0,
+		Status:       models.StatusIssued,
 	}
-	doi, _ = time.Parse(time.RFC3339, "2019-06-01T00:00:00Z")
-	doe, _ = time.Parse(time.RFC3339, "2029-06-01T00:00:00Z")
+	doi, _ = civil.ParseDate("2019-06-01")
+	doe, _ = civil.ParseDate("2029-06-01")
 	list["987654321"] = models.Passport{
 		ID:           "987654321",
 		DateOfIssue:  doi,
 		DateOfExpiry: doe,
 		Authority:    "HMPO",
 		UserID:       1,
+		Status:       models.StatusIssued,
 	}
 	return list
 }
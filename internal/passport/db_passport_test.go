@@ -3,15 +3,18 @@ package passport
 import (
 	"context"
 	"testing"
+	"time"
 
 	"github.com/leeprovoost/go-rest-api-template/internal/passport/models"
+	"github.com/leeprovoost/go-rest-api-template/pkg/civil"
+	"github.com/leeprovoost/go-rest-api-template/pkg/query"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
 
 func TestListPassportsByUser(t *testing.T) {
 	srv := NewTestServer()
-	passports, err := srv.passportStore.ListPassportsByUser(context.Background(), 0)
+	passports, err := srv.passportStore.ListPassportsByUser(context.Background(), 0, query.Query{})
 	require.NoError(t, err)
 	assert.Len(t, passports, 1)
 	assert.Equal(t, "012345678", passports[0].ID)
@@ -19,7 +22,7 @@ func TestListPassportsByUser(t *testing.T) {
 
 func TestListPassportsByUserNoResults(t *testing.T) {
 	srv := NewTestServer()
-	passports, err := srv.passportStore.ListPassportsByUser(context.Background(), 999)
+	passports, err := srv.passportStore.ListPassportsByUser(context.Background(), 999, query.Query{})
 	require.NoError(t, err)
 	assert.Empty(t, passports)
 }
@@ -63,10 +66,76 @@ func TestAddPassportDuplicate(t *testing.T) {
 		UserID:    0,
 	}
 	_, err := srv.passportStore.AddPassport(context.Background(), p)
-	assert.Error(t, err)
+	assert.ErrorIs(t, err, models.ErrPassportExists)
 	assert.Contains(t, err.Error(), "already exists")
 }
 
+func TestAddPassportActiveExists(t *testing.T) {
+	srv := NewTestServer()
+	ctx := context.Background()
+	p := models.Passport{
+		ID:           "111111111",
+		DateOfIssue:  civil.Today().AddDate(-1, 0, 0),
+		DateOfExpiry: civil.Today().AddDate(9, 0, 0),
+		Authority:    "HMPO",
+		UserID:       0,
+	}
+	_, err := srv.passportStore.AddPassport(ctx, p)
+	assert.ErrorIs(t, err, models.ErrActivePassportExists)
+	assert.Contains(t, err.Error(), `"012345678"`)
+
+	// A passport from another authority of the same state clashes too.
+	former := p
+	former.ID, former.Authority = "333333333", "IPS"
+	_, err = srv.passportStore.AddPassport(ctx, former)
+	assert.ErrorIs(t, err, models.ErrActivePassportExists)
+	assert.Contains(t, err.Error(), `"012345678" from HMPO`)
+
+	// An expired passport, or one from another state, doesn't clash.
+	expired := p
+	expired.DateOfExpiry = civil.Today().AddDate(0, 0, -1)
+	_, err = srv.passportStore.AddPassport(ctx, expired)
+	require.NoError(t, err)
+	other := p
+	other.ID, other.Authority = "PA1234567", "DFA"
+	_, err = srv.passportStore.AddPassport(ctx, other)
+	require.NoError(t, err)
+
+	// Nor can an update reactivate the expired one.
+	_, err = srv.passportStore.UpdatePassport(ctx, p)
+	assert.ErrorIs(t, err, models.ErrActivePassportExists)
+}
+
+func TestTransitionPassport(t *testing.T) {
+	srv := NewTestServer()
+	ctx := context.Background()
+	at := time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)
+
+	lost, err := srv.passportStore.TransitionPassport(ctx, "012345678", models.Transition{
+		To: models.StatusLost, Reason: "left on a train", Actor: "jdoe", At: at,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, models.StatusIssued, lost.From)
+
+	// A lost passport can't be found again, only replaced.
+	_, err = srv.passportStore.TransitionPassport(ctx, "012345678", models.Transition{To: models.StatusIssued})
+	assert.ErrorIs(t, err, models.ErrInvalidTransition)
+	_, err = srv.passportStore.TransitionPassport(ctx, "nonexistent", models.Transition{To: models.StatusLost})
+	assert.NotErrorIs(t, err, models.ErrInvalidTransition)
+
+	// Updates keep the status.
+	p, err := srv.passportStore.GetPassport(ctx, "012345678")
+	require.NoError(t, err)
+	p.Status = models.StatusIssued
+	updated, err := srv.passportStore.UpdatePassport(ctx, p)
+	require.NoError(t, err)
+	assert.Equal(t, models.StatusLost, updated.Status)
+
+	transitions, err := srv.passportStore.ListTransitions(ctx, "012345678")
+	require.NoError(t, err)
+	assert.Equal(t, []models.Transition{lost}, transitions)
+}
+
 func TestUpdatePassport(t *testing.T) {
 	srv := NewTestServer()
 	p := models.Passport{
@@ -111,3 +180,55 @@ func TestDeletePassportFail(t *testing.T) {
 	assert.Error(t, err)
 	assert.Contains(t, err.Error(), "not found")
 }
+
+func TestListPassportsByUserWithQuery(t *testing.T) {
+	srv := NewTestServer()
+	q := query.Query{
+		Filters: []query.Filter{{Field: "authority", Op: query.OpNe, Value: "HMPO"}},
+	}
+	passports, err := srv.passportStore.ListPassportsByUser(context.Background(), 0, q)
+	require.NoError(t, err)
+	assert.Empty(t, passports)
+}
+
+func TestListPassportsByUsers(t *testing.T) {
+	srv := NewTestServer()
+	result, err := srv.passportStore.ListPassportsByUsers(context.Background(), []int{0, 1, 999})
+	require.NoError(t, err)
+	assert.Len(t, result, 3)
+	assert.Equal(t, "012345678", result[0][0].ID)
+	assert.Equal(t, "987654321", result[1][0].ID)
+	assert.NotNil(t, result[999])
+	assert.Empty(t, result[999])
+}
+
+func TestPassportStats(t *testing.T) {
+	srv := NewTestServer()
+	ctx := context.Background()
+	from := time.Date(2029, 1, 20, 0, 0, 0, 0, time.UTC)
+
+	stats, err := srv.passportStore.PassportStats(ctx, from, 12)
+	require.NoError(t, err)
+	assert.Equal(t, 2, stats.Total)
+	assert.Equal(t, map[string]int{"HMPO": 2}, stats.ByAuthority)
+	require.Len(t, stats.ExpiriesByMonth, 12)
+	assert.Equal(t, 1, stats.ExpiriesByMonth[5]) // June 2029
+	assert.Equal(t, 1, sum(stats.ExpiriesByMonth))
+
+	// January 2030 is the 13th month, and lost passports aren't renewed.
+	_, err = srv.passportStore.TransitionPassport(ctx, "987654321", models.Transition{To: models.StatusLost, At: from})
+	require.NoError(t, err)
+	stats, err = srv.passportStore.PassportStats(ctx, from, 13)
+	require.NoError(t, err)
+	assert.Equal(t, 2, stats.Total)
+	assert.Equal(t, 1, stats.ExpiriesByMonth[12])
+	assert.Equal(t, 1, sum(stats.ExpiriesByMonth))
+}
+
+func sum(counts []int) int {
+	n := 0
+	for _, c := range counts {
+		n += c
+	}
+	return n
+}
@@ -0,0 +1,91 @@
+package passport
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// errTxDone is returned when committing a transaction that has already been
+// committed or rolled back.
+var errTxDone = errors.New("transaction has already been committed or rolled back")
+
+// errTxNested is returned when beginning a transaction on a store from inside
+// another transaction on the same store.
+var errTxNested = errors.New("transaction already in progress")
+
+// txLock guards an in-memory store. A transaction holds the write lock from
+// Begin until Commit or Rollback, so other callers wait for it to finish;
+// calls made with the transaction's context skip locking because the lock is
+// already held.
+type txLock struct {
+	mu sync.RWMutex
+}
+
+type txKey struct{ l *txLock }
+
+// memTx is a snapshot transaction on an in-memory store.
+type memTx struct {
+	l       *txLock
+	restore func()
+	done    bool
+}
+
+// inTx reports whether ctx belongs to an open transaction on this store.
+func (l *txLock) inTx(ctx context.Context) bool {
+	tx, ok := ctx.Value(txKey{l}).(*memTx)
+	return ok && !tx.done
+}
+
+// lock takes the write lock for a mutation and returns the function that
+// releases it.
+func (l *txLock) lock(ctx context.Context) func() {
+	if l.inTx(ctx) {
+		return func() {}
+	}
+	l.mu.Lock()
+	return l.mu.Unlock
+}
+
+// rlock takes the read lock and returns the function that releases it.
+func (l *txLock) rlock(ctx context.Context) func() {
+	if l.inTx(ctx) {
+		return func() {}
+	}
+	l.mu.RLock()
+	return l.mu.RUnlock
+}
+
+// begin takes the write lock and starts a transaction. snapshot is called
+// with the lock held and returns the function that restores the store's
+// state on rollback.
+func (l *txLock) begin(ctx context.Context, snapshot func() func()) (context.Context, *memTx, error) {
+	if l.inTx(ctx) {
+		return ctx, nil, errTxNested
+	}
+	l.mu.Lock()
+	tx := &memTx{l: l, restore: snapshot()}
+	return context.WithValue(ctx, txKey{l}, tx), tx, nil
+}
+
+// Commit keeps the transaction's changes.
+func (tx *memTx) Commit() error {
+	if tx.done {
+		return errTxDone
+	}
+	tx.done = true
+	tx.l.mu.Unlock()
+	return nil
+}
+
+// Rollback restores the store to its state at Begin. It is a no-op after
+// Commit, so it can be deferred.
+func (tx *memTx) Rollback() error {
+	if tx.done {
+		return nil
+	}
+	tx.restore()
+	tx.done = true
+	tx.l.mu.Unlock()
+	return nil
+}
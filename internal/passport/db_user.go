@@ -3,19 +3,27 @@ package passport
 import (
 	"context"
 	"fmt"
+	"maps"
 	"sort"
 	"time"
 
 	"github.com/leeprovoost/go-rest-api-template/internal/passport/models"
+	"github.com/leeprovoost/go-rest-api-template/pkg/civil"
+	"github.com/leeprovoost/go-rest-api-template/pkg/query"
 )
 
 // Compile-time proof of interface implementation.
-var _ models.UserStorage = (*UserService)(nil)
+var (
+	_ models.UserStorage = (*UserService)(nil)
+	_ models.Transactor  = (*UserService)(nil)
+)
 
-// UserService is an in-memory implementation of models.UserStorage.
+// UserService is an in-memory implementation of models.UserStorage. It is
+// safe for concurrent use.
 type UserService struct {
 	UserList  map[int]models.User
 	MaxUserID int
+	tx        txLock
 }
 
 // NewUserService creates a new UserService with the given data.
@@ -26,8 +34,9 @@ func NewUserService(list map[int]models.User, count int) models.UserStorage {
 	}
 }
 
-// ListUsers returns all users sorted by ID.
-func (s *UserService) ListUsers(_ context.Context) ([]models.User, error) {
+// ListUsers returns the users matching q, sorted by q.Sort and then by ID.
+func (s *UserService) ListUsers(ctx context.Context, q query.Query) ([]models.User, error) {
+	defer s.tx.rlock(ctx)()
 	users := make([]models.User, 0, len(s.UserList))
 	for _, v := range s.UserList {
 		users = append(users, v)
@@ -35,11 +44,12 @@ func (s *UserService) ListUsers(_ context.Context) ([]models.User, error) {
 	sort.Slice(users, func(i, j int) bool {
 		return users[i].ID < users[j].ID
 	})
-	return users, nil
+	return query.Apply(q, users), nil
 }
 
 // GetUser returns a single user by ID.
-func (s *UserService) GetUser(_ context.Context, id int) (models.User, error) {
+func (s *UserService) GetUser(ctx context.Context, id int) (models.User, error) {
+	defer s.tx.rlock(ctx)()
 	user, ok := s.UserList[id]
 	if !ok {
 		return models.User{}, fmt.Errorf("user %d not found", id)
@@ -48,7 +58,8 @@ func (s *UserService) GetUser(_ context.Context, id int) (models.User, error) {
 }
 
 // AddUser adds a new user with an auto-generated ID.
-func (s *UserService) AddUser(_ context.Context, u models.User) (models.User, error) {
+func (s *UserService) AddUser(ctx context.Context, u models.User) (models.User, error) {
+	defer s.tx.lock(ctx)()
 	s.MaxUserID++
 	u.ID = s.MaxUserID
 	s.UserList[s.MaxUserID] = u
@@ -56,7 +67,8 @@ func (s *UserService) AddUser(_ context.Context, u models.User) (models.User, er
 }
 
 // UpdateUser replaces an existing user.
-func (s *UserService) UpdateUser(_ context.Context, u models.User) (models.User, error) {
+func (s *UserService) UpdateUser(ctx context.Context, u models.User) (models.User, error) {
+	defer s.tx.lock(ctx)()
 	if _, ok := s.UserList[u.ID]; !ok {
 		return u, fmt.Errorf("user %d not found", u.ID)
 	}
@@ -65,7 +77,8 @@ func (s *UserService) UpdateUser(_ context.Context, u models.User) (models.User,
 }
 
 // DeleteUser removes a user by ID.
-func (s *UserService) DeleteUser(_ context.Context, id int) error {
+func (s *UserService) DeleteUser(ctx context.Context, id int) error {
+	defer s.tx.lock(ctx)()
 	if _, ok := s.UserList[id]; !ok {
 		return fmt.Errorf("user %d not found", id)
 	}
@@ -73,10 +86,29 @@ func (s *UserService) DeleteUser(_ context.Context, id int) error {
 	return nil
 }
 
+// UserStats counts the users, and their ages at now, in one pass.
+func (s *UserService) UserStats(ctx context.Context, now time.Time) (models.UserStats, error) {
+	defer s.tx.rlock(ctx)()
+	stats := models.UserStats{Total: len(s.UserList), ByAge: make([]int, len(models.AgeBands))}
+	for _, u := range s.UserList {
+		stats.ByAge[models.AgeBand(models.Age(u.DateOfBirth, now))]++
+	}
+	return stats, nil
+}
+
+// Begin starts a transaction. Other callers wait until it is committed or
+// rolled back.
+func (s *UserService) Begin(ctx context.Context) (context.Context, models.Tx, error) {
+	return s.tx.begin(ctx, func() func() {
+		list, maxID := maps.Clone(s.UserList), s.MaxUserID
+		return func() { s.UserList, s.MaxUserID = list, maxID }
+	})
+}
+
 // CreateMockDataSet returns test data: a map of users and the max user ID.
 func CreateMockDataSet() (map[int]models.User, int) {
 	list := make(map[int]models.User)
-	dt, _ := time.Parse(time.RFC3339, "1985-12-31T00:00:00Z")
+	dt, _ := civil.ParseDate("1985-12-31")
 	list[0] = models.User{
 		ID:              0,
 		FirstName:       "John",
@@ -84,7 +116,7 @@ func CreateMockDataSet() (map[int]models.User, int) {
 		DateOfBirth:     dt,
 		LocationOfBirth: "London",
 	}
-	dt, _ = time.Parse(time.RFC3339, "1992-01-01T00:00:00Z")
+	dt, _ = civil.ParseDate("1992-01-01")
 	list[1] = models.User{
 		ID:              1,
 		FirstName:       "Jane",
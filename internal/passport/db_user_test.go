@@ -6,19 +6,21 @@ import (
 	"time"
 
 	"github.com/leeprovoost/go-rest-api-template/internal/passport/models"
+	"github.com/leeprovoost/go-rest-api-template/pkg/civil"
+	"github.com/leeprovoost/go-rest-api-template/pkg/query"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
 
 func TestListUsers(t *testing.T) {
 	srv := NewTestServer()
-	list, _ := srv.userStore.ListUsers(context.Background())
+	list, _ := srv.userStore.ListUsers(context.Background(), query.Query{})
 	assert.Equal(t, 2, len(list), "there should be 2 items in the list")
 }
 
 func TestGetUserSuccess(t *testing.T) {
 	srv := NewTestServer()
-	dt, _ := time.Parse(time.RFC3339, "1985-12-31T00:00:00Z")
+	dt, _ := civil.ParseDate("1985-12-31")
 	u, err := srv.userStore.GetUser(context.Background(), 0)
 	require.NoError(t, err)
 	assert.Equal(t, 0, u.ID)
@@ -36,7 +38,7 @@ func TestGetUserFail(t *testing.T) {
 
 func TestAddUser(t *testing.T) {
 	srv := NewTestServer()
-	dt, _ := time.Parse(time.RFC3339, "1972-03-07T00:00:00Z")
+	dt, _ := civil.ParseDate("1972-03-07")
 	u := models.User{
 		FirstName:       "Apple",
 		LastName:        "Jack",
@@ -46,13 +48,13 @@ func TestAddUser(t *testing.T) {
 	u, _ = srv.userStore.AddUser(context.Background(), u)
 	assert.Equal(t, 2, u.ID, "expected database ID should be 2")
 
-	list, _ := srv.userStore.ListUsers(context.Background())
+	list, _ := srv.userStore.ListUsers(context.Background(), query.Query{})
 	assert.Equal(t, 3, len(list), "there should be 3 items in the list")
 }
 
 func TestUpdateUserSuccess(t *testing.T) {
 	srv := NewTestServer()
-	dt, _ := time.Parse(time.RFC3339, "1985-12-31T00:00:00Z")
+	dt, _ := civil.ParseDate("1985-12-31")
 	u := models.User{
 		ID:              0,
 		FirstName:       "John",
@@ -71,7 +73,7 @@ func TestUpdateUserSuccess(t *testing.T) {
 
 func TestUpdateUserFail(t *testing.T) {
 	srv := NewTestServer()
-	dt, _ := time.Parse(time.RFC3339, "1985-12-31T00:00:00Z")
+	dt, _ := civil.ParseDate("1985-12-31")
 	u := models.User{
 		ID:              20,
 		FirstName:       "John",
@@ -94,3 +96,50 @@ func TestDeleteUserFail(t *testing.T) {
 	err := srv.userStore.DeleteUser(context.Background(), 10)
 	assert.Error(t, err)
 }
+
+func TestListUsersWithQuery(t *testing.T) {
+	srv := NewTestServer()
+	q := query.Query{
+		Filters: []query.Filter{{Field: "lastName", Op: query.OpEq, Value: "Doe"}},
+		Sort:    []query.SortKey{{Field: "firstName"}},
+	}
+	list, err := srv.userStore.ListUsers(context.Background(), q)
+	require.NoError(t, err)
+	require.Len(t, list, 2)
+	assert.Equal(t, "Jane", list[0].FirstName)
+	assert.Equal(t, "John", list[1].FirstName)
+}
+
+func TestUserServiceRollback(t *testing.T) {
+	store := &UserService{}
+	store.UserList, store.MaxUserID = CreateMockDataSet()
+
+	ctx, tx, err := store.Begin(context.Background())
+	require.NoError(t, err)
+	_, err = store.AddUser(ctx, models.User{FirstName: "Apple"})
+	require.NoError(t, err)
+	require.NoError(t, store.DeleteUser(ctx, 0))
+	_, _, err = store.Begin(ctx)
+	assert.ErrorIs(t, err, errTxNested)
+	require.NoError(t, tx.Rollback())
+
+	_, err = store.GetUser(context.Background(), 0)
+	assert.NoError(t, err)
+	_, err = store.GetUser(context.Background(), 2)
+	assert.Error(t, err)
+	assert.Equal(t, 1, store.MaxUserID)
+	assert.ErrorIs(t, tx.Commit(), errTxDone)
+}
+
+func TestUserStats(t *testing.T) {
+	srv := NewTestServer()
+	// Jane is 28 in 2020, and John turns 35 on 2020-12-31.
+	stats, err := srv.userStore.UserStats(context.Background(), time.Date(2020, 12, 30, 0, 0, 0, 0, time.UTC))
+	require.NoError(t, err)
+	assert.Equal(t, 2, stats.Total)
+	assert.Equal(t, []int{0, 0, 2, 0, 0, 0, 0}, stats.ByAge)
+
+	stats, err = srv.userStore.UserStats(context.Background(), time.Date(2020, 12, 31, 0, 0, 0, 0, time.UTC))
+	require.NoError(t, err)
+	assert.Equal(t, []int{0, 0, 1, 1, 0, 0, 0}, stats.ByAge)
+}
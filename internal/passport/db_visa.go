@@ -0,0 +1,117 @@
+package passport
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"sort"
+
+	"github.com/leeprovoost/go-rest-api-template/internal/passport/models"
+	"github.com/leeprovoost/go-rest-api-template/pkg/civil"
+	"github.com/leeprovoost/go-rest-api-template/pkg/query"
+)
+
+// Compile-time proof of interface implementation.
+var (
+	_ models.VisaStorage = (*VisaService)(nil)
+	_ models.Transactor  = (*VisaService)(nil)
+)
+
+// VisaService is an in-memory implementation of models.VisaStorage. It is
+// safe for concurrent use.
+type VisaService struct {
+	VisaList  map[int]models.Visa
+	MaxVisaID int
+	tx        txLock
+}
+
+// NewVisaService creates a new VisaService with the given data.
+func NewVisaService(list map[int]models.Visa, count int) models.VisaStorage {
+	return &VisaService{
+		VisaList:  list,
+		MaxVisaID: count,
+	}
+}
+
+// ListVisasByPassport returns the visas in a passport that match q, sorted
+// by q.Sort and then by ID.
+func (s *VisaService) ListVisasByPassport(ctx context.Context, passportID string, q query.Query) ([]models.Visa, error) {
+	defer s.tx.rlock(ctx)()
+	visas := []models.Visa{}
+	for _, v := range s.VisaList {
+		if v.PassportID == passportID {
+			visas = append(visas, v)
+		}
+	}
+	sort.Slice(visas, func(i, j int) bool {
+		return visas[i].ID < visas[j].ID
+	})
+	return query.Apply(q, visas), nil
+}
+
+// GetVisa returns a single visa by ID.
+func (s *VisaService) GetVisa(ctx context.Context, id int) (models.Visa, error) {
+	defer s.tx.rlock(ctx)()
+	v, ok := s.VisaList[id]
+	if !ok {
+		return models.Visa{}, fmt.Errorf("visa %d not found", id)
+	}
+	return v, nil
+}
+
+// AddVisa adds a new visa with an auto-generated ID.
+func (s *VisaService) AddVisa(ctx context.Context, v models.Visa) (models.Visa, error) {
+	defer s.tx.lock(ctx)()
+	s.MaxVisaID++
+	v.ID = s.MaxVisaID
+	s.VisaList[v.ID] = v
+	return v, nil
+}
+
+// UpdateVisa replaces an existing visa, keeping its passport.
+func (s *VisaService) UpdateVisa(ctx context.Context, v models.Visa) (models.Visa, error) {
+	defer s.tx.lock(ctx)()
+	old, ok := s.VisaList[v.ID]
+	if !ok {
+		return v, fmt.Errorf("visa %d not found", v.ID)
+	}
+	v.PassportID = old.PassportID
+	s.VisaList[v.ID] = v
+	return v, nil
+}
+
+// DeleteVisa removes a visa by ID.
+func (s *VisaService) DeleteVisa(ctx context.Context, id int) error {
+	defer s.tx.lock(ctx)()
+	if _, ok := s.VisaList[id]; !ok {
+		return fmt.Errorf("visa %d not found", id)
+	}
+	delete(s.VisaList, id)
+	return nil
+}
+
+// Begin starts a transaction. Other callers wait until it is committed or
+// rolled back.
+func (s *VisaService) Begin(ctx context.Context) (context.Context, models.Tx, error) {
+	return s.tx.begin(ctx, func() func() {
+		list, maxID := maps.Clone(s.VisaList), s.MaxVisaID
+		return func() { s.VisaList, s.MaxVisaID = list, maxID }
+	})
+}
+
+// CreateMockVisaDataSet returns test data: a map of visas and the max visa
+// ID.
+func CreateMockVisaDataSet() (map[int]models.Visa, int) {
+	list := make(map[int]models.Visa)
+	from, _ := civil.ParseDate("2023-05-01")
+	until, _ := civil.ParseDate("2033-04-30")
+	list[1] = models.Visa{
+		ID:         1,
+		PassportID: "012345678",
+		Country:    "USA",
+		ValidFrom:  from,
+		ValidUntil: until,
+		Entries:    "multiple",
+	}
+	return list, 1
+}
@@ -0,0 +1,67 @@
+package passport
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/leeprovoost/go-rest-api-template/internal/passport/models"
+	"github.com/leeprovoost/go-rest-api-template/pkg/civil"
+	"github.com/leeprovoost/go-rest-api-template/pkg/query"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestListVisasByPassport(t *testing.T) {
+	srv := NewTestServer()
+	list, err := srv.visaStore.ListVisasByPassport(context.Background(), "012345678", query.Query{})
+	require.NoError(t, err)
+	require.Len(t, list, 1)
+	assert.Equal(t, "USA", list[0].Country)
+
+	list, err = srv.visaStore.ListVisasByPassport(context.Background(), "987654321", query.Query{})
+	require.NoError(t, err)
+	assert.NotNil(t, list)
+	assert.Empty(t, list)
+}
+
+func TestAddVisa(t *testing.T) {
+	srv := NewTestServer()
+	v := models.Visa{
+		PassportID: "987654321",
+		Country:    "IND",
+		ValidFrom:  civil.Date{Year: 2024, Month: time.January, Day: 1},
+		ValidUntil: civil.Date{Year: 2024, Month: time.June, Day: 30},
+		Entries:    "single",
+	}
+	added, err := srv.visaStore.AddVisa(context.Background(), v)
+	require.NoError(t, err)
+	assert.Equal(t, 2, added.ID)
+
+	fetched, err := srv.visaStore.GetVisa(context.Background(), 2)
+	require.NoError(t, err)
+	assert.Equal(t, added, fetched)
+}
+
+func TestUpdateVisa(t *testing.T) {
+	srv := NewTestServer()
+	v, err := srv.visaStore.GetVisa(context.Background(), 1)
+	require.NoError(t, err)
+	v.Entries = "single"
+	v.PassportID = "987654321"
+	updated, err := srv.visaStore.UpdateVisa(context.Background(), v)
+	require.NoError(t, err)
+	assert.Equal(t, "single", updated.Entries)
+	assert.Equal(t, "012345678", updated.PassportID, "a visa can't move to another passport")
+
+	_, err = srv.visaStore.UpdateVisa(context.Background(), models.Visa{ID: 99})
+	assert.ErrorContains(t, err, "not found")
+}
+
+func TestDeleteVisa(t *testing.T) {
+	srv := NewTestServer()
+	require.NoError(t, srv.visaStore.DeleteVisa(context.Background(), 1))
+	_, err := srv.visaStore.GetVisa(context.Background(), 1)
+	assert.Error(t, err)
+	assert.Error(t, srv.visaStore.DeleteVisa(context.Background(), 1))
+}
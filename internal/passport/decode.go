@@ -0,0 +1,246 @@
+package passport
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/leeprovoost/go-rest-api-template/pkg/civil"
+	"github.com/leeprovoost/go-rest-api-template/pkg/status"
+)
+
+const (
+	// maxBodyBytes is the largest body a JSON endpoint accepts.
+	maxBodyBytes = 1 << 20
+	// maxImportBodyBytes is the limit for POST /imports, which carries many
+	// users at once. No endpoint accepts a larger body.
+	maxImportBodyBytes = 32 << 20
+)
+
+// decodeError is a request body that could not be decoded. Its message says
+// what was wrong and where, and is safe to return to the client.
+type decodeError struct {
+	code    status.Code
+	message string
+}
+
+func (e *decodeError) Error() string {
+	return e.message
+}
+
+// decode reads a JSON request body of at most maxBodyBytes into dst. On
+// failure it responds with an error and returns false; content that isn't
+// valid JSON for dst is reported with the malformed code.
+func (s *Server) decode(w http.ResponseWriter, r *http.Request, dst any, malformed status.Code) bool {
+	return s.decodeLimit(w, r, dst, malformed, maxBodyBytes)
+}
+
+// decodeLimit is decode with a custom body size limit.
+func (s *Server) decodeLimit(w http.ResponseWriter, r *http.Request, dst any, malformed status.Code, limit int64) bool {
+	err := decodeJSON(w, r, dst, limit)
+	if err == nil {
+		return true
+	}
+	code := err.code
+	if code == "" {
+		code = malformed
+	}
+	s.logger.Error("can't decode request body", "path", r.URL.Path, "code", code, "error", err)
+	respondError(w, code, err.message)
+	return false
+}
+
+// decodeJSON decodes a single JSON value from the body of r into dst. The
+// request must be application/json, the body at most limit bytes, and the
+// value must not contain fields dst doesn't have or be followed by anything
+// other than whitespace. A decodeError without a code means the content
+// itself is malformed.
+func decodeJSON(w http.ResponseWriter, r *http.Request, dst any, limit int64) *decodeError {
+	if mt, _, err := mime.ParseMediaType(r.Header.Get("Content-Type")); err != nil || mt != "application/json" {
+		return &decodeError{code: status.CodeUnsupportedMediaType, message: "Content-Type must be application/json"}
+	}
+	data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, limit))
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			return &decodeError{code: status.CodeRequestTooLarge, message: fmt.Sprintf("request body must not be larger than %d bytes", limit)}
+		}
+		return &decodeError{code: status.CodeMalformedRequest, message: "can't read request body"}
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(dst); err != nil {
+		return &decodeError{message: describeJSONError(data, err)}
+	}
+	if rest := bytes.TrimLeft(data[dec.InputOffset():], " \t\r\n"); len(rest) > 0 {
+		return &decodeError{message: "unexpected data after the JSON value " + position(data, int64(len(data)-len(rest)))}
+	}
+	return nil
+}
+
+// describeJSONError turns an error from decoding data into a message that
+// names the offending field, where it can be found, and its position.
+func describeJSONError(data []byte, err error) string {
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	var timeErr *time.ParseError
+	var dateErr *civil.ParseError
+	switch {
+	case len(bytes.TrimSpace(data)) == 0:
+		return "request body is empty"
+	case errors.As(err, &syntaxErr):
+		return fmt.Sprintf("invalid JSON %s: %s", position(data, syntaxErr.Offset-1), syntaxErr.Error())
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		return "invalid JSON " + position(data, int64(len(data))) + ": unexpected end of input"
+	case errors.As(err, &typeErr):
+		// Offset is just past the offending value.
+		field, start := locate(data, func(tok jsonToken) bool { return !tok.key && tok.end >= typeErr.Offset })
+		return fieldError(data, field, start, fmt.Sprintf("must be %s, got %s", jsonType(typeErr), typeErr.Value))
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		name, _ := strconv.Unquote(strings.TrimPrefix(err.Error(), "json: unknown field "))
+		field, start := locate(data, func(tok jsonToken) bool { return tok.key && tok.value == name })
+		return fieldError(data, field, start, "is not allowed")
+	case errors.As(err, &timeErr):
+		field, start := locate(data, func(tok jsonToken) bool { return !tok.key && tok.value == timeErr.Value })
+		return fieldError(data, field, start, "must be an RFC 3339 date-time")
+	case errors.As(err, &dateErr):
+		field, start := locate(data, func(tok jsonToken) bool { return !tok.key && tok.value == dateErr.Value })
+		return fieldError(data, field, start, "must be a date (YYYY-MM-DD)")
+	default:
+		return "invalid JSON: " + err.Error()
+	}
+}
+
+// fieldError formats an error about the value of field, which starts at
+// offset start. An empty field is the top-level value.
+func fieldError(data []byte, field string, start int64, problem string) string {
+	if field == "" {
+		return fmt.Sprintf("request body %s %s", position(data, start), problem)
+	}
+	return fmt.Sprintf("field %q %s %s", field, position(data, start), problem)
+}
+
+// jsonToken is a token found by locate.
+type jsonToken struct {
+	key   bool  // an object key rather than a value
+	value any   // the key or scalar value; nil for objects and arrays
+	start int64 // offset of the first byte of the token
+	end   int64 // offset just past the token, or past the whole object or array
+}
+
+// locate walks the tokens of data and returns the path (such as
+// "users[0].passports[1].id") and start offset of the first token that
+// matches. Objects and arrays are offered as their opening delimiter.
+func locate(data []byte, match func(jsonToken) bool) (string, int64) {
+	type frame struct {
+		object bool
+		path   string
+		key    string // key of the value being read, in an object
+		keyed  bool   // whether key has been read for the current value
+		index  int    // index of the value being read, in an array
+	}
+	var stack []*frame
+	childPath := func() string {
+		if len(stack) == 0 {
+			return ""
+		}
+		f := stack[len(stack)-1]
+		if !f.object {
+			return fmt.Sprintf("%s[%d]", f.path, f.index)
+		}
+		if f.path == "" {
+			return f.key
+		}
+		return f.path + "." + f.key
+	}
+	// done moves on to the next key or element after a value ends.
+	done := func() {
+		if len(stack) == 0 {
+			return
+		}
+		f := stack[len(stack)-1]
+		if f.object {
+			f.keyed = false
+		} else {
+			f.index++
+		}
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	for {
+		before := dec.InputOffset()
+		t, err := dec.Token()
+		if err != nil {
+			return "", 0
+		}
+		end := dec.InputOffset()
+		start := before + int64(len(data[before:end])-len(bytes.TrimLeft(data[before:end], " \t\r\n,:")))
+
+		if d, ok := t.(json.Delim); ok {
+			switch d {
+			case '{', '[':
+				if match(jsonToken{start: start, end: end}) {
+					return childPath(), start
+				}
+				stack = append(stack, &frame{object: d == '{', path: childPath()})
+			default:
+				stack = stack[:len(stack)-1]
+				done()
+			}
+			continue
+		}
+		if len(stack) > 0 {
+			if f := stack[len(stack)-1]; f.object && !f.keyed {
+				f.key, _ = t.(string)
+				f.keyed = true
+				if match(jsonToken{key: true, value: f.key, start: start, end: end}) {
+					return childPath(), start
+				}
+				continue
+			}
+		}
+		if match(jsonToken{value: t, start: start, end: end}) {
+			return childPath(), start
+		}
+		done()
+	}
+}
+
+// jsonType describes the JSON type that the target of typeErr expects.
+func jsonType(typeErr *json.UnmarshalTypeError) string {
+	switch typeErr.Type.Kind() {
+	case reflect.String:
+		return "a string"
+	case reflect.Bool:
+		return "a boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "an integer"
+	case reflect.Float32, reflect.Float64:
+		return "a number"
+	case reflect.Slice, reflect.Array:
+		return "an array"
+	case reflect.Struct, reflect.Map:
+		return "an object"
+	default:
+		return typeErr.Type.String()
+	}
+}
+
+// position formats the 1-based line and column of the byte at offset.
+func position(data []byte, offset int64) string {
+	offset = max(0, min(offset, int64(len(data))))
+	before := data[:offset]
+	line := bytes.Count(before, []byte("\n")) + 1
+	column := len(before) - bytes.LastIndexByte(before, '\n')
+	return fmt.Sprintf("at line %d, column %d", line, column)
+}
@@ -0,0 +1,111 @@
+package passport
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/leeprovoost/go-rest-api-template/internal/passport/models"
+	"github.com/leeprovoost/go-rest-api-template/pkg/status"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func decodeString(t *testing.T, body string, dst any) *decodeError {
+	t.Helper()
+	r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+	r.Header.Set("Content-Type", "application/json; charset=utf-8")
+	return decodeJSON(httptest.NewRecorder(), r, dst, 1024)
+}
+
+func TestDecodeJSON(t *testing.T) {
+	var u models.User
+	require.Nil(t, decodeString(t, `{"firstName": "Apple", "lastName": "Jack"}`+"\n", &u))
+	assert.Equal(t, "Jack", u.LastName)
+}
+
+func TestDecodeJSONErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want string
+	}{
+		{"empty", "  ", "request body is empty"},
+		{"syntax", "{\"firstName\": \"a\",\n  \"lastName\" \"b\"}", "invalid JSON at line 2, column 14: invalid character '\"' after object key"},
+		{"truncated", `{"firstName": "a"`, "invalid JSON at line 1, column 18: unexpected end of input"},
+		{"trailing data", `{"firstName": "a"} {}`, "unexpected data after the JSON value at line 1, column 20"},
+		{"unknown field", "{\"firstName\": \"a\",\n \"shoeSize\": 9}", `field "shoeSize" at line 2, column 2 is not allowed`},
+		{"wrong type", `{"firstName": 12}`, `field "firstName" at line 1, column 15 must be a string, got number`},
+		{"not an object", `[1]`, "request body at line 1, column 1 must be an object, got array"},
+		{"bad date", `{"dateOfBirth": "yesterday"}`, `field "dateOfBirth" at line 1, column 17 must be a date (YYYY-MM-DD)`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var u models.User
+			err := decodeString(t, tt.body, &u)
+			require.NotNil(t, err)
+			assert.Empty(t, err.code)
+			assert.Equal(t, tt.want, err.message)
+		})
+	}
+}
+
+func TestDecodeJSONNestedFieldPaths(t *testing.T) {
+	var req importRequest
+	err := decodeString(t, `{"users": [{"firstName": "a"}, {"passports": [{"id": "1", "colour": "red"}]}]}`, &req)
+	require.NotNil(t, err)
+	assert.Equal(t, `field "users[1].passports[0].colour" at line 1, column 59 is not allowed`, err.message)
+
+	err = decodeString(t, `{"users": [{"firstName": "a"}, {"id": "x"}]}`, &req)
+	require.NotNil(t, err)
+	assert.Equal(t, `field "users[1].id" at line 1, column 39 must be an integer, got string`, err.message)
+}
+
+func TestDecodeJSONContentType(t *testing.T) {
+	for _, ct := range []string{"", "text/plain", "application/xml", "application/json-patch+json"} {
+		r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{}`))
+		if ct != "" {
+			r.Header.Set("Content-Type", ct)
+		}
+		var u models.User
+		err := decodeJSON(httptest.NewRecorder(), r, &u, 1024)
+		require.NotNil(t, err, ct)
+		assert.Equal(t, status.CodeUnsupportedMediaType, err.code, ct)
+	}
+}
+
+func TestDecodeJSONTooLarge(t *testing.T) {
+	var u models.User
+	err := decodeString(t, `{"firstName": "`+strings.Repeat("a", 2048)+`"}`, &u)
+	require.NotNil(t, err)
+	assert.Equal(t, status.CodeRequestTooLarge, err.code)
+}
+
+func TestCreateUserStrictDecoding(t *testing.T) {
+	handler := newTestHandler()
+
+	tests := []struct {
+		contentType string
+		body        string
+		wantStatus  int
+		wantCode    status.Code
+	}{
+		{"text/plain", newUserJSON, http.StatusUnsupportedMediaType, status.CodeUnsupportedMediaType},
+		{"application/json", `{"firstName":"Apple","lastName":"Jack","nickname":"AJ"}`, http.StatusBadRequest, status.CodeMalformedUser},
+		{"application/json", newUserJSON + `{}`, http.StatusBadRequest, status.CodeMalformedUser},
+		{"application/json", `{"firstName":"` + strings.Repeat("a", maxBodyBytes) + `"}`, http.StatusRequestEntityTooLarge, status.CodeRequestTooLarge},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodPost, "/users", strings.NewReader(tt.body))
+		r.Header.Set("Content-Type", tt.contentType)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+
+		assert.Equal(t, tt.wantStatus, w.Code)
+		var resp status.Response
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		assert.Equal(t, tt.wantCode, resp.Code)
+	}
+}
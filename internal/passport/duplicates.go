@@ -0,0 +1,252 @@
+package passport
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/leeprovoost/go-rest-api-template/internal/passport/models"
+	"github.com/leeprovoost/go-rest-api-template/pkg/civil"
+	"github.com/leeprovoost/go-rest-api-template/pkg/phonetic"
+	"github.com/leeprovoost/go-rest-api-template/pkg/query"
+	"github.com/leeprovoost/go-rest-api-template/pkg/status"
+	"github.com/leeprovoost/go-rest-api-template/pkg/validate"
+)
+
+// minDuplicateScore is the lowest score reported as a possible duplicate.
+// Matching names alone score 0.6, and the same last name and date of birth
+// 0.7, which siblings born on the same day share; a duplicate needs more.
+const minDuplicateScore = 0.75
+
+// How well a field of a possible duplicate matches.
+const (
+	matchExact      = "exact"      // equal after normalizeName, or the same day
+	matchPhonetic   = "phonetic"   // names that sound the same
+	matchTransposed = "transposed" // dates with day and month swapped
+	matchNone       = "none"
+)
+
+// duplicate is a user who may be the same person as another user.
+type duplicate struct {
+	user    models.User
+	score   float64
+	matches map[string]string // field name to how it matched
+}
+
+// duplicateResponse is a duplicate as returned by GET
+// /users/{id}/duplicates.
+type duplicateResponse struct {
+	User    any               `json:"user"`
+	Score   float64           `json:"score"`
+	Matches map[string]string `json:"matches"`
+}
+
+// findDuplicates scores every other user in users against u, and returns
+// those that reach minDuplicateScore, best first. Names count for 0.6,
+// split between first and last name, and score 1 if they are equal after
+// normalizeName and 0.8 if they sound the same. The date of birth counts for
+// 0.4, or half that if the day and month are swapped.
+func findDuplicates(u models.User, users []models.User) []duplicate {
+	var dups []duplicate
+	for _, other := range users {
+		if other.ID == u.ID {
+			continue
+		}
+		first, firstScore := compareNames(u.FirstName, other.FirstName)
+		last, lastScore := compareNames(u.LastName, other.LastName)
+		dob, dobScore := compareDates(u.DateOfBirth, other.DateOfBirth)
+		score := 0.3*firstScore + 0.3*lastScore + 0.4*dobScore
+		if score < minDuplicateScore-1e-9 {
+			continue
+		}
+		dups = append(dups, duplicate{
+			user:    other,
+			score:   float64(int(score*100+0.5)) / 100,
+			matches: map[string]string{"firstName": first, "lastName": last, "dateOfBirth": dob},
+		})
+	}
+	sort.SliceStable(dups, func(i, j int) bool {
+		if dups[i].score != dups[j].score {
+			return dups[i].score > dups[j].score
+		}
+		return dups[i].user.ID < dups[j].user.ID
+	})
+	return dups
+}
+
+// compareNames returns how a and b match, and the score of the match.
+func compareNames(a, b string) (string, float64) {
+	na, nb := normalizeName(a), normalizeName(b)
+	switch {
+	case na == "" || nb == "":
+		return matchNone, 0
+	case na == nb:
+		return matchExact, 1
+	case soundexAll(na) == soundexAll(nb):
+		return matchPhonetic, 0.8
+	}
+	return matchNone, 0
+}
+
+// soundexAll returns the Soundex codes of the words of a normalized name.
+func soundexAll(name string) string {
+	words := strings.Fields(name)
+	for i, w := range words {
+		words[i] = phonetic.Soundex(w)
+	}
+	return strings.Join(words, " ")
+}
+
+// compareDates returns how two dates of birth match, and the score of the
+// match. Swapped days and months are a common mistake when dates are
+// written in another country's order.
+func compareDates(a, b civil.Date) (string, float64) {
+	switch {
+	case a == b:
+		return matchExact, 1
+	case a.Year == b.Year && int(a.Month) == b.Day && a.Day == int(b.Month):
+		return matchTransposed, 0.5
+	}
+	return matchNone, 0
+}
+
+func (s *Server) handleListDuplicates(w http.ResponseWriter, r *http.Request) {
+	uid, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		respondError(w, status.CodeInvalidUserID, "invalid user id")
+		return
+	}
+	u, err := s.userStore.GetUser(r.Context(), uid)
+	if err != nil {
+		respondError(w, status.CodeUserNotFound, "can't find user")
+		return
+	}
+	users, err := s.userStore.ListUsers(r.Context(), query.Query{})
+	if err != nil {
+		s.logger.Error("failed to list users", "error", err)
+		respondError(w, status.CodeInternal, "failed to list users")
+		return
+	}
+	v := versionOf(r)
+	dups := findDuplicates(u, users)
+	resp := make([]duplicateResponse, len(dups))
+	for i, d := range dups {
+		resp[i] = duplicateResponse{User: v.user(d.user), Score: d.score, Matches: d.matches}
+	}
+	respond(w, http.StatusOK, map[string]any{
+		"duplicates": resp,
+		"count":      len(resp),
+	})
+}
+
+// mergeRequest is the body of POST /users/{id}/merge: the users to merge
+// into the user in the path.
+type mergeRequest struct {
+	UserIDs []int `json:"userIds" validate:"required"`
+}
+
+// handleMergeUsers merges duplicates into the user in the path, which
+// survives: in one transaction, the duplicates' passports are moved to it and
+// the duplicates are deleted. If a moved passport would give the survivor two
+// active passports from the same issuing state, nothing is merged.
+func (s *Server) handleMergeUsers(w http.ResponseWriter, r *http.Request) {
+	uid, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		respondError(w, status.CodeInvalidUserID, "invalid user id")
+		return
+	}
+	var req mergeRequest
+	if !s.decode(w, r, &req, status.CodeMalformedRequest) {
+		return
+	}
+	errs := validate.Struct(req)
+	seen := make(map[int]bool, len(req.UserIDs))
+	for i, id := range req.UserIDs {
+		switch {
+		case id == uid:
+			errs = append(errs, validate.Error{
+				Pointer: "/userIds/" + strconv.Itoa(i),
+				Rule:    "survivor",
+				Message: fmt.Sprintf("userIds[%d] must not be the surviving user", i),
+			})
+		case seen[id]:
+			errs = append(errs, validate.Error{
+				Pointer: "/userIds/" + strconv.Itoa(i),
+				Rule:    "unique",
+				Message: fmt.Sprintf("userIds[%d] must not repeat user %d", i, id),
+			})
+		}
+		seen[id] = true
+	}
+	if len(errs) > 0 {
+		respondValidationErrors(w, errs)
+		return
+	}
+
+	ctx, tx, err := s.beginTx(r.Context())
+	if errors.Is(err, errTxUnsupported) {
+		respondError(w, status.CodeTransactionsUnsupported, "merges are not supported by this storage backend")
+		return
+	}
+	if err != nil {
+		s.logger.Error("failed to begin transaction", "error", err)
+		respondError(w, status.CodeInternal, "failed to begin transaction")
+		return
+	}
+	defer tx.Rollback()
+
+	survivor, err := s.userStore.GetUser(ctx, uid)
+	if err != nil {
+		respondError(w, status.CodeUserNotFound, "can't find user")
+		return
+	}
+	for _, id := range req.UserIDs {
+		if _, err := s.userStore.GetUser(ctx, id); err != nil {
+			respondError(w, status.CodeUserNotFound, fmt.Sprintf("can't find user %d", id))
+			return
+		}
+		passports, err := s.passportStore.ListPassportsByUser(ctx, id, query.Query{})
+		if err != nil {
+			s.logger.Error("failed to list passports", "userId", id, "error", err)
+			respondError(w, status.CodeInternal, "something went wrong")
+			return
+		}
+		for _, p := range passports {
+			p.UserID = uid
+			_, err := s.passportStore.UpdatePassport(ctx, p)
+			if code, ok := passportConflict(err); ok {
+				respondError(w, code, err.Error())
+				return
+			}
+			if err != nil {
+				s.logger.Error("failed to move passport", "id", p.ID, "error", err)
+				respondError(w, status.CodeInternal, "something went wrong")
+				return
+			}
+		}
+		if err := s.userStore.DeleteUser(ctx, id); err != nil {
+			s.logger.Error("failed to delete merged user", "userId", id, "error", err)
+			respondError(w, status.CodeInternal, "something went wrong")
+			return
+		}
+	}
+	passports, err := s.passportStore.ListPassportsByUser(ctx, uid, query.Query{})
+	if err != nil {
+		s.logger.Error("failed to list passports", "userId", uid, "error", err)
+		respondError(w, status.CodeInternal, "something went wrong")
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		s.logger.Error("failed to commit transaction", "error", err)
+		respondError(w, status.CodeInternal, "failed to commit transaction")
+		return
+	}
+	if passports == nil {
+		passports = []models.Passport{}
+	}
+	s.logger.Info("users merged", "survivor", uid, "merged", req.UserIDs)
+	respondUser(w, r, http.StatusOK, survivor, nil, passports)
+}
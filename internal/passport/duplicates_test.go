@@ -0,0 +1,132 @@
+package passport
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/leeprovoost/go-rest-api-template/internal/passport/models"
+	"github.com/leeprovoost/go-rest-api-template/pkg/civil"
+	"github.com/leeprovoost/go-rest-api-template/pkg/status"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFindDuplicates(t *testing.T) {
+	dob := civil.Date{Year: 1985, Month: time.March, Day: 4}
+	john := models.User{ID: 0, FirstName: "John", LastName: "Doe", DateOfBirth: dob}
+	users := []models.User{
+		john,
+		{ID: 1, FirstName: "JOHN", LastName: "DOE", DateOfBirth: dob},
+		{ID: 2, FirstName: "Jon", LastName: "Doe", DateOfBirth: dob},
+		{ID: 3, FirstName: "John", LastName: "Doe", DateOfBirth: civil.Date{Year: 1985, Month: time.April, Day: 3}},
+		{ID: 4, FirstName: "Mary", LastName: "Doe", DateOfBirth: dob},
+		{ID: 5, FirstName: "John", LastName: "Doe", DateOfBirth: civil.Date{Year: 1990, Month: time.March, Day: 4}},
+	}
+
+	dups := findDuplicates(john, users)
+	require.Len(t, dups, 3)
+	assert.Equal(t, 1, dups[0].user.ID)
+	assert.Equal(t, 1.0, dups[0].score)
+	assert.Equal(t, 2, dups[1].user.ID)
+	assert.Equal(t, 0.94, dups[1].score)
+	assert.Equal(t, map[string]string{"firstName": "phonetic", "lastName": "exact", "dateOfBirth": "exact"}, dups[1].matches)
+	assert.Equal(t, 3, dups[2].user.ID)
+	assert.Equal(t, 0.8, dups[2].score)
+	assert.Equal(t, "transposed", dups[2].matches["dateOfBirth"])
+}
+
+func TestListDuplicatesHandler(t *testing.T) {
+	handler := newTestHandler()
+	w := postWithKey(handler, "/users", "", `{"firstName":"Jon","lastName":"Doe","dateOfBirth":"1985-12-31T00:00:00Z","locationOfBirth":"London"}`)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+
+	_, body := getJSON(t, handler, "/v2/users/0/duplicates")
+	assert.EqualValues(t, 1, body["count"])
+	dup := body["duplicates"].([]any)[0].(map[string]any)
+	assert.Equal(t, 0.94, dup["score"])
+	assert.Equal(t, "Jon", dup["user"].(map[string]any)["firstName"])
+	assert.Equal(t, "1985-12-31", dup["user"].(map[string]any)["dateOfBirth"])
+	assert.Equal(t, "phonetic", dup["matches"].(map[string]any)["firstName"])
+
+	_, body = getJSON(t, handler, "/users/1/duplicates")
+	assert.EqualValues(t, 0, body["count"])
+
+	w = sendJSON(handler, http.MethodGet, "/users/42/duplicates", "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestMergeUsersHandler(t *testing.T) {
+	handler := newTestHandler()
+	w := postWithKey(handler, "/users", "", `{"firstName":"Jon","lastName":"Doe","dateOfBirth":"1985-12-31T00:00:00Z","locationOfBirth":"London"}`)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	w = postWithKey(handler, "/users/2/passports", "", `{"id":"PA1234567","dateOfIssue":"2024-01-01T00:00:00Z","dateOfExpiry":"2034-01-01T00:00:00Z","authority":"DFA"}`)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+
+	w = postWithKey(handler, "/users/0/merge", "", `{"userIds":[2]}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var survivor map[string]any
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &survivor))
+	assert.Equal(t, "John", survivor["firstName"])
+	assert.Len(t, survivor["passports"], 2)
+
+	_, moved := getJSON(t, handler, "/passports/PA1234567")
+	assert.Equal(t, float64(0), moved["userId"])
+	w = sendJSON(handler, http.MethodGet, "/users/2", "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestMergeUsersIsAtomic(t *testing.T) {
+	handler := newTestHandler()
+	// Jane's HMPO passport can't join John's, so nothing is merged.
+	w := postWithKey(handler, "/users/0/merge", "", `{"userIds":[1]}`)
+	assert.Equal(t, http.StatusConflict, w.Code)
+	var resp status.Response
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, status.CodePassportActiveExists, resp.Code)
+
+	getJSON(t, handler, "/users/1")
+	_, p := getJSON(t, handler, "/passports/987654321")
+	assert.Equal(t, float64(1), p["userId"])
+}
+
+func TestMergeUsersInAtomicBatch(t *testing.T) {
+	handler := newTestHandler()
+	w := postWithKey(handler, "/users", "", `{"firstName":"Jon","lastName":"Doe","dateOfBirth":"1985-12-31","locationOfBirth":"London"}`)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+
+	// The merge joins the batch's transaction.
+	w, resp := postBatch(t, handler, `{"atomic":true,"operations":[
+		{"method":"POST","path":"/users/0/merge","body":{"userIds":[2]}}
+	]}`)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, http.StatusOK, resp.Results[0].Status, string(resp.Results[0].Body))
+	require.NotNil(t, resp.Committed)
+	assert.True(t, *resp.Committed)
+	w = sendJSON(handler, http.MethodGet, "/users/2", "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestMergeUsersValidation(t *testing.T) {
+	handler := newTestHandler()
+	tests := []struct {
+		name string
+		path string
+		body string
+		code int
+	}{
+		{"no users", "/users/0/merge", `{"userIds":[]}`, http.StatusUnprocessableEntity},
+		{"survivor merged into itself", "/users/0/merge", `{"userIds":[0]}`, http.StatusUnprocessableEntity},
+		{"repeated user", "/users/0/merge", `{"userIds":[2,2]}`, http.StatusUnprocessableEntity},
+		{"unknown survivor", "/users/42/merge", `{"userIds":[1]}`, http.StatusNotFound},
+		{"unknown duplicate", "/users/0/merge", `{"userIds":[42]}`, http.StatusNotFound},
+		{"invalid id", "/users/abc/merge", `{"userIds":[1]}`, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := postWithKey(handler, tt.path, "", tt.body)
+			assert.Equal(t, tt.code, w.Code, w.Body.String())
+		})
+	}
+}
@@ -0,0 +1,127 @@
+package passport
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/leeprovoost/go-rest-api-template/internal/passport/models"
+	"github.com/leeprovoost/go-rest-api-template/pkg/query"
+	"github.com/leeprovoost/go-rest-api-template/pkg/status"
+	"github.com/leeprovoost/go-rest-api-template/pkg/validate"
+)
+
+// exportChunkSize is how many users an export loads passports for at a time,
+// which is also how often it reports progress and checks for cancellation.
+const exportChunkSize = 100
+
+// handleExport starts an operation that exports users matching the same
+// filters and sort as GET /users, with their passports embedded.
+func (s *Server) handleExport(w http.ResponseWriter, r *http.Request) {
+	q, err := query.Parse(r.URL.Query(), userSchema)
+	if err != nil {
+		respondError(w, status.CodeInvalidQuery, err.Error())
+		return
+	}
+	v := versionOf(r)
+	s.acceptOperation(w, r, "export", func(ctx context.Context, run *operationRun) (any, error) {
+		users, err := s.userStore.ListUsers(ctx, q)
+		if err != nil {
+			s.logger.Error("export: failed to list users", "error", err)
+			return nil, err
+		}
+		run.setTotal(len(users))
+
+		out := make([]any, 0, len(users))
+		for start := 0; start < len(users); start += exportChunkSize {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+			chunk := users[start:min(start+exportChunkSize, len(users))]
+			byUser, err := s.loadPassports(ctx, chunk)
+			if err != nil {
+				s.logger.Error("export: failed to load passports", "error", err)
+				return nil, err
+			}
+			for _, u := range chunk {
+				out = append(out, embedPassports(v, u, nil, byUser[u.ID]))
+			}
+			run.advance(len(chunk))
+		}
+		return map[string]any{
+			"users": out,
+			"count": len(out),
+		}, nil
+	})
+}
+
+// importUser is a user to import, with the passports to create for it.
+type importUser struct {
+	models.User
+	Passports []models.Passport `json:"passports"`
+}
+
+type importRequest struct {
+	Users []importUser `json:"users" validate:"required"`
+}
+
+// handleImport validates every user and passport up front, so that the
+// request fails fast on bad input, then creates them in an operation. Items
+// the store rejects, such as duplicate passports, are reported in the
+// operation's errors without stopping the import.
+func (s *Server) handleImport(w http.ResponseWriter, r *http.Request) {
+	var req importRequest
+	if !s.decodeLimit(w, r, &req, status.CodeMalformedRequest, maxImportBodyBytes) {
+		return
+	}
+	// Imported passports are issued, like created ones. Their status and
+	// links only change through transitions and renewals.
+	for _, iu := range req.Users {
+		for j := range iu.Passports {
+			p := &iu.Passports[j]
+			p.Status, p.Replaces, p.ReplacedBy = "", "", ""
+		}
+	}
+	if errs := validate.Struct(req); len(errs) > 0 {
+		respondValidationErrors(w, errs)
+		return
+	}
+	v := versionOf(r)
+	s.acceptOperation(w, r, "import", func(ctx context.Context, run *operationRun) (any, error) {
+		run.setTotal(len(req.Users))
+		created := make([]link, 0, len(req.Users))
+		for i, iu := range req.Users {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+			u := iu.User
+			u.ID = -1 // will be assigned by store
+			user, err := s.userStore.AddUser(ctx, u)
+			if err != nil {
+				s.logger.Error("import: failed to create user", "index", i, "error", err)
+				run.fail(status.CodeInternal, fmt.Sprintf("users[%d]: failed to create user", i))
+				run.advance(1)
+				continue
+			}
+			created = append(created, link{Href: v.prefix + "/users/" + strconv.Itoa(user.ID)})
+			for j, p := range iu.Passports {
+				p.UserID = user.ID
+				if _, err := s.passportStore.AddPassport(ctx, p); err != nil {
+					code, ok := passportConflict(err)
+					if !ok {
+						s.logger.Error("import: failed to create passport", "index", i, "error", err)
+						run.fail(status.CodeInternal, fmt.Sprintf("users[%d].passports[%d]: failed to create passport", i, j))
+						continue
+					}
+					run.fail(code, fmt.Sprintf("users[%d].passports[%d]: %v", i, j, err))
+				}
+			}
+			run.advance(1)
+		}
+		return map[string]any{
+			"users": created,
+			"count": len(created),
+		}, nil
+	})
+}
@@ -0,0 +1,69 @@
+package passport
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/leeprovoost/go-rest-api-template/pkg/query"
+)
+
+// parseFields reads the comma-separated fields query parameter and validates
+// each name against schema. It returns nil when the client did not ask for a
+// sparse fieldset.
+func parseFields(r *http.Request, schema query.Schema) ([]string, error) {
+	raw := r.URL.Query().Get("fields")
+	if raw == "" {
+		return nil, nil
+	}
+	var fields []string
+	seen := make(map[string]bool)
+	for _, f := range strings.Split(raw, ",") {
+		f = strings.TrimSpace(f)
+		if f == "" || seen[f] {
+			continue
+		}
+		if _, ok := schema[f]; !ok {
+			return nil, fmt.Errorf("unknown field %q", f)
+		}
+		seen[f] = true
+		fields = append(fields, f)
+	}
+	return fields, nil
+}
+
+// project returns v restricted to the given JSON fields. A nil fields slice
+// returns v unchanged.
+func project(v any, fields []string) any {
+	if fields == nil {
+		return v
+	}
+	dat, err := json.Marshal(v)
+	if err != nil {
+		return v
+	}
+	var obj map[string]json.RawMessage
+	if err := json.Unmarshal(dat, &obj); err != nil {
+		return v
+	}
+	out := make(map[string]json.RawMessage, len(fields))
+	for _, f := range fields {
+		if val, ok := obj[f]; ok {
+			out[f] = val
+		}
+	}
+	return out
+}
+
+// projectAll applies project to every element of list.
+func projectAll[T any](list []T, fields []string) any {
+	if fields == nil {
+		return list
+	}
+	out := make([]any, len(list))
+	for i, v := range list {
+		out[i] = project(v, fields)
+	}
+	return out
+}
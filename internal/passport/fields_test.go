@@ -0,0 +1,35 @@
+package passport
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/leeprovoost/go-rest-api-template/internal/passport/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseFieldsEmpty(t *testing.T) {
+	r := httptest.NewRequest("GET", "/users", nil)
+	fields, err := parseFields(r, userSchema)
+	require.NoError(t, err)
+	assert.Nil(t, fields)
+}
+
+func TestParseFieldsDeduplicatesAndTrims(t *testing.T) {
+	r := httptest.NewRequest("GET", "/users?fields=id,%20lastName,,id", nil)
+	fields, err := parseFields(r, userSchema)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"id", "lastName"}, fields)
+}
+
+func TestParseFieldsUnknown(t *testing.T) {
+	r := httptest.NewRequest("GET", "/users?fields=ID", nil)
+	_, err := parseFields(r, userSchema)
+	assert.EqualError(t, err, `unknown field "ID"`)
+}
+
+func TestProjectNilFieldsReturnsValue(t *testing.T) {
+	u := models.User{ID: 3, FirstName: "Ann"}
+	assert.Equal(t, u, project(u, nil))
+}
@@ -0,0 +1,468 @@
+package passport
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/language/ast"
+	"github.com/leeprovoost/go-rest-api-template/internal/passport/models"
+	"github.com/leeprovoost/go-rest-api-template/pkg/civil"
+	"github.com/leeprovoost/go-rest-api-template/pkg/query"
+	"github.com/leeprovoost/go-rest-api-template/pkg/status"
+	"github.com/leeprovoost/go-rest-api-template/pkg/validate"
+)
+
+// graphQLRequest is the body of POST /graphql.
+type graphQLRequest struct {
+	Query         string         `json:"query"`
+	OperationName string         `json:"operationName"`
+	Variables     map[string]any `json:"variables"`
+	Extensions    map[string]any `json:"extensions"` // sent by some clients; ignored
+}
+
+func (s *Server) handleGraphQL(w http.ResponseWriter, r *http.Request) {
+	var req graphQLRequest
+	if !s.decode(w, r, &req, status.CodeMalformedRequest) {
+		return
+	}
+	if req.Query == "" {
+		respondError(w, status.CodeMalformedRequest, "query is required")
+		return
+	}
+
+	ctx := context.WithValue(r.Context(), passportLoaderKey{}, &passportLoader{store: s.passportStore})
+	result := graphql.Do(graphql.Params{
+		Schema:         s.graphql,
+		RequestString:  req.Query,
+		VariableValues: req.Variables,
+		OperationName:  req.OperationName,
+		Context:        ctx,
+	})
+	respond(w, http.StatusOK, result)
+}
+
+// graphQLError is a resolver error that carries a status code in its
+// extensions, so GraphQL clients see the same codes as REST clients.
+type graphQLError struct {
+	code    status.Code
+	message string
+	errors  validate.Errors
+}
+
+func (e *graphQLError) Error() string {
+	return e.message
+}
+
+func (e *graphQLError) Extensions() map[string]any {
+	ext := map[string]any{"code": e.code}
+	if len(e.errors) > 0 {
+		ext["errors"] = e.errors.Messages()
+		ext["fields"] = e.errors
+	}
+	return ext
+}
+
+func validationError(errs validate.Errors) error {
+	return &graphQLError{code: status.CodeValidationFailed, message: "validation failed", errors: errs}
+}
+
+// passportLoader batches the passport lookups of one GraphQL request. Every
+// User.passports field joins the current batch and returns a thunk; the
+// executor runs the thunks after resolving the whole level, and the first one
+// loads the passports of every user in the batch with a single storage call.
+type passportLoader struct {
+	store models.PassportStorage
+	batch *passportBatch
+}
+
+type passportBatch struct {
+	ids    []int
+	done   bool
+	result map[int][]models.Passport
+	err    error
+}
+
+type passportLoaderKey struct{}
+
+func (l *passportLoader) load(ctx context.Context, uid int) func() (any, error) {
+	if l.batch == nil {
+		l.batch = &passportBatch{}
+	}
+	b := l.batch
+	b.ids = append(b.ids, uid)
+	return func() (any, error) {
+		if !b.done {
+			if l.batch == b {
+				l.batch = nil
+			}
+			b.result, b.err = l.store.ListPassportsByUsers(ctx, b.ids)
+			b.done = true
+		}
+		if b.err != nil {
+			return nil, b.err
+		}
+		return b.result[uid], nil
+	}
+}
+
+// dateScalar is a calendar date, written as YYYY-MM-DD. Like the REST API,
+// it accepts RFC 3339 timestamps as input, from clients written when dates
+// were DateTime.
+var dateScalar = graphql.NewScalar(graphql.ScalarConfig{
+	Name:        "Date",
+	Description: "A calendar date, serialized as YYYY-MM-DD.",
+	Serialize: func(v any) any {
+		if d, ok := v.(civil.Date); ok {
+			return d.String()
+		}
+		return nil
+	},
+	ParseValue: parseDateValue,
+	ParseLiteral: func(v ast.Value) any {
+		if s, ok := v.(*ast.StringValue); ok {
+			return parseDateValue(s.Value)
+		}
+		return nil
+	},
+})
+
+// parseDateValue returns the date in a string, or nil, which the executor
+// reports as an invalid value, if it isn't one.
+func parseDateValue(v any) any {
+	s, ok := v.(string)
+	if !ok {
+		return nil
+	}
+	var d civil.Date
+	if err := d.UnmarshalText([]byte(s)); err != nil {
+		return nil
+	}
+	return d
+}
+
+// newGraphQLSchema builds the GraphQL schema. Resolvers use the same stores
+// and validation rules as the REST handlers.
+func (s *Server) newGraphQLSchema() (graphql.Schema, error) {
+	var userType, passportType *graphql.Object
+
+	userType = graphql.NewObject(graphql.ObjectConfig{
+		Name: "User",
+		Fields: graphql.FieldsThunk(func() graphql.Fields {
+			return graphql.Fields{
+				"id":              {Type: graphql.NewNonNull(graphql.Int)},
+				"firstName":       {Type: graphql.NewNonNull(graphql.String)},
+				"lastName":        {Type: graphql.NewNonNull(graphql.String)},
+				"dateOfBirth":     {Type: graphql.NewNonNull(dateScalar)},
+				"locationOfBirth": {Type: graphql.NewNonNull(graphql.String)},
+				"passports": {
+					Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(passportType))),
+					Resolve: func(p graphql.ResolveParams) (any, error) {
+						u := p.Source.(models.User)
+						loader := p.Context.Value(passportLoaderKey{}).(*passportLoader)
+						return loader.load(p.Context, u.ID), nil
+					},
+				},
+			}
+		}),
+	})
+
+	passportType = graphql.NewObject(graphql.ObjectConfig{
+		Name: "Passport",
+		Fields: graphql.FieldsThunk(func() graphql.Fields {
+			return graphql.Fields{
+				"id":           {Type: graphql.NewNonNull(graphql.String)},
+				"dateOfIssue":  {Type: graphql.NewNonNull(dateScalar)},
+				"dateOfExpiry": {Type: graphql.NewNonNull(dateScalar)},
+				"authority":    {Type: graphql.NewNonNull(graphql.String)},
+				"userId":       {Type: graphql.NewNonNull(graphql.Int)},
+				"status": {
+					Type:        graphql.NewNonNull(graphql.String),
+					Description: "Changed through POST /passports/{id}/transitions only.",
+					Resolve: func(p graphql.ResolveParams) (any, error) {
+						return string(p.Source.(models.Passport).Status), nil
+					},
+				},
+				"replaces":   {Type: graphql.String, Description: "ID of the passport this one renewed.", Resolve: optionalString(func(p models.Passport) string { return p.Replaces })},
+				"replacedBy": {Type: graphql.String, Description: "ID of the passport that renewed this one.", Resolve: optionalString(func(p models.Passport) string { return p.ReplacedBy })},
+				"owner": {
+					Type: userType,
+					Resolve: func(p graphql.ResolveParams) (any, error) {
+						u, err := s.userStore.GetUser(p.Context, p.Source.(models.Passport).UserID)
+						if err != nil {
+							return nil, nil
+						}
+						return u, nil
+					},
+				},
+			}
+		}),
+	})
+
+	userPageType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "UserPage",
+		Fields: graphql.Fields{
+			"users":  {Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(userType)))},
+			"count":  {Type: graphql.NewNonNull(graphql.Int)},
+			"total":  {Type: graphql.NewNonNull(graphql.Int)},
+			"offset": {Type: graphql.NewNonNull(graphql.Int)},
+			"limit":  {Type: graphql.NewNonNull(graphql.Int)},
+		},
+	})
+
+	// Input fields are nullable so that missing values are reported by the
+	// shared validation rules rather than by the GraphQL type checker.
+	userInput := graphql.NewInputObject(graphql.InputObjectConfig{
+		Name: "UserInput",
+		Fields: graphql.InputObjectConfigFieldMap{
+			"firstName":       {Type: graphql.String},
+			"lastName":        {Type: graphql.String},
+			"dateOfBirth":     {Type: dateScalar},
+			"locationOfBirth": {Type: graphql.String},
+		},
+	})
+	passportInput := graphql.NewInputObject(graphql.InputObjectConfig{
+		Name: "PassportInput",
+		Fields: graphql.InputObjectConfigFieldMap{
+			"id":           {Type: graphql.String, Description: "Required when creating a passport; ignored when updating."},
+			"dateOfIssue":  {Type: dateScalar},
+			"dateOfExpiry": {Type: dateScalar},
+			"authority":    {Type: graphql.String},
+			"userId":       {Type: graphql.Int, Description: "New owner of the passport when updating, which keeps its owner if omitted; ignored when creating."},
+		},
+	})
+
+	queryType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Query",
+		Fields: graphql.Fields{
+			"users": {
+				Type: graphql.NewNonNull(userPageType),
+				Args: graphql.FieldConfigArgument{
+					"filter": {Type: graphql.String, Description: "Filters in the REST query syntax, e.g. lastName=Doe&dateOfBirth[gte]=1990-01-01"},
+					"sort":   {Type: graphql.String, Description: "Comma-separated sort keys; prefix with - for descending"},
+					"offset": {Type: graphql.Int, DefaultValue: 0},
+					"limit":  {Type: graphql.Int, DefaultValue: 25},
+				},
+				Resolve: s.resolveUsers,
+			},
+			"user": {
+				Type: userType,
+				Args: graphql.FieldConfigArgument{
+					"id": {Type: graphql.NewNonNull(graphql.Int)},
+				},
+				Resolve: func(p graphql.ResolveParams) (any, error) {
+					u, err := s.userStore.GetUser(p.Context, p.Args["id"].(int))
+					if err != nil {
+						return nil, &graphQLError{code: status.CodeUserNotFound, message: "can't find user"}
+					}
+					return u, nil
+				},
+			},
+			"passport": {
+				Type: passportType,
+				Args: graphql.FieldConfigArgument{
+					"id": {Type: graphql.NewNonNull(graphql.String)},
+				},
+				Resolve: func(p graphql.ResolveParams) (any, error) {
+					pp, err := s.passportStore.GetPassport(p.Context, p.Args["id"].(string))
+					if err != nil {
+						return nil, &graphQLError{code: status.CodePassportNotFound, message: "can't find passport"}
+					}
+					return pp, nil
+				},
+			},
+		},
+	})
+
+	mutationType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Mutation",
+		Fields: graphql.Fields{
+			"createUser": {
+				Type: graphql.NewNonNull(userType),
+				Args: graphql.FieldConfigArgument{
+					"input": {Type: graphql.NewNonNull(userInput)},
+				},
+				Resolve: func(p graphql.ResolveParams) (any, error) {
+					u := userFromInput(p.Args["input"])
+					if errs := validate.Struct(u); len(errs) > 0 {
+						return nil, validationError(errs)
+					}
+					u.ID = -1 // will be assigned by store
+					return s.userStore.AddUser(p.Context, u)
+				},
+			},
+			"updateUser": {
+				Type: graphql.NewNonNull(userType),
+				Args: graphql.FieldConfigArgument{
+					"id":    {Type: graphql.NewNonNull(graphql.Int)},
+					"input": {Type: graphql.NewNonNull(userInput)},
+				},
+				Resolve: func(p graphql.ResolveParams) (any, error) {
+					u := userFromInput(p.Args["input"])
+					if errs := validate.Struct(u); len(errs) > 0 {
+						return nil, validationError(errs)
+					}
+					u.ID = p.Args["id"].(int)
+					user, err := s.userStore.UpdateUser(p.Context, u)
+					if err != nil {
+						return nil, &graphQLError{code: status.CodeUserNotFound, message: "can't find user"}
+					}
+					return user, nil
+				},
+			},
+			"deleteUser": {
+				Type: graphql.NewNonNull(graphql.Boolean),
+				Args: graphql.FieldConfigArgument{
+					"id": {Type: graphql.NewNonNull(graphql.Int)},
+				},
+				Resolve: func(p graphql.ResolveParams) (any, error) {
+					if err := s.userStore.DeleteUser(p.Context, p.Args["id"].(int)); err != nil {
+						return nil, &graphQLError{code: status.CodeUserNotFound, message: "can't find user"}
+					}
+					return true, nil
+				},
+			},
+			"createPassport": {
+				Type: graphql.NewNonNull(passportType),
+				Args: graphql.FieldConfigArgument{
+					"userId": {Type: graphql.NewNonNull(graphql.Int)},
+					"input":  {Type: graphql.NewNonNull(passportInput)},
+				},
+				Resolve: func(p graphql.ResolveParams) (any, error) {
+					pp := passportFromInput(p.Args["input"])
+					pp.UserID = p.Args["userId"].(int)
+					if errs := validate.Struct(pp); len(errs) > 0 {
+						return nil, validationError(errs)
+					}
+					passport, err := s.passportStore.AddPassport(p.Context, pp)
+					if code, ok := passportConflict(err); ok {
+						return nil, &graphQLError{code: code, message: err.Error()}
+					}
+					if err != nil {
+						s.logger.Error("failed to create passport", "error", err)
+						return nil, &graphQLError{code: status.CodeInternal, message: "something went wrong"}
+					}
+					return passport, nil
+				},
+			},
+			"updatePassport": {
+				Type: graphql.NewNonNull(passportType),
+				Args: graphql.FieldConfigArgument{
+					"id":    {Type: graphql.NewNonNull(graphql.String)},
+					"input": {Type: graphql.NewNonNull(passportInput)},
+				},
+				Resolve: func(p graphql.ResolveParams) (any, error) {
+					pp := passportFromInput(p.Args["input"])
+					pp.ID = p.Args["id"].(string)
+					if in, _ := p.Args["input"].(map[string]any); in["userId"] == nil {
+						if err := s.keepOwner(p.Context, &pp); err != nil {
+							return nil, &graphQLError{code: status.CodePassportNotFound, message: "can't find passport"}
+						}
+					}
+					if errs := validate.Struct(pp); len(errs) > 0 {
+						return nil, validationError(errs)
+					}
+					passport, err := s.passportStore.UpdatePassport(p.Context, pp)
+					if code, ok := passportConflict(err); ok {
+						return nil, &graphQLError{code: code, message: err.Error()}
+					}
+					if err != nil {
+						return nil, &graphQLError{code: status.CodePassportNotFound, message: "can't find passport"}
+					}
+					return passport, nil
+				},
+			},
+			"deletePassport": {
+				Type: graphql.NewNonNull(graphql.Boolean),
+				Args: graphql.FieldConfigArgument{
+					"id": {Type: graphql.NewNonNull(graphql.String)},
+				},
+				Resolve: func(p graphql.ResolveParams) (any, error) {
+					err := s.deletePassport(p.Context, p.Args["id"].(string))
+					if errors.Is(err, errPassportNotFound) {
+						return nil, &graphQLError{code: status.CodePassportNotFound, message: "can't find passport"}
+					}
+					if err != nil {
+						s.logger.Error("failed to delete passport", "error", err)
+						return nil, &graphQLError{code: status.CodeInternal, message: "something went wrong"}
+					}
+					return true, nil
+				},
+			},
+		},
+	})
+
+	return graphql.NewSchema(graphql.SchemaConfig{
+		Query:    queryType,
+		Mutation: mutationType,
+	})
+}
+
+// resolveUsers lists users, accepting the same filter and sort syntax as
+// GET /users.
+func (s *Server) resolveUsers(p graphql.ResolveParams) (any, error) {
+	values := url.Values{}
+	if filter, ok := p.Args["filter"].(string); ok && filter != "" {
+		var err error
+		if values, err = url.ParseQuery(filter); err != nil {
+			return nil, &graphQLError{code: status.CodeInvalidQuery, message: "malformed filter"}
+		}
+	}
+	if sort, ok := p.Args["sort"].(string); ok && sort != "" {
+		values.Set("sort", sort)
+	}
+	q, err := query.Parse(values, userSchema)
+	if err != nil {
+		return nil, &graphQLError{code: status.CodeInvalidQuery, message: err.Error()}
+	}
+	list, err := s.userStore.ListUsers(p.Context, q)
+	if err != nil {
+		s.logger.Error("failed to list users", "error", err)
+		return nil, &graphQLError{code: status.CodeInternal, message: "failed to list users"}
+	}
+	offset, _ := p.Args["offset"].(int)
+	limit, _ := p.Args["limit"].(int)
+	offset, limit = clampPagination(offset, limit)
+	page := paginate(list, offset, limit)
+	return map[string]any{
+		"users":  page,
+		"count":  len(page),
+		"total":  len(list),
+		"offset": offset,
+		"limit":  limit,
+	}, nil
+}
+
+// optionalString resolves a string field of a passport, or null if the
+// field is empty.
+func optionalString(field func(models.Passport) string) graphql.FieldResolveFn {
+	return func(p graphql.ResolveParams) (any, error) {
+		if v := field(p.Source.(models.Passport)); v != "" {
+			return v, nil
+		}
+		return nil, nil
+	}
+}
+
+func userFromInput(arg any) models.User {
+	in, _ := arg.(map[string]any)
+	var u models.User
+	u.FirstName, _ = in["firstName"].(string)
+	u.LastName, _ = in["lastName"].(string)
+	u.DateOfBirth, _ = in["dateOfBirth"].(civil.Date)
+	u.LocationOfBirth, _ = in["locationOfBirth"].(string)
+	return u
+}
+
+func passportFromInput(arg any) models.Passport {
+	in, _ := arg.(map[string]any)
+	var p models.Passport
+	p.ID, _ = in["id"].(string)
+	p.DateOfIssue, _ = in["dateOfIssue"].(civil.Date)
+	p.DateOfExpiry, _ = in["dateOfExpiry"].(civil.Date)
+	p.Authority, _ = in["authority"].(string)
+	p.UserID, _ = in["userId"].(int)
+	return p
+}
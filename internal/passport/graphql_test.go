@@ -0,0 +1,165 @@
+package passport
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type graphQLResponse struct {
+	Data   map[string]any `json:"data"`
+	Errors []struct {
+		Message    string         `json:"message"`
+		Extensions map[string]any `json:"extensions"`
+	} `json:"errors"`
+}
+
+func doGraphQL(t *testing.T, handler http.Handler, query string, variables map[string]any) graphQLResponse {
+	t.Helper()
+	body, err := json.Marshal(map[string]any{"query": query, "variables": variables})
+	require.NoError(t, err)
+	r := httptest.NewRequest(http.MethodPost, "/graphql", strings.NewReader(string(body)))
+	r.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	require.Equal(t, http.StatusOK, w.Code)
+	var resp graphQLResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	return resp
+}
+
+func TestGraphQLUsersWithBatchedPassports(t *testing.T) {
+	store := &countingPassportStore{PassportStorage: NewPassportService(CreateMockPassportDataSet())}
+	srv := NewServer(NewUserService(CreateMockDataSet()), store, NewVisaService(CreateMockVisaDataSet()), slog.Default(), ServerOptions{Env: "LOCAL"})
+	handler := srv.middleware(srv.routes())
+	store.single, store.batch = 0, 0 // ignore search index build
+
+	resp := doGraphQL(t, handler, `{
+		users(sort: "-id") {
+			total
+			users { firstName passports { id authority owner { lastName } } }
+		}
+	}`, nil)
+
+	require.Empty(t, resp.Errors)
+	page := resp.Data["users"].(map[string]any)
+	assert.Equal(t, float64(2), page["total"])
+	users := page["users"].([]any)
+	require.Len(t, users, 2)
+	jane := users[0].(map[string]any)
+	assert.Equal(t, "Jane", jane["firstName"])
+	assert.Equal(t, []any{map[string]any{
+		"id":        "987654321",
+		"authority": "HMPO",
+		"owner":     map[string]any{"lastName": "Doe"},
+	}}, jane["passports"])
+	assert.Equal(t, 0, store.single)
+	assert.Equal(t, 1, store.batch)
+}
+
+func TestGraphQLUsersFilter(t *testing.T) {
+	resp := doGraphQL(t, newTestHandler(), `{ users(filter: "firstName=Jane") { users { id } } }`, nil)
+	require.Empty(t, resp.Errors)
+	assert.Equal(t, []any{map[string]any{"id": float64(1)}}, resp.Data["users"].(map[string]any)["users"])
+
+	resp = doGraphQL(t, newTestHandler(), `{ users(filter: "shoeSize=9") { total } }`, nil)
+	require.Len(t, resp.Errors, 1)
+	assert.Equal(t, "INVALID_QUERY", resp.Errors[0].Extensions["code"])
+}
+
+func TestGraphQLUserAndPassport(t *testing.T) {
+	handler := newTestHandler()
+
+	resp := doGraphQL(t, handler, `query($id: Int!) { user(id: $id) { dateOfBirth passports { dateOfExpiry } } }`, map[string]any{"id": 0})
+	require.Empty(t, resp.Errors)
+	assert.Equal(t, map[string]any{
+		"dateOfBirth": "1985-12-31",
+		"passports":   []any{map[string]any{"dateOfExpiry": "2030-01-15"}},
+	}, resp.Data["user"])
+
+	resp = doGraphQL(t, handler, `{ passport(id: "012345678") { owner { firstName } } }`, nil)
+	require.Empty(t, resp.Errors)
+	assert.Equal(t, map[string]any{"owner": map[string]any{"firstName": "John"}}, resp.Data["passport"])
+
+	resp = doGraphQL(t, handler, `{ user(id: 99) { id } }`, nil)
+	require.Len(t, resp.Errors, 1)
+	assert.Equal(t, "USER_NOT_FOUND", resp.Errors[0].Extensions["code"])
+}
+
+func TestGraphQLMutations(t *testing.T) {
+	handler := newTestHandler()
+
+	resp := doGraphQL(t, handler, `mutation($in: UserInput!) { createUser(input: $in) { id firstName } }`, map[string]any{
+		"in": map[string]any{
+			"firstName":       "Apple",
+			"lastName":        "Jack",
+			"dateOfBirth":     "1972-03-07T00:00:00Z",
+			"locationOfBirth": "Cambridge",
+		},
+	})
+	require.Empty(t, resp.Errors)
+	assert.Equal(t, map[string]any{"id": float64(2), "firstName": "Apple"}, resp.Data["createUser"])
+
+	resp = doGraphQL(t, handler, `mutation {
+		createPassport(userId: 2, input: {id: "111111111", dateOfIssue: "2020-01-15", dateOfExpiry: "2030-01-15", authority: "HMPO"}) { userId }
+	}`, nil)
+	require.Empty(t, resp.Errors)
+	assert.Equal(t, map[string]any{"userId": float64(2)}, resp.Data["createPassport"])
+
+	// The new passport is visible through REST and the search index.
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/passports/111111111", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, 1, doSearch(t, handler, "apple").Total)
+
+	// Without userId, an update keeps the passport's owner.
+	resp = doGraphQL(t, handler, `mutation {
+		updatePassport(id: "111111111", input: {dateOfIssue: "2020-01-15", dateOfExpiry: "2029-01-15", authority: "HMPO"}) { userId dateOfExpiry }
+	}`, nil)
+	require.Empty(t, resp.Errors)
+	assert.Equal(t, map[string]any{"userId": float64(2), "dateOfExpiry": "2029-01-15"}, resp.Data["updatePassport"])
+
+	resp = doGraphQL(t, handler, `mutation { deleteUser(id: 2) }`, nil)
+	require.Empty(t, resp.Errors)
+	assert.Equal(t, true, resp.Data["deleteUser"])
+}
+
+func TestGraphQLMutationErrors(t *testing.T) {
+	handler := newTestHandler()
+
+	resp := doGraphQL(t, handler, `mutation { createUser(input: {firstName: "Apple"}) { id } }`, nil)
+	require.Len(t, resp.Errors, 1)
+	assert.Equal(t, "VALIDATION_FAILED", resp.Errors[0].Extensions["code"])
+	assert.Len(t, resp.Errors[0].Extensions["errors"], 3)
+
+	resp = doGraphQL(t, handler, `mutation {
+		createPassport(userId: 0, input: {id: "012345678", dateOfIssue: "2020-01-15T00:00:00Z", dateOfExpiry: "2030-01-15T00:00:00Z", authority: "HMPO"}) { id }
+	}`, nil)
+	require.Len(t, resp.Errors, 1)
+	assert.Equal(t, "PASSPORT_DUPLICATE", resp.Errors[0].Extensions["code"])
+
+	resp = doGraphQL(t, handler, `mutation { deletePassport(id: "nope") }`, nil)
+	require.Len(t, resp.Errors, 1)
+	assert.Equal(t, "PASSPORT_NOT_FOUND", resp.Errors[0].Extensions["code"])
+}
+
+func TestGraphQLMalformedRequest(t *testing.T) {
+	handler := newTestHandler()
+	for _, body := range []string{`not json`, `{"query": ""}`} {
+		r := httptest.NewRequest(http.MethodPost, "/graphql", strings.NewReader(body))
+		r.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		assert.Equal(t, http.StatusBadRequest, w.Code, body)
+	}
+
+	resp := doGraphQL(t, handler, `{ users { nope } }`, nil)
+	assert.NotEmpty(t, resp.Errors)
+	assert.Nil(t, resp.Data)
+}
@@ -0,0 +1,396 @@
+package passport
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/leeprovoost/go-rest-api-template/internal/passport/models"
+	"github.com/leeprovoost/go-rest-api-template/pkg/civil"
+	passportv1 "github.com/leeprovoost/go-rest-api-template/pkg/pb/passport/v1"
+	"github.com/leeprovoost/go-rest-api-template/pkg/query"
+	"github.com/leeprovoost/go-rest-api-template/pkg/status"
+	"github.com/leeprovoost/go-rest-api-template/pkg/validate"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/reflection"
+	grpcstatus "google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// errorDomain is the ErrorInfo domain of gRPC errors.
+const errorDomain = "go-rest-api-template"
+
+// newGRPCServer returns a gRPC server exposing the user and passport
+// services, with the same request ID, logging and rate limiting policies as
+// the HTTP middleware.
+func (s *Server) newGRPCServer() *grpc.Server {
+	interceptors := []grpc.UnaryServerInterceptor{grpcRequestID, s.grpcLogger}
+	if s.rateLimiter != nil {
+		interceptors = append(interceptors, s.rateLimiter.unaryInterceptor)
+	}
+	g := grpc.NewServer(grpc.ChainUnaryInterceptor(interceptors...))
+	passportv1.RegisterUserServiceServer(g, &grpcUserServer{s: s})
+	passportv1.RegisterPassportServiceServer(g, &grpcPassportServer{s: s})
+	healthpb.RegisterHealthServer(g, health.NewServer())
+	reflection.Register(g)
+	return g
+}
+
+// stopGRPC stops g gracefully, cutting off remaining calls when ctx expires.
+func stopGRPC(ctx context.Context, g *grpc.Server) {
+	done := make(chan struct{})
+	go func() {
+		g.GracefulStop()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		g.Stop()
+	}
+}
+
+// --- Interceptors ---
+
+type requestIDKey struct{}
+
+// grpcRequestID reads or generates a unique request ID and returns it in the
+// x-request-id response header.
+func grpcRequestID(ctx context.Context, req any, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	var id string
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if ids := md.Get("x-request-id"); len(ids) > 0 {
+			id = ids[0]
+		}
+	}
+	if id == "" {
+		id = generateID()
+	}
+	_ = grpc.SetHeader(ctx, metadata.Pairs("x-request-id", id))
+	return handler(context.WithValue(ctx, requestIDKey{}, id), req)
+}
+
+func (s *Server) grpcLogger(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	start := time.Now()
+	resp, err := handler(ctx, req)
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	s.logger.Info("rpc",
+		"method", info.FullMethod,
+		"code", grpcstatus.Code(err).String(),
+		"duration", time.Since(start),
+		"request_id", id,
+	)
+	return resp, err
+}
+
+func (rl *rateLimiter) unaryInterceptor(ctx context.Context, req any, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	if !rl.getLimiter(grpcClientIP(ctx)).Allow() {
+		return nil, grpcError(status.CodeRateLimited, "rate limit exceeded")
+	}
+	return handler(ctx, req)
+}
+
+func grpcClientIP(ctx context.Context) string {
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if forwarded := md.Get("x-forwarded-for"); len(forwarded) > 0 {
+			return forwarded[0]
+		}
+	}
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return ""
+	}
+	ip, _, err := net.SplitHostPort(p.Addr.String())
+	if err != nil {
+		return p.Addr.String()
+	}
+	return ip
+}
+
+// --- Errors ---
+
+// grpcError returns a gRPC status for an API error code. The code itself is
+// attached as ErrorInfo.Reason, so gRPC clients see the same codes as REST
+// clients, and validation errors are attached as a BadRequest with a field
+// violation per error.
+func grpcError(code status.Code, message string, violations ...validate.Error) error {
+	st := grpcstatus.New(grpcCode(code), message)
+	info := &errdetails.ErrorInfo{Reason: string(code), Domain: errorDomain}
+	var err error
+	if len(violations) > 0 {
+		br := &errdetails.BadRequest{}
+		for _, v := range violations {
+			br.FieldViolations = append(br.FieldViolations, &errdetails.BadRequest_FieldViolation{Field: v.Path(), Description: v.Message})
+		}
+		st, err = st.WithDetails(info, br)
+	} else {
+		st, err = st.WithDetails(info)
+	}
+	if err != nil {
+		return grpcstatus.Error(grpcCode(code), message)
+	}
+	return st.Err()
+}
+
+// grpcCode maps an API error code to the closest gRPC code.
+func grpcCode(code status.Code) codes.Code {
+	switch code.HTTPStatus() {
+	case 400, 422:
+		return codes.InvalidArgument
+	case 404:
+		return codes.NotFound
+	case 409:
+		return codes.AlreadyExists
+	case 429:
+		return codes.ResourceExhausted
+	case 501:
+		return codes.Unimplemented
+	default:
+		return codes.Internal
+	}
+}
+
+// --- Conversions ---
+
+func userToProto(u models.User) *passportv1.User {
+	return &passportv1.User{
+		Id:              int64(u.ID),
+		FirstName:       u.FirstName,
+		LastName:        u.LastName,
+		DateOfBirth:     dateToProto(u.DateOfBirth),
+		LocationOfBirth: u.LocationOfBirth,
+	}
+}
+
+func userFromProto(u *passportv1.User) models.User {
+	return models.User{
+		ID:              int(u.GetId()),
+		FirstName:       u.GetFirstName(),
+		LastName:        u.GetLastName(),
+		DateOfBirth:     dateFromProto(u.GetDateOfBirth()),
+		LocationOfBirth: u.GetLocationOfBirth(),
+	}
+}
+
+func passportToProto(p models.Passport) *passportv1.Passport {
+	return &passportv1.Passport{
+		Id:           p.ID,
+		DateOfIssue:  dateToProto(p.DateOfIssue),
+		DateOfExpiry: dateToProto(p.DateOfExpiry),
+		Authority:    p.Authority,
+		UserId:       proto.Int64(int64(p.UserID)),
+		Status:       string(p.Status),
+		Replaces:     p.Replaces,
+		ReplacedBy:   p.ReplacedBy,
+	}
+}
+
+func passportFromProto(p *passportv1.Passport) models.Passport {
+	return models.Passport{
+		ID:           p.GetId(),
+		DateOfIssue:  dateFromProto(p.GetDateOfIssue()),
+		DateOfExpiry: dateFromProto(p.GetDateOfExpiry()),
+		Authority:    p.GetAuthority(),
+		UserID:       int(p.GetUserId()),
+	}
+}
+
+// dateToProto sends a date as the start of its day in UTC.
+func dateToProto(d civil.Date) *timestamppb.Timestamp {
+	return timestamppb.New(d.In(time.UTC))
+}
+
+// dateFromProto returns the date of a timestamp in UTC, or the zero date for
+// a missing timestamp, so that the validation rules report it as required.
+func dateFromProto(ts *timestamppb.Timestamp) civil.Date {
+	if ts == nil {
+		return civil.Date{}
+	}
+	return civil.DateOf(ts.AsTime())
+}
+
+// parseListQuery builds a query from a filter in the REST query syntax and
+// a sort expression.
+func parseListQuery(filter, sort string, schema query.Schema) (query.Query, error) {
+	values, err := url.ParseQuery(filter)
+	if err != nil {
+		return query.Query{}, grpcError(status.CodeInvalidQuery, "malformed filter")
+	}
+	if sort = strings.TrimSpace(sort); sort != "" {
+		values.Set("sort", sort)
+	}
+	q, err := query.Parse(values, schema)
+	if err != nil {
+		return query.Query{}, grpcError(status.CodeInvalidQuery, err.Error())
+	}
+	return q, nil
+}
+
+// --- Users ---
+
+// grpcUserServer implements passportv1.UserServiceServer on top of the
+// server's user store.
+type grpcUserServer struct {
+	passportv1.UnimplementedUserServiceServer
+	s *Server
+}
+
+func (g *grpcUserServer) ListUsers(ctx context.Context, req *passportv1.ListUsersRequest) (*passportv1.ListUsersResponse, error) {
+	q, err := parseListQuery(req.GetFilter(), req.GetSort(), userSchema)
+	if err != nil {
+		return nil, err
+	}
+	list, err := g.s.userStore.ListUsers(ctx, q)
+	if err != nil {
+		g.s.logger.Error("failed to list users", "error", err)
+		return nil, grpcError(status.CodeInternal, "failed to list users")
+	}
+	offset, limit := clampPagination(int(req.GetOffset()), int(req.GetLimit()))
+	resp := &passportv1.ListUsersResponse{
+		Total:  int32(len(list)),
+		Offset: int32(offset),
+		Limit:  int32(limit),
+	}
+	for _, u := range paginate(list, offset, limit) {
+		resp.Users = append(resp.Users, userToProto(u))
+	}
+	return resp, nil
+}
+
+func (g *grpcUserServer) GetUser(ctx context.Context, req *passportv1.GetUserRequest) (*passportv1.User, error) {
+	u, err := g.s.userStore.GetUser(ctx, int(req.GetId()))
+	if err != nil {
+		return nil, grpcError(status.CodeUserNotFound, "can't find user")
+	}
+	return userToProto(u), nil
+}
+
+func (g *grpcUserServer) CreateUser(ctx context.Context, req *passportv1.CreateUserRequest) (*passportv1.User, error) {
+	u := userFromProto(req.GetUser())
+	if errs := validate.Struct(u); len(errs) > 0 {
+		return nil, grpcError(status.CodeValidationFailed, "validation failed", errs...)
+	}
+	u.ID = -1 // will be assigned by store
+	user, err := g.s.userStore.AddUser(ctx, u)
+	if err != nil {
+		g.s.logger.Error("failed to create user", "error", err)
+		return nil, grpcError(status.CodeInternal, "something went wrong")
+	}
+	return userToProto(user), nil
+}
+
+func (g *grpcUserServer) UpdateUser(ctx context.Context, req *passportv1.UpdateUserRequest) (*passportv1.User, error) {
+	u := userFromProto(req.GetUser())
+	if errs := validate.Struct(u); len(errs) > 0 {
+		return nil, grpcError(status.CodeValidationFailed, "validation failed", errs...)
+	}
+	user, err := g.s.userStore.UpdateUser(ctx, u)
+	if err != nil {
+		return nil, grpcError(status.CodeUserNotFound, "can't find user")
+	}
+	return userToProto(user), nil
+}
+
+func (g *grpcUserServer) DeleteUser(ctx context.Context, req *passportv1.DeleteUserRequest) (*passportv1.DeleteUserResponse, error) {
+	if err := g.s.userStore.DeleteUser(ctx, int(req.GetId())); err != nil {
+		return nil, grpcError(status.CodeUserNotFound, "can't find user")
+	}
+	return &passportv1.DeleteUserResponse{}, nil
+}
+
+// --- Passports ---
+
+// grpcPassportServer implements passportv1.PassportServiceServer on top of
+// the server's passport store.
+type grpcPassportServer struct {
+	passportv1.UnimplementedPassportServiceServer
+	s *Server
+}
+
+func (g *grpcPassportServer) ListPassports(ctx context.Context, req *passportv1.ListPassportsRequest) (*passportv1.ListPassportsResponse, error) {
+	q, err := parseListQuery(req.GetFilter(), req.GetSort(), passportSchema)
+	if err != nil {
+		return nil, err
+	}
+	list, err := g.s.passportStore.ListPassportsByUser(ctx, int(req.GetUserId()), q)
+	if err != nil {
+		g.s.logger.Error("failed to list passports", "userId", req.GetUserId(), "error", err)
+		return nil, grpcError(status.CodeInternal, "failed to list passports")
+	}
+	offset, limit := clampPagination(int(req.GetOffset()), int(req.GetLimit()))
+	resp := &passportv1.ListPassportsResponse{
+		Total:  int32(len(list)),
+		Offset: int32(offset),
+		Limit:  int32(limit),
+	}
+	for _, p := range paginate(list, offset, limit) {
+		resp.Passports = append(resp.Passports, passportToProto(p))
+	}
+	return resp, nil
+}
+
+func (g *grpcPassportServer) GetPassport(ctx context.Context, req *passportv1.GetPassportRequest) (*passportv1.Passport, error) {
+	p, err := g.s.passportStore.GetPassport(ctx, req.GetId())
+	if err != nil {
+		return nil, grpcError(status.CodePassportNotFound, "can't find passport")
+	}
+	return passportToProto(p), nil
+}
+
+func (g *grpcPassportServer) CreatePassport(ctx context.Context, req *passportv1.CreatePassportRequest) (*passportv1.Passport, error) {
+	p := passportFromProto(req.GetPassport())
+	if errs := validate.Struct(p); len(errs) > 0 {
+		return nil, grpcError(status.CodeValidationFailed, "validation failed", errs...)
+	}
+	passport, err := g.s.passportStore.AddPassport(ctx, p)
+	if code, ok := passportConflict(err); ok {
+		return nil, grpcError(code, err.Error())
+	}
+	if err != nil {
+		g.s.logger.Error("failed to create passport", "error", err)
+		return nil, grpcError(status.CodeInternal, "something went wrong")
+	}
+	return passportToProto(passport), nil
+}
+
+func (g *grpcPassportServer) UpdatePassport(ctx context.Context, req *passportv1.UpdatePassportRequest) (*passportv1.Passport, error) {
+	p := passportFromProto(req.GetPassport())
+	if errs := validate.Struct(p); len(errs) > 0 {
+		return nil, grpcError(status.CodeValidationFailed, "validation failed", errs...)
+	}
+	if req.GetPassport().UserId == nil {
+		if err := g.s.keepOwner(ctx, &p); err != nil {
+			return nil, grpcError(status.CodePassportNotFound, "can't find passport")
+		}
+	}
+	passport, err := g.s.passportStore.UpdatePassport(ctx, p)
+	if code, ok := passportConflict(err); ok {
+		return nil, grpcError(code, err.Error())
+	}
+	if err != nil {
+		return nil, grpcError(status.CodePassportNotFound, "can't find passport")
+	}
+	return passportToProto(passport), nil
+}
+
+func (g *grpcPassportServer) DeletePassport(ctx context.Context, req *passportv1.DeletePassportRequest) (*passportv1.DeletePassportResponse, error) {
+	err := g.s.deletePassport(ctx, req.GetId())
+	if errors.Is(err, errPassportNotFound) {
+		return nil, grpcError(status.CodePassportNotFound, "can't find passport")
+	}
+	if err != nil {
+		g.s.logger.Error("failed to delete passport", "error", err)
+		return nil, grpcError(status.CodeInternal, "something went wrong")
+	}
+	return &passportv1.DeletePassportResponse{}, nil
+}
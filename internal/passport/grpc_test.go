@@ -0,0 +1,182 @@
+package passport
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	passportv1 "github.com/leeprovoost/go-rest-api-template/pkg/pb/passport/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	grpcstatus "google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// newTestGRPCClient serves srv's gRPC API over an in-memory listener.
+func newTestGRPCClient(t *testing.T, srv *Server) *grpc.ClientConn {
+	t.Helper()
+	lis := bufconn.Listen(1 << 20)
+	g := srv.newGRPCServer()
+	go func() { _ = g.Serve(lis) }()
+	t.Cleanup(g.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+	return conn
+}
+
+func date(t *testing.T, s string) *timestamppb.Timestamp {
+	t.Helper()
+	d, err := time.Parse(time.DateOnly, s)
+	require.NoError(t, err)
+	return timestamppb.New(d)
+}
+
+func TestGRPCUserCRUD(t *testing.T) {
+	srv := NewTestServer()
+	users := passportv1.NewUserServiceClient(newTestGRPCClient(t, srv))
+	ctx := context.Background()
+
+	created, err := users.CreateUser(ctx, &passportv1.CreateUserRequest{User: &passportv1.User{
+		FirstName:       "Apple",
+		LastName:        "Jack",
+		DateOfBirth:     date(t, "1972-03-07"),
+		LocationOfBirth: "Cambridge",
+	}})
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), created.GetId())
+
+	got, err := users.GetUser(ctx, &passportv1.GetUserRequest{Id: created.GetId()})
+	require.NoError(t, err)
+	assert.Equal(t, "Jack", got.GetLastName())
+	assert.Equal(t, "1972-03-07", got.GetDateOfBirth().AsTime().Format(time.DateOnly))
+
+	got.LocationOfBirth = "Oxford"
+	updated, err := users.UpdateUser(ctx, &passportv1.UpdateUserRequest{User: got})
+	require.NoError(t, err)
+	assert.Equal(t, "Oxford", updated.GetLocationOfBirth())
+
+	// Mutations go through the indexed store, so search sees them.
+	assert.Equal(t, 1, doSearch(t, srv.middleware(srv.routes()), "oxford").Total)
+
+	_, err = users.DeleteUser(ctx, &passportv1.DeleteUserRequest{Id: created.GetId()})
+	require.NoError(t, err)
+	_, err = users.GetUser(ctx, &passportv1.GetUserRequest{Id: created.GetId()})
+	assert.Equal(t, codes.NotFound, grpcstatus.Code(err))
+}
+
+func TestGRPCListUsers(t *testing.T) {
+	users := passportv1.NewUserServiceClient(newTestGRPCClient(t, NewTestServer()))
+
+	resp, err := users.ListUsers(context.Background(), &passportv1.ListUsersRequest{
+		Filter: "lastName=Doe",
+		Sort:   "-id",
+		Limit:  1,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, int32(2), resp.GetTotal())
+	assert.Equal(t, int32(1), resp.GetLimit())
+	require.Len(t, resp.GetUsers(), 1)
+	assert.Equal(t, "Jane", resp.GetUsers()[0].GetFirstName())
+
+	_, err = users.ListUsers(context.Background(), &passportv1.ListUsersRequest{Filter: "shoeSize=9"})
+	assert.Equal(t, codes.InvalidArgument, grpcstatus.Code(err))
+}
+
+func TestGRPCPassports(t *testing.T) {
+	passports := passportv1.NewPassportServiceClient(newTestGRPCClient(t, NewTestServer()))
+	ctx := context.Background()
+
+	list, err := passports.ListPassports(ctx, &passportv1.ListPassportsRequest{UserId: 1})
+	require.NoError(t, err)
+	require.Len(t, list.GetPassports(), 1)
+	assert.Equal(t, "987654321", list.GetPassports()[0].GetId())
+
+	p := &passportv1.Passport{
+		Id:           "PA1111111",
+		DateOfIssue:  date(t, "2020-01-15"),
+		DateOfExpiry: date(t, "2030-01-15"),
+		Authority:    "DFA",
+		UserId:       proto.Int64(1),
+	}
+	_, err = passports.CreatePassport(ctx, &passportv1.CreatePassportRequest{Passport: p})
+	require.NoError(t, err)
+	_, err = passports.CreatePassport(ctx, &passportv1.CreatePassportRequest{Passport: p})
+	assert.Equal(t, codes.AlreadyExists, grpcstatus.Code(err))
+
+	// Updates that leave out the owner keep it.
+	p.UserId = nil
+	p.DateOfExpiry = date(t, "2029-01-15")
+	updated, err := passports.UpdatePassport(ctx, &passportv1.UpdatePassportRequest{Passport: p})
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), updated.GetUserId())
+
+	// Naming one moves the passport, even to user 0.
+	p.UserId = proto.Int64(0)
+	updated, err = passports.UpdatePassport(ctx, &passportv1.UpdatePassportRequest{Passport: p})
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), updated.GetUserId())
+
+	_, err = passports.DeletePassport(ctx, &passportv1.DeletePassportRequest{Id: "PA1111111"})
+	require.NoError(t, err)
+	_, err = passports.GetPassport(ctx, &passportv1.GetPassportRequest{Id: "PA1111111"})
+	assert.Equal(t, codes.NotFound, grpcstatus.Code(err))
+}
+
+func TestGRPCValidationError(t *testing.T) {
+	users := passportv1.NewUserServiceClient(newTestGRPCClient(t, NewTestServer()))
+
+	_, err := users.CreateUser(context.Background(), &passportv1.CreateUserRequest{User: &passportv1.User{FirstName: "Apple"}})
+	st := grpcstatus.Convert(err)
+	assert.Equal(t, codes.InvalidArgument, st.Code())
+
+	var reason string
+	violations := map[string]string{}
+	for _, d := range st.Details() {
+		switch d := d.(type) {
+		case *errdetails.ErrorInfo:
+			reason = d.GetReason()
+		case *errdetails.BadRequest:
+			for _, v := range d.GetFieldViolations() {
+				violations[v.GetField()] = v.GetDescription()
+			}
+		}
+	}
+	assert.Equal(t, "VALIDATION_FAILED", reason)
+	assert.Equal(t, "lastName is required", violations["lastName"])
+}
+
+func TestGRPCRequestID(t *testing.T) {
+	users := passportv1.NewUserServiceClient(newTestGRPCClient(t, NewTestServer()))
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "x-request-id", "abc-123")
+
+	var header metadata.MD
+	_, err := users.GetUser(ctx, &passportv1.GetUserRequest{Id: 0}, grpc.Header(&header))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"abc-123"}, header.Get("x-request-id"))
+}
+
+func TestGRPCRateLimit(t *testing.T) {
+	srv := NewTestServer()
+	srv.rateLimiter = newRateLimiter(1, 1)
+	users := passportv1.NewUserServiceClient(newTestGRPCClient(t, srv))
+
+	_, err := users.GetUser(context.Background(), &passportv1.GetUserRequest{Id: 0})
+	require.NoError(t, err)
+	_, err = users.GetUser(context.Background(), &passportv1.GetUserRequest{Id: 0})
+	assert.Equal(t, codes.ResourceExhausted, grpcstatus.Code(err))
+}
@@ -0,0 +1,169 @@
+package passport
+
+import (
+	"encoding/json"
+	"mime"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/leeprovoost/go-rest-api-template/internal/passport/models"
+)
+
+// halMediaType is the media type of the HAL representation. Clients opt in
+// by sending it in the Accept header.
+const halMediaType = "application/hal+json"
+
+// wantsHAL reports whether the client asked for the HAL representation.
+func wantsHAL(r *http.Request) bool {
+	for _, accept := range r.Header.Values("Accept") {
+		for _, part := range strings.Split(accept, ",") {
+			mt, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+			if err != nil || mt != halMediaType {
+				continue
+			}
+			if q, err := strconv.ParseFloat(params["q"], 64); err == nil && q == 0 {
+				continue
+			}
+			return true
+		}
+	}
+	return false
+}
+
+// variesByAccept wraps a route that renders HAL or plain JSON depending on
+// the Accept header. Every response of the route, plain JSON and errors
+// included, carries Vary: Accept, so that shared caches keep the two apart.
+func variesByAccept(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Accept")
+		next(w, r)
+	}
+}
+
+// respondHAL writes a HAL response with the given status code. Its route is
+// wrapped in variesByAccept.
+func respondHAL(w http.ResponseWriter, code int, data any) {
+	w.Header().Set("Content-Type", halMediaType)
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(data)
+}
+
+// link is a HAL link object.
+type link struct {
+	Href string `json:"href"`
+}
+
+func userLinks(v *apiVersion, u models.User) map[string]link {
+	self := v.prefix + "/users/" + strconv.Itoa(u.ID)
+	return map[string]link{
+		"self":       {Href: self},
+		"passports":  {Href: self + "/passports"},
+		"duplicates": {Href: self + "/duplicates"},
+	}
+}
+
+func passportLinks(v *apiVersion, p models.Passport) map[string]link {
+	self := v.prefix + "/passports/" + url.PathEscape(p.ID)
+	links := map[string]link{
+		"self":        {Href: self},
+		"owner":       {Href: v.prefix + "/users/" + strconv.Itoa(p.UserID)},
+		"transitions": {Href: self + "/transitions"},
+		"visas":       {Href: self + "/visas"},
+	}
+	if p.Replaces != "" {
+		links["replaces"] = link{Href: v.prefix + "/passports/" + url.PathEscape(p.Replaces)}
+	}
+	if p.ReplacedBy != "" {
+		links["replacedBy"] = link{Href: v.prefix + "/passports/" + url.PathEscape(p.ReplacedBy)}
+	}
+	return links
+}
+
+func visaLinks(v *apiVersion, visa models.Visa) map[string]link {
+	passport := v.prefix + "/passports/" + url.PathEscape(visa.PassportID)
+	return map[string]link{
+		"self":     {Href: passport + "/visas/" + strconv.Itoa(visa.ID)},
+		"passport": {Href: passport},
+	}
+}
+
+// halObject converts v to a JSON object so that _links and _embedded can be
+// added alongside its fields.
+func halObject(v any, links map[string]link) map[string]any {
+	obj := make(map[string]any)
+	if dat, err := json.Marshal(v); err == nil {
+		var fields map[string]json.RawMessage
+		if json.Unmarshal(dat, &fields) == nil {
+			for k, val := range fields {
+				obj[k] = val
+			}
+		}
+	}
+	obj["_links"] = links
+	return obj
+}
+
+// halUser returns the HAL representation of u in version v, restricted to
+// fields. A non-nil passports slice is embedded under _embedded.passports.
+func halUser(v *apiVersion, u models.User, fields []string, passports []models.Passport) map[string]any {
+	obj := halObject(project(v.user(u), fields), userLinks(v, u))
+	if passports != nil {
+		items := make([]any, len(passports))
+		for i, p := range passports {
+			items[i] = halPassport(v, p, nil)
+		}
+		obj["_embedded"] = map[string]any{"passports": items}
+	}
+	return obj
+}
+
+// halPassport returns the HAL representation of p in version v, restricted
+// to fields.
+func halPassport(v *apiVersion, p models.Passport, fields []string) map[string]any {
+	return halObject(project(v.passport(p), fields), passportLinks(v, p))
+}
+
+// halVisa returns the HAL representation of visa in version v, restricted to
+// fields.
+func halVisa(v *apiVersion, visa models.Visa, fields []string) map[string]any {
+	return halObject(project(v.visa(visa), fields), visaLinks(v, visa))
+}
+
+// respondUser writes a single user as HAL or plain JSON depending on the
+// Accept header, in the API version the request was routed to. A non-nil
+// passports slice is embedded in the response.
+func respondUser(w http.ResponseWriter, r *http.Request, code int, u models.User, fields []string, passports []models.Passport) {
+	v := versionOf(r)
+	switch {
+	case wantsHAL(r):
+		respondHAL(w, code, halUser(v, u, fields, passports))
+	case passports != nil:
+		respond(w, code, embedPassports(v, u, fields, passports))
+	default:
+		respond(w, code, project(v.user(u), fields))
+	}
+}
+
+// respondPassport writes a single passport as HAL or plain JSON depending on
+// the Accept header, in the API version the request was routed to.
+func respondPassport(w http.ResponseWriter, r *http.Request, code int, p models.Passport, fields []string) {
+	v := versionOf(r)
+	if wantsHAL(r) {
+		respondHAL(w, code, halPassport(v, p, fields))
+		return
+	}
+	respond(w, code, project(v.passport(p), fields))
+}
+
+// respondVisa writes a single visa as HAL or plain JSON depending on the
+// Accept header, in the API version the request was routed to.
+func respondVisa(w http.ResponseWriter, r *http.Request, code int, visa models.Visa, fields []string) {
+	v := versionOf(r)
+	if wantsHAL(r) {
+		respondHAL(w, code, halVisa(v, visa, fields))
+		return
+	}
+	respond(w, code, project(v.visa(visa), fields))
+}
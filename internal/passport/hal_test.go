@@ -0,0 +1,52 @@
+package passport
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWantsHAL(t *testing.T) {
+	tests := map[string]bool{
+		"":                                      false,
+		"application/json":                      false,
+		"application/hal+json":                  true,
+		"text/html, application/hal+json;q=0.9": true,
+		"application/hal+json;q=0":              false,
+	}
+	for accept, want := range tests {
+		r := httptest.NewRequest("GET", "/users", nil)
+		if accept != "" {
+			r.Header.Set("Accept", accept)
+		}
+		assert.Equal(t, want, wantsHAL(r), "Accept: %q", accept)
+	}
+}
+
+func TestNegotiatedRoutesVaryByAccept(t *testing.T) {
+	handler := newTestHandler()
+
+	for _, target := range []string{"/users", "/v2/users/0", "/passports/012345678", "/users/0/passports", "/passports/012345678/visas/1", "/users/99"} {
+		for _, accept := range []string{"", "application/json", "application/hal+json"} {
+			r := httptest.NewRequest(http.MethodGet, target, nil)
+			if accept != "" {
+				r.Header.Set("Accept", accept)
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+			assert.Equal(t, []string{"Accept"}, w.Header().Values("Vary"), "%s with Accept: %q", target, accept)
+		}
+	}
+
+	// Replayed responses too.
+	for range 2 {
+		w := postWithKey(handler, "/users", "k1", newUserJSON)
+		assert.Equal(t, []string{"Accept"}, w.Header().Values("Vary"))
+	}
+
+	// Routes that don't negotiate don't vary.
+	w, _ := getJSON(t, handler, "/passports/012345678/transitions")
+	assert.Empty(t, w.Header().Values("Vary"))
+}
@@ -1,15 +1,31 @@
 package passport
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
 	"strconv"
 
 	"github.com/leeprovoost/go-rest-api-template/internal/passport/models"
+	"github.com/leeprovoost/go-rest-api-template/pkg/blob"
 	"github.com/leeprovoost/go-rest-api-template/pkg/health"
+	"github.com/leeprovoost/go-rest-api-template/pkg/query"
 	"github.com/leeprovoost/go-rest-api-template/pkg/status"
+	"github.com/leeprovoost/go-rest-api-template/pkg/validate"
 )
 
+// Schemas describe the filterable, sortable and projectable fields of each model.
+var (
+	userSchema     = query.SchemaOf(models.User{})
+	passportSchema = query.SchemaOf(models.Passport{})
+	visaSchema     = query.SchemaOf(models.Visa{})
+)
+
+// listParams are the query parameters on list endpoints that are not filters.
+var listParams = []string{"offset", "limit", "fields", "include"}
+
 // respond writes a JSON response with the given status code.
 func respond(w http.ResponseWriter, code int, data any) {
 	w.Header().Set("Content-Type", "application/json")
@@ -19,6 +35,33 @@ func respond(w http.ResponseWriter, code int, data any) {
 	}
 }
 
+// respondError writes a status.Response for the given error code, using the
+// HTTP status registered for it in the status catalog.
+func respondError(w http.ResponseWriter, code status.Code, message string) {
+	respond(w, code.HTTPStatus(), status.New(code, message))
+}
+
+// respondValidationErrors writes a 422 response listing the validation errors.
+func respondValidationErrors(w http.ResponseWriter, errs validate.Errors) {
+	resp := status.New(status.CodeValidationFailed, "validation failed")
+	resp.Errors = errs.Messages()
+	resp.Fields = errs
+	respond(w, http.StatusUnprocessableEntity, resp)
+}
+
+// passportConflict returns the error code for a passport the store refused
+// because it clashes with another passport, and false for any other error.
+func passportConflict(err error) (status.Code, bool) {
+	switch {
+	case errors.Is(err, models.ErrActivePassportExists):
+		return status.CodePassportActiveExists, true
+	case errors.Is(err, models.ErrPassportExists):
+		return status.CodePassportDuplicate, true
+	default:
+		return "", false
+	}
+}
+
 // --- Health & readiness ---
 
 func (s *Server) handleHealthcheck(w http.ResponseWriter, r *http.Request) {
@@ -32,34 +75,85 @@ func (s *Server) handleReady(w http.ResponseWriter, _ *http.Request) {
 	respond(w, http.StatusOK, map[string]string{"status": "ok"})
 }
 
+// --- Error catalog ---
+
+func (s *Server) handleListErrorCodes(w http.ResponseWriter, _ *http.Request) {
+	codes := status.Catalog()
+	respond(w, http.StatusOK, map[string]any{
+		"errors": codes,
+		"count":  len(codes),
+	})
+}
+
 // --- Users ---
 
 func (s *Server) handleListUsers(w http.ResponseWriter, r *http.Request) {
-	list, err := s.userStore.ListUsers(r.Context())
+	fields, err := parseFields(r, userSchema)
+	if err != nil {
+		respondError(w, status.CodeInvalidFields, err.Error())
+		return
+	}
+	include, err := parseInclude(r, "passports")
+	if err != nil {
+		respondError(w, status.CodeInvalidInclude, err.Error())
+		return
+	}
+	q, err := query.Parse(r.URL.Query(), userSchema, listParams...)
+	if err != nil {
+		respondError(w, status.CodeInvalidQuery, err.Error())
+		return
+	}
+	list, err := s.userStore.ListUsers(r.Context(), q)
 	if err != nil {
 		s.logger.Error("failed to list users", "error", err)
-		respond(w, http.StatusInternalServerError, status.Response{
-			Status:  strconv.Itoa(http.StatusInternalServerError),
-			Message: "failed to list users",
-		})
+		respondError(w, status.CodeInternal, "failed to list users")
 		return
 	}
 
-	// Pagination
 	total := len(list)
 	offset, limit := parsePagination(r)
-	if offset > len(list) {
-		list = []models.User{}
-	} else {
-		end := offset + limit
-		if end > len(list) {
-			end = len(list)
+	list = paginate(list, offset, limit)
+	pg := page{Offset: offset, Limit: limit, Total: total}
+	pg.setHeaders(w, r.URL)
+
+	var passports map[int][]models.Passport
+	if include["passports"] {
+		passports, err = s.loadPassports(r.Context(), list)
+		if err != nil {
+			s.logger.Error("failed to load passports", "error", err)
+			respondError(w, status.CodeInternal, "failed to list passports")
+			return
 		}
-		list = list[offset:end]
+	}
+
+	v := versionOf(r)
+	if wantsHAL(r) {
+		items := make([]any, len(list))
+		for i, u := range list {
+			items[i] = halUser(v, u, fields, passports[u.ID])
+		}
+		respondHAL(w, http.StatusOK, map[string]any{
+			"_links":    pg.links(r.URL),
+			"_embedded": map[string]any{"users": items},
+			"count":     len(list),
+			"total":     total,
+			"offset":    offset,
+			"limit":     limit,
+		})
+		return
+	}
+
+	users := projectAll(mapAll(list, v.user), fields)
+	if passports != nil {
+		embedded := make([]any, len(list))
+		for i, u := range list {
+			embedded[i] = embedPassports(v, u, fields, passports[u.ID])
+		}
+		users = embedded
 	}
 
 	respond(w, http.StatusOK, map[string]any{
-		"users":  list,
+		"users":  users,
 		"count":  len(list),
 		"total":  total,
 		"offset": offset,
@@ -70,92 +164,79 @@ func (s *Server) handleListUsers(w http.ResponseWriter, r *http.Request) {
 func (s *Server) handleGetUser(w http.ResponseWriter, r *http.Request) {
 	uid, err := strconv.Atoi(r.PathValue("id"))
 	if err != nil {
-		respond(w, http.StatusBadRequest, status.Response{
-			Status:  strconv.Itoa(http.StatusBadRequest),
-			Message: "invalid user id",
-		})
+		respondError(w, status.CodeInvalidUserID, "invalid user id")
+		return
+	}
+	fields, err := parseFields(r, userSchema)
+	if err != nil {
+		respondError(w, status.CodeInvalidFields, err.Error())
+		return
+	}
+	include, err := parseInclude(r, "passports")
+	if err != nil {
+		respondError(w, status.CodeInvalidInclude, err.Error())
 		return
 	}
 	user, err := s.userStore.GetUser(r.Context(), uid)
 	if err != nil {
 		s.logger.Error("user not found", "id", uid, "error", err)
-		respond(w, http.StatusNotFound, status.Response{
-			Status:  strconv.Itoa(http.StatusNotFound),
-			Message: "can't find user",
-		})
+		respondError(w, status.CodeUserNotFound, "can't find user")
 		return
 	}
-	respond(w, http.StatusOK, user)
+	var passports []models.Passport
+	if include["passports"] {
+		byUser, err := s.loadPassports(r.Context(), []models.User{user})
+		if err != nil {
+			s.logger.Error("failed to load passports", "userId", uid, "error", err)
+			respondError(w, status.CodeInternal, "failed to list passports")
+			return
+		}
+		passports = byUser[uid]
+	}
+	respondUser(w, r, http.StatusOK, user, fields, passports)
 }
 
 func (s *Server) handleCreateUser(w http.ResponseWriter, r *http.Request) {
 	var u models.User
-	if err := json.NewDecoder(r.Body).Decode(&u); err != nil {
-		s.logger.Error("malformed user object", "error", err)
-		respond(w, http.StatusBadRequest, status.Response{
-			Status:  strconv.Itoa(http.StatusBadRequest),
-			Message: "malformed user object",
-		})
+	if !s.decode(w, r, &u, status.CodeMalformedUser) {
 		return
 	}
-	if errs := validateUser(u); len(errs) > 0 {
-		respond(w, http.StatusUnprocessableEntity, status.Response{
-			Status:  strconv.Itoa(http.StatusUnprocessableEntity),
-			Message: "validation failed",
-			Errors:  errs,
-		})
+	if errs := validate.Struct(u); len(errs) > 0 {
+		respondValidationErrors(w, errs)
 		return
 	}
 	u.ID = -1 // will be assigned by store
 	user, _ := s.userStore.AddUser(r.Context(), u)
-	respond(w, http.StatusCreated, user)
+	respondUser(w, r, http.StatusCreated, user, nil, nil)
 }
 
 func (s *Server) handleUpdateUser(w http.ResponseWriter, r *http.Request) {
 	var u models.User
-	if err := json.NewDecoder(r.Body).Decode(&u); err != nil {
-		s.logger.Error("malformed user object", "error", err)
-		respond(w, http.StatusBadRequest, status.Response{
-			Status:  strconv.Itoa(http.StatusBadRequest),
-			Message: "malformed user object",
-		})
+	if !s.decode(w, r, &u, status.CodeMalformedUser) {
 		return
 	}
-	if errs := validateUser(u); len(errs) > 0 {
-		respond(w, http.StatusUnprocessableEntity, status.Response{
-			Status:  strconv.Itoa(http.StatusUnprocessableEntity),
-			Message: "validation failed",
-			Errors:  errs,
-		})
+	if errs := validate.Struct(u); len(errs) > 0 {
+		respondValidationErrors(w, errs)
 		return
 	}
 	user, err := s.userStore.UpdateUser(r.Context(), u)
 	if err != nil {
 		s.logger.Error("failed to update user", "error", err)
-		respond(w, http.StatusInternalServerError, status.Response{
-			Status:  strconv.Itoa(http.StatusInternalServerError),
-			Message: "something went wrong",
-		})
+		respondError(w, status.CodeInternal, "something went wrong")
 		return
 	}
-	respond(w, http.StatusOK, user)
+	respondUser(w, r, http.StatusOK, user, nil, nil)
 }
 
 func (s *Server) handleDeleteUser(w http.ResponseWriter, r *http.Request) {
 	uid, err := strconv.Atoi(r.PathValue("id"))
 	if err != nil {
-		respond(w, http.StatusBadRequest, status.Response{
-			Status:  strconv.Itoa(http.StatusBadRequest),
-			Message: "invalid user id",
-		})
+		respondError(w, status.CodeInvalidUserID, "invalid user id")
 		return
 	}
 	if err := s.userStore.DeleteUser(r.Context(), uid); err != nil {
 		s.logger.Error("failed to delete user", "error", err)
-		respond(w, http.StatusInternalServerError, status.Response{
-			Status:  strconv.Itoa(http.StatusInternalServerError),
-			Message: "something went wrong",
-		})
+		respondError(w, status.CodeInternal, "something went wrong")
 		return
 	}
 	w.WriteHeader(http.StatusNoContent)
@@ -166,170 +247,213 @@ func (s *Server) handleDeleteUser(w http.ResponseWriter, r *http.Request) {
 func (s *Server) handleListUserPassports(w http.ResponseWriter, r *http.Request) {
 	uid, err := strconv.Atoi(r.PathValue("uid"))
 	if err != nil {
-		respond(w, http.StatusBadRequest, status.Response{
-			Status:  strconv.Itoa(http.StatusBadRequest),
-			Message: "invalid user id",
-		})
+		respondError(w, status.CodeInvalidUserID, "invalid user id")
 		return
 	}
-	passports, err := s.passportStore.ListPassportsByUser(r.Context(), uid)
+	fields, err := parseFields(r, passportSchema)
+	if err != nil {
+		respondError(w, status.CodeInvalidFields, err.Error())
+		return
+	}
+	q, err := query.Parse(r.URL.Query(), passportSchema, listParams...)
+	if err != nil {
+		respondError(w, status.CodeInvalidQuery, err.Error())
+		return
+	}
+	passports, err := s.passportStore.ListPassportsByUser(r.Context(), uid, q)
 	if err != nil {
 		s.logger.Error("failed to list passports", "userId", uid, "error", err)
-		respond(w, http.StatusInternalServerError, status.Response{
-			Status:  strconv.Itoa(http.StatusInternalServerError),
-			Message: "failed to list passports",
+		respondError(w, status.CodeInternal, "failed to list passports")
+		return
+	}
+
+	total := len(passports)
+	offset, limit := parsePagination(r)
+	passports = paginate(passports, offset, limit)
+	pg := page{Offset: offset, Limit: limit, Total: total}
+	pg.setHeaders(w, r.URL)
+
+	v := versionOf(r)
+	if wantsHAL(r) {
+		items := make([]any, len(passports))
+		for i, p := range passports {
+			items[i] = halPassport(v, p, fields)
+		}
+		links := pg.links(r.URL)
+		links["owner"] = link{Href: v.prefix + "/users/" + strconv.Itoa(uid)}
+		respondHAL(w, http.StatusOK, map[string]any{
+			"_links":    links,
+			"_embedded": map[string]any{"passports": items},
+			"count":     len(passports),
+			"total":     total,
+			"offset":    offset,
+			"limit":     limit,
 		})
 		return
 	}
 	respond(w, http.StatusOK, map[string]any{
-		"passports": passports,
+		"passports": projectAll(mapAll(passports, v.passport), fields),
 		"count":     len(passports),
+		"total":     total,
+		"offset":    offset,
+		"limit":     limit,
 	})
 }
 
 func (s *Server) handleGetPassport(w http.ResponseWriter, r *http.Request) {
 	id := r.PathValue("id")
+	fields, err := parseFields(r, passportSchema)
+	if err != nil {
+		respondError(w, status.CodeInvalidFields, err.Error())
+		return
+	}
 	passport, err := s.passportStore.GetPassport(r.Context(), id)
 	if err != nil {
 		s.logger.Error("passport not found", "id", id, "error", err)
-		respond(w, http.StatusNotFound, status.Response{
-			Status:  strconv.Itoa(http.StatusNotFound),
-			Message: "can't find passport",
-		})
+		respondError(w, status.CodePassportNotFound, "can't find passport")
 		return
 	}
-	respond(w, http.StatusOK, passport)
+	respondPassport(w, r, http.StatusOK, passport, fields)
 }
 
 func (s *Server) handleCreatePassport(w http.ResponseWriter, r *http.Request) {
 	uid, err := strconv.Atoi(r.PathValue("uid"))
 	if err != nil {
-		respond(w, http.StatusBadRequest, status.Response{
-			Status:  strconv.Itoa(http.StatusBadRequest),
-			Message: "invalid user id",
-		})
+		respondError(w, status.CodeInvalidUserID, "invalid user id")
 		return
 	}
 	var p models.Passport
-	if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
-		s.logger.Error("malformed passport object", "error", err)
-		respond(w, http.StatusBadRequest, status.Response{
-			Status:  strconv.Itoa(http.StatusBadRequest),
-			Message: "malformed passport object",
-		})
+	if !s.decode(w, r, &p, status.CodeMalformedPassport) {
 		return
 	}
 	p.UserID = uid
-	if errs := validatePassport(p); len(errs) > 0 {
-		respond(w, http.StatusUnprocessableEntity, status.Response{
-			Status:  strconv.Itoa(http.StatusUnprocessableEntity),
-			Message: "validation failed",
-			Errors:  errs,
-		})
+	// New passports are issued. Their status and links only change through
+	// transitions and renewals.
+	p.Status, p.Replaces, p.ReplacedBy = "", "", ""
+	if errs := validate.Struct(p); len(errs) > 0 {
+		respondValidationErrors(w, errs)
 		return
 	}
 	passport, err := s.passportStore.AddPassport(r.Context(), p)
+	if code, ok := passportConflict(err); ok {
+		respondError(w, code, err.Error())
+		return
+	}
 	if err != nil {
 		s.logger.Error("failed to create passport", "error", err)
-		respond(w, http.StatusConflict, status.Response{
-			Status:  strconv.Itoa(http.StatusConflict),
-			Message: err.Error(),
-		})
+		respondError(w, status.CodeInternal, "something went wrong")
 		return
 	}
-	respond(w, http.StatusCreated, passport)
+	respondPassport(w, r, http.StatusCreated, passport, nil)
+}
+
+// passportUpdate is the body of PUT /passports/{id}. UserID shadows the
+// passport's own field so that a missing userId can be told apart from 0.
+type passportUpdate struct {
+	models.Passport
+	UserID *int `json:"userId"`
 }
 
 func (s *Server) handleUpdatePassport(w http.ResponseWriter, r *http.Request) {
-	var p models.Passport
-	if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
-		s.logger.Error("malformed passport object", "error", err)
-		respond(w, http.StatusBadRequest, status.Response{
-			Status:  strconv.Itoa(http.StatusBadRequest),
-			Message: "malformed passport object",
-		})
+	var in passportUpdate
+	if !s.decode(w, r, &in, status.CodeMalformedPassport) {
 		return
 	}
+	p := in.Passport
 	p.ID = r.PathValue("id")
-	if errs := validatePassport(p); len(errs) > 0 {
-		respond(w, http.StatusUnprocessableEntity, status.Response{
-			Status:  strconv.Itoa(http.StatusUnprocessableEntity),
-			Message: "validation failed",
-			Errors:  errs,
-		})
+	if errs := validate.Struct(p); len(errs) > 0 {
+		respondValidationErrors(w, errs)
+		return
+	}
+	if in.UserID != nil {
+		p.UserID = *in.UserID
+	} else if err := s.keepOwner(r.Context(), &p); err != nil {
+		s.logger.Error("failed to update passport", "error", err)
+		respondError(w, status.CodeInternal, "something went wrong")
 		return
 	}
 	passport, err := s.passportStore.UpdatePassport(r.Context(), p)
+	if code, ok := passportConflict(err); ok {
+		respondError(w, code, err.Error())
+		return
+	}
 	if err != nil {
 		s.logger.Error("failed to update passport", "error", err)
-		respond(w, http.StatusInternalServerError, status.Response{
-			Status:  strconv.Itoa(http.StatusInternalServerError),
-			Message: "something went wrong",
-		})
+		respondError(w, status.CodeInternal, "something went wrong")
 		return
 	}
-	respond(w, http.StatusOK, passport)
+	respondPassport(w, r, http.StatusOK, passport, nil)
+}
+
+// keepOwner sets the owner of p to that of the stored passport with the same
+// ID. Updates that leave out the owner keep it, on every API.
+func (s *Server) keepOwner(ctx context.Context, p *models.Passport) error {
+	current, err := s.passportStore.GetPassport(ctx, p.ID)
+	if err != nil {
+		return fmt.Errorf("%w: %w", errPassportNotFound, err)
+	}
+	p.UserID = current.UserID
+	return nil
 }
 
 func (s *Server) handleDeletePassport(w http.ResponseWriter, r *http.Request) {
 	id := r.PathValue("id")
-	if err := s.passportStore.DeletePassport(r.Context(), id); err != nil {
+	if err := s.deletePassport(r.Context(), id); err != nil {
 		s.logger.Error("failed to delete passport", "error", err)
-		respond(w, http.StatusInternalServerError, status.Response{
-			Status:  strconv.Itoa(http.StatusInternalServerError),
-			Message: "something went wrong",
-		})
+		respondError(w, status.CodeInternal, "something went wrong")
 		return
 	}
 	w.WriteHeader(http.StatusNoContent)
 }
 
-// --- Validation ---
-
-func validateUser(u models.User) []string {
-	var errs []string
-	if u.FirstName == "" {
-		errs = append(errs, "firstName is required")
-	}
-	if u.LastName == "" {
-		errs = append(errs, "lastName is required")
-	}
-	if u.DateOfBirth.IsZero() {
-		errs = append(errs, "dateOfBirth is required")
-	}
-	if u.LocationOfBirth == "" {
-		errs = append(errs, "locationOfBirth is required")
-	}
-	return errs
-}
+// errPassportNotFound is returned by deletePassport for an unknown passport.
+var errPassportNotFound = errors.New("passport not found")
 
-func validatePassport(p models.Passport) []string {
-	var errs []string
-	if p.ID == "" {
-		errs = append(errs, "id is required")
-	}
-	if p.DateOfIssue.IsZero() {
-		errs = append(errs, "dateOfIssue is required")
+// deletePassport deletes a passport with its visas and attachments. Passport
+// numbers are chosen by clients, so anything left behind would reappear on a
+// new passport with the same number. The passport goes last, so a failure
+// can be retried. The blob store can't roll back, so inside a transaction
+// the attachments are only deleted once it commits.
+func (s *Server) deletePassport(ctx context.Context, id string) error {
+	if _, err := s.passportStore.GetPassport(ctx, id); err != nil {
+		return fmt.Errorf("%w: %w", errPassportNotFound, err)
+	}
+	if txFrom(ctx) == nil {
+		if err := s.deleteAttachments(ctx, id); err != nil {
+			return err
+		}
+	} else {
+		afterEnd(ctx, func(committed bool) {
+			if !committed {
+				return
+			}
+			if err := s.deleteAttachments(context.WithoutCancel(ctx), id); err != nil {
+				s.logger.Error("failed to delete attachments of deleted passport", "passportId", id, "error", err)
+			}
+		})
 	}
-	if p.DateOfExpiry.IsZero() {
-		errs = append(errs, "dateOfExpiry is required")
+	visas, err := s.visaStore.ListVisasByPassport(ctx, id, query.Query{})
+	if err != nil {
+		return fmt.Errorf("listing visas: %w", err)
 	}
-	if p.Authority == "" {
-		errs = append(errs, "authority is required")
+	for _, v := range visas {
+		if err := s.visaStore.DeleteVisa(ctx, v.ID); err != nil {
+			return fmt.Errorf("deleting visa %d: %w", v.ID, err)
+		}
 	}
-	return errs
+	return s.passportStore.DeletePassport(ctx, id)
 }
 
-// --- Helpers ---
-
-func parsePagination(r *http.Request) (offset, limit int) {
-	offset, _ = strconv.Atoi(r.URL.Query().Get("offset"))
-	limit, _ = strconv.Atoi(r.URL.Query().Get("limit"))
-	if offset < 0 {
-		offset = 0
+// deleteAttachments deletes the attachments of a passport.
+func (s *Server) deleteAttachments(ctx context.Context, passportID string) error {
+	infos, err := s.blobs.List(ctx, attachmentKey(passportID, ""))
+	if err != nil {
+		return fmt.Errorf("listing attachments: %w", err)
 	}
-	if limit <= 0 || limit > 100 {
-		limit = 25
+	for _, info := range infos {
+		if err := s.blobs.Delete(ctx, info.Key); err != nil && !errors.Is(err, blob.ErrNotFound) {
+			return fmt.Errorf("deleting attachment %s: %w", info.Key, err)
+		}
 	}
-	return offset, limit
+	return nil
 }
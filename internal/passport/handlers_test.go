@@ -1,12 +1,19 @@
 package passport
 
 import (
+	"context"
 	"encoding/json"
+	"log/slog"
 	"net/http"
 	"net/http/httptest"
+	"strconv"
 	"strings"
 	"testing"
 
+	"github.com/leeprovoost/go-rest-api-template/internal/passport/models"
+	"github.com/leeprovoost/go-rest-api-template/pkg/query"
+	"github.com/leeprovoost/go-rest-api-template/pkg/status"
+	"github.com/leeprovoost/go-rest-api-template/pkg/validate"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -47,6 +54,28 @@ func TestReadyHandler(t *testing.T) {
 	assert.Equal(t, "ok", body["status"])
 }
 
+// --- Error catalog ---
+
+func TestListErrorCodesHandler(t *testing.T) {
+	handler := newTestHandler()
+	r := httptest.NewRequest(http.MethodGet, "/errors", nil)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var body struct {
+		Errors []status.CodeInfo `json:"errors"`
+		Count  int               `json:"count"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	assert.Equal(t, len(status.Catalog()), body.Count)
+	assert.Contains(t, body.Errors, status.CodeInfo{
+		Code:        status.CodeUserNotFound,
+		Status:      http.StatusNotFound,
+		Description: "No user exists with the given ID.",
+	})
+}
+
 // --- Users ---
 
 func TestListUsersHandler(t *testing.T) {
@@ -102,12 +131,16 @@ func TestGetUserNotFound(t *testing.T) {
 	handler.ServeHTTP(w, r)
 
 	assert.Equal(t, http.StatusNotFound, w.Code)
+	var resp map[string]any
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, "USER_NOT_FOUND", resp["code"])
 }
 
 func TestCreateUserHandler(t *testing.T) {
 	handler := newTestHandler()
 	body := `{"firstName":"Apple","lastName":"Jack","dateOfBirth":"1972-03-07T00:00:00Z","locationOfBirth":"Cambridge"}`
 	r := httptest.NewRequest(http.MethodPost, "/users", strings.NewReader(body))
+	r.Header.Set("Content-Type", "application/json")
 	w := httptest.NewRecorder()
 	handler.ServeHTTP(w, r)
 
@@ -122,6 +155,7 @@ func TestCreateUserValidationFails(t *testing.T) {
 	handler := newTestHandler()
 	body := `{"firstName":"","lastName":"","dateOfBirth":"0001-01-01T00:00:00Z","locationOfBirth":""}`
 	r := httptest.NewRequest(http.MethodPost, "/users", strings.NewReader(body))
+	r.Header.Set("Content-Type", "application/json")
 	w := httptest.NewRecorder()
 	handler.ServeHTTP(w, r)
 
@@ -129,6 +163,7 @@ func TestCreateUserValidationFails(t *testing.T) {
 	var resp map[string]any
 	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
 	assert.Equal(t, "validation failed", resp["message"])
+	assert.Equal(t, "VALIDATION_FAILED", resp["code"])
 	errors := resp["errors"].([]any)
 	assert.Len(t, errors, 4)
 }
@@ -148,19 +183,22 @@ func TestGetUserInvalidID(t *testing.T) {
 func TestCreateUserMalformedJSON(t *testing.T) {
 	handler := newTestHandler()
 	r := httptest.NewRequest(http.MethodPost, "/users", strings.NewReader(`{bad json`))
+	r.Header.Set("Content-Type", "application/json")
 	w := httptest.NewRecorder()
 	handler.ServeHTTP(w, r)
 
 	assert.Equal(t, http.StatusBadRequest, w.Code)
 	var resp map[string]any
 	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
-	assert.Equal(t, "malformed user object", resp["message"])
+	assert.Equal(t, "MALFORMED_USER", resp["code"])
+	assert.Equal(t, "invalid JSON at line 1, column 2: invalid character 'b' looking for beginning of object key string", resp["message"])
 }
 
 func TestUpdateUserHandler(t *testing.T) {
 	handler := newTestHandler()
 	body := `{"id":0,"firstName":"John","lastName":"Updated","dateOfBirth":"1985-12-31T00:00:00Z","locationOfBirth":"Manchester"}`
 	r := httptest.NewRequest(http.MethodPut, "/users/0", strings.NewReader(body))
+	r.Header.Set("Content-Type", "application/json")
 	w := httptest.NewRecorder()
 	handler.ServeHTTP(w, r)
 
@@ -174,6 +212,7 @@ func TestUpdateUserHandler(t *testing.T) {
 func TestUpdateUserMalformedJSON(t *testing.T) {
 	handler := newTestHandler()
 	r := httptest.NewRequest(http.MethodPut, "/users/0", strings.NewReader(`not json`))
+	r.Header.Set("Content-Type", "application/json")
 	w := httptest.NewRecorder()
 	handler.ServeHTTP(w, r)
 
@@ -184,6 +223,7 @@ func TestUpdateUserValidationFails(t *testing.T) {
 	handler := newTestHandler()
 	body := `{"firstName":"","lastName":"","dateOfBirth":"0001-01-01T00:00:00Z","locationOfBirth":""}`
 	r := httptest.NewRequest(http.MethodPut, "/users/0", strings.NewReader(body))
+	r.Header.Set("Content-Type", "application/json")
 	w := httptest.NewRecorder()
 	handler.ServeHTTP(w, r)
 
@@ -198,6 +238,7 @@ func TestUpdateUserStoreError(t *testing.T) {
 	handler := newTestHandler()
 	body := `{"id":999,"firstName":"Ghost","lastName":"User","dateOfBirth":"1990-01-01T00:00:00Z","locationOfBirth":"Nowhere"}`
 	r := httptest.NewRequest(http.MethodPut, "/users/999", strings.NewReader(body))
+	r.Header.Set("Content-Type", "application/json")
 	w := httptest.NewRecorder()
 	handler.ServeHTTP(w, r)
 
@@ -295,15 +336,16 @@ func TestGetPassportNotFound(t *testing.T) {
 
 func TestCreatePassportHandler(t *testing.T) {
 	handler := newTestHandler()
-	body := `{"id":"111222333","dateOfIssue":"2024-01-01T00:00:00Z","dateOfExpiry":"2034-01-01T00:00:00Z","authority":"HMPO"}`
+	body := `{"id":"PA1234567","dateOfIssue":"2024-01-01T00:00:00Z","dateOfExpiry":"2034-01-01T00:00:00Z","authority":"DFA"}`
 	r := httptest.NewRequest(http.MethodPost, "/users/0/passports", strings.NewReader(body))
+	r.Header.Set("Content-Type", "application/json")
 	w := httptest.NewRecorder()
 	handler.ServeHTTP(w, r)
 
 	assert.Equal(t, http.StatusCreated, w.Code)
 	var passport map[string]any
 	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &passport))
-	assert.Equal(t, "111222333", passport["id"])
+	assert.Equal(t, "PA1234567", passport["id"])
 	assert.Equal(t, float64(0), passport["userId"])
 }
 
@@ -320,6 +362,7 @@ func TestCreatePassportInvalidUserID(t *testing.T) {
 	handler := newTestHandler()
 	body := `{"id":"999888777","dateOfIssue":"2024-01-01T00:00:00Z","dateOfExpiry":"2034-01-01T00:00:00Z","authority":"HMPO"}`
 	r := httptest.NewRequest(http.MethodPost, "/users/abc/passports", strings.NewReader(body))
+	r.Header.Set("Content-Type", "application/json")
 	w := httptest.NewRecorder()
 	handler.ServeHTTP(w, r)
 
@@ -329,6 +372,7 @@ func TestCreatePassportInvalidUserID(t *testing.T) {
 func TestCreatePassportMalformedJSON(t *testing.T) {
 	handler := newTestHandler()
 	r := httptest.NewRequest(http.MethodPost, "/users/0/passports", strings.NewReader(`{bad`))
+	r.Header.Set("Content-Type", "application/json")
 	w := httptest.NewRecorder()
 	handler.ServeHTTP(w, r)
 
@@ -339,6 +383,7 @@ func TestCreatePassportValidationFails(t *testing.T) {
 	handler := newTestHandler()
 	body := `{"id":"","dateOfIssue":"0001-01-01T00:00:00Z","dateOfExpiry":"0001-01-01T00:00:00Z","authority":""}`
 	r := httptest.NewRequest(http.MethodPost, "/users/0/passports", strings.NewReader(body))
+	r.Header.Set("Content-Type", "application/json")
 	w := httptest.NewRecorder()
 	handler.ServeHTTP(w, r)
 
@@ -353,16 +398,89 @@ func TestCreatePassportDuplicate(t *testing.T) {
 	handler := newTestHandler()
 	body := `{"id":"012345678","dateOfIssue":"2024-01-01T00:00:00Z","dateOfExpiry":"2034-01-01T00:00:00Z","authority":"HMPO"}`
 	r := httptest.NewRequest(http.MethodPost, "/users/0/passports", strings.NewReader(body))
+	r.Header.Set("Content-Type", "application/json")
 	w := httptest.NewRecorder()
 	handler.ServeHTTP(w, r)
 
 	assert.Equal(t, http.StatusConflict, w.Code)
+	var resp map[string]any
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, "PASSPORT_DUPLICATE", resp["code"])
+}
+
+func TestCreatePassportDomainRules(t *testing.T) {
+	tests := []struct {
+		name  string
+		body  string
+		field validate.Error
+	}{
+		{
+			name:  "expiry before issue",
+			body:  `{"id":"111222333","dateOfIssue":"2024-01-01T00:00:00Z","dateOfExpiry":"2023-01-01T00:00:00Z","authority":"IPS"}`,
+			field: validate.Error{Pointer: "/dateOfExpiry", Rule: "after", Message: "dateOfExpiry must be after dateOfIssue"},
+		},
+		{
+			name:  "issued in the future",
+			body:  `{"id":"111222333","dateOfIssue":"2999-01-01T00:00:00Z","dateOfExpiry":"3009-01-01T00:00:00Z","authority":"IPS"}`,
+			field: validate.Error{Pointer: "/dateOfIssue", Rule: "past", Message: "dateOfIssue must be in the past"},
+		},
+		{
+			name:  "validity too long",
+			body:  `{"id":"111222333","dateOfIssue":"2024-01-01T00:00:00Z","dateOfExpiry":"2034-01-02T00:00:00Z","authority":"IPS"}`,
+			field: validate.Error{Pointer: "/dateOfExpiry", Rule: "maxValidity", Message: "dateOfExpiry must be at most 10 years after dateOfIssue for authority IPS"},
+		},
+		{
+			name:  "number format",
+			body:  `{"id":"AB1234567","dateOfIssue":"2024-01-01T00:00:00Z","dateOfExpiry":"2034-01-01T00:00:00Z","authority":"IPS"}`,
+			field: validate.Error{Pointer: "/id", Rule: "format", Message: "id must be 9 digits for authority IPS"},
+		},
+		{
+			name:  "unknown authority",
+			body:  `{"id":"ab-1234","dateOfIssue":"2024-01-01T00:00:00Z","dateOfExpiry":"2034-01-01T00:00:00Z","authority":"XYZ"}`,
+			field: validate.Error{Pointer: "/id", Rule: "format", Message: "id must be 1 to 9 capital letters or digits for authority XYZ"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/users/0/passports", strings.NewReader(tt.body))
+			r.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			newTestHandler().ServeHTTP(w, r)
+
+			require.Equal(t, http.StatusUnprocessableEntity, w.Code)
+			var resp status.Response
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+			assert.Equal(t, []validate.Error{tt.field}, resp.Fields)
+		})
+	}
+}
+
+func TestCreatePassportActiveExists(t *testing.T) {
+	handler := newTestHandler()
+	body := `{"id":"111222333","dateOfIssue":"2024-01-01T00:00:00Z","dateOfExpiry":"2034-01-01T00:00:00Z","authority":"HMPO"}`
+	r := httptest.NewRequest(http.MethodPost, "/users/0/passports", strings.NewReader(body))
+	r.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+
+	assert.Equal(t, http.StatusConflict, w.Code)
+	var resp status.Response
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, status.CodePassportActiveExists, resp.Code)
+	assert.Contains(t, resp.Message, `"012345678"`)
+
+	// IPS issued British passports before HMPO, so it clashes too.
+	w = postWithKey(handler, "/users/0/passports", "", strings.Replace(body, "HMPO", "IPS", 1))
+	assert.Equal(t, http.StatusConflict, w.Code)
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, status.CodePassportActiveExists, resp.Code)
 }
 
 func TestUpdatePassportHandler(t *testing.T) {
 	handler := newTestHandler()
 	body := `{"dateOfIssue":"2021-06-01T00:00:00Z","dateOfExpiry":"2031-06-01T00:00:00Z","authority":"IPS"}`
 	r := httptest.NewRequest(http.MethodPut, "/passports/012345678", strings.NewReader(body))
+	r.Header.Set("Content-Type", "application/json")
 	w := httptest.NewRecorder()
 	handler.ServeHTTP(w, r)
 
@@ -373,9 +491,26 @@ func TestUpdatePassportHandler(t *testing.T) {
 	assert.Equal(t, "IPS", passport["authority"])
 }
 
+func TestUpdatePassportKeepsOwner(t *testing.T) {
+	handler := newTestHandler()
+	body := `{"dateOfIssue":"2019-06-01","dateOfExpiry":"2029-06-01","authority":"HMPO"}`
+
+	// Without userId, the passport stays with user 1.
+	w := sendJSON(handler, http.MethodPut, "/passports/987654321", body)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	_, passport := getJSON(t, handler, "/passports/987654321")
+	assert.Equal(t, float64(1), passport["userId"])
+
+	// With it, the passport moves, here onto user 0 who already has one.
+	w = sendJSON(handler, http.MethodPut, "/passports/987654321", strings.Replace(body, "{", `{"userId":0,`, 1))
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Contains(t, w.Body.String(), string(status.CodePassportActiveExists))
+}
+
 func TestUpdatePassportMalformedJSON(t *testing.T) {
 	handler := newTestHandler()
 	r := httptest.NewRequest(http.MethodPut, "/passports/012345678", strings.NewReader(`{bad`))
+	r.Header.Set("Content-Type", "application/json")
 	w := httptest.NewRecorder()
 	handler.ServeHTTP(w, r)
 
@@ -386,6 +521,7 @@ func TestUpdatePassportValidationFails(t *testing.T) {
 	handler := newTestHandler()
 	body := `{"dateOfIssue":"0001-01-01T00:00:00Z","dateOfExpiry":"0001-01-01T00:00:00Z","authority":""}`
 	r := httptest.NewRequest(http.MethodPut, "/passports/012345678", strings.NewReader(body))
+	r.Header.Set("Content-Type", "application/json")
 	w := httptest.NewRecorder()
 	handler.ServeHTTP(w, r)
 
@@ -396,6 +532,7 @@ func TestUpdatePassportNotFound(t *testing.T) {
 	handler := newTestHandler()
 	body := `{"dateOfIssue":"2021-06-01T00:00:00Z","dateOfExpiry":"2031-06-01T00:00:00Z","authority":"IPS"}`
 	r := httptest.NewRequest(http.MethodPut, "/passports/000000000", strings.NewReader(body))
+	r.Header.Set("Content-Type", "application/json")
 	w := httptest.NewRecorder()
 	handler.ServeHTTP(w, r)
 
@@ -411,6 +548,34 @@ func TestDeletePassportHandler(t *testing.T) {
 	assert.Equal(t, http.StatusNoContent, w.Code)
 }
 
+func TestDeletePassportDeletesVisasAndAttachments(t *testing.T) {
+	handler := newTestHandler()
+	w := upload(t, handler, "/passports/012345678/attachments", "file", "photo.png", photoPage)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+
+	// A rolled-back delete keeps everything.
+	_, resp := postBatch(t, handler, `{"atomic":true,"operations":[
+		{"method":"DELETE","path":"/passports/012345678"},
+		{"method":"GET","path":"/users/99"}
+	]}`)
+	assert.False(t, *resp.Committed)
+	_, body := getJSON(t, handler, "/passports/012345678/visas")
+	assert.EqualValues(t, 1, body["total"])
+	_, body = getJSON(t, handler, "/passports/012345678/attachments")
+	assert.EqualValues(t, 1, body["count"])
+
+	w = sendJSON(handler, http.MethodDelete, "/passports/012345678", "")
+	require.Equal(t, http.StatusNoContent, w.Code)
+
+	// A new passport with the same number starts out empty.
+	w = postWithKey(handler, "/users/0/passports", "", `{"id":"012345678","dateOfIssue":"2024-01-01","dateOfExpiry":"2034-01-01","authority":"HMPO"}`)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	_, body = getJSON(t, handler, "/passports/012345678/visas")
+	assert.EqualValues(t, 0, body["total"])
+	_, body = getJSON(t, handler, "/passports/012345678/attachments")
+	assert.EqualValues(t, 0, body["count"])
+}
+
 func TestDeletePassportNotFound(t *testing.T) {
 	handler := newTestHandler()
 	r := httptest.NewRequest(http.MethodDelete, "/passports/000000000", nil)
@@ -419,3 +584,508 @@ func TestDeletePassportNotFound(t *testing.T) {
 
 	assert.Equal(t, http.StatusInternalServerError, w.Code)
 }
+
+// --- Sparse fieldsets ---
+
+func TestListUsersWithFields(t *testing.T) {
+	handler := newTestHandler()
+	r := httptest.NewRequest(http.MethodGet, "/users?fields=id,lastName", nil)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var body struct {
+		Users []map[string]any `json:"users"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	require.Len(t, body.Users, 2)
+	assert.Equal(t, map[string]any{"id": float64(0), "lastName": "Doe"}, body.Users[0])
+}
+
+func TestGetUserWithFields(t *testing.T) {
+	handler := newTestHandler()
+	r := httptest.NewRequest(http.MethodGet, "/users/1?fields=firstName", nil)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var body map[string]any
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	assert.Equal(t, map[string]any{"firstName": "Jane"}, body)
+}
+
+func TestGetUserWithUnknownField(t *testing.T) {
+	handler := newTestHandler()
+	r := httptest.NewRequest(http.MethodGet, "/users/0?fields=id,password", nil)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	var resp map[string]any
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, "INVALID_FIELDS", resp["code"])
+	assert.Equal(t, `unknown field "password"`, resp["message"])
+}
+
+func TestGetPassportWithFields(t *testing.T) {
+	handler := newTestHandler()
+	r := httptest.NewRequest(http.MethodGet, "/passports/012345678?fields=id,authority", nil)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var body map[string]any
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	assert.Equal(t, map[string]any{"id": "012345678", "authority": "HMPO"}, body)
+}
+
+func TestListUserPassportsWithFields(t *testing.T) {
+	handler := newTestHandler()
+	r := httptest.NewRequest(http.MethodGet, "/users/0/passports?fields=dateOfExpiry", nil)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var body struct {
+		Passports []map[string]any `json:"passports"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	require.Len(t, body.Passports, 1)
+	assert.Equal(t, map[string]any{"dateOfExpiry": "2030-01-15T00:00:00Z"}, body.Passports[0])
+}
+
+func TestListUserPassportsWithUnknownField(t *testing.T) {
+	handler := newTestHandler()
+	r := httptest.NewRequest(http.MethodGet, "/users/0/passports?fields=firstName", nil)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+// --- Filtering and sorting ---
+
+func TestListUsersFilter(t *testing.T) {
+	tests := []struct {
+		query string
+		names []string
+	}{
+		{"lastName=Doe", []string{"John", "Jane"}},
+		{"firstName=Jane", []string{"Jane"}},
+		{"dateOfBirth[gte]=1990-01-01", []string{"Jane"}},
+		{"locationOfBirth[contains]=lon", []string{"John"}},
+		{"sort=-id", []string{"Jane", "John"}},
+		{"lastName=Doe&sort=-dateOfBirth&limit=1", []string{"Jane"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			handler := newTestHandler()
+			r := httptest.NewRequest(http.MethodGet, "/users?"+tt.query, nil)
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+
+			assert.Equal(t, http.StatusOK, w.Code)
+			var body struct {
+				Users []models.User `json:"users"`
+			}
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+			var names []string
+			for _, u := range body.Users {
+				names = append(names, u.FirstName)
+			}
+			assert.Equal(t, tt.names, names)
+		})
+	}
+}
+
+func TestListUsersFilterTotalCountsMatches(t *testing.T) {
+	handler := newTestHandler()
+	r := httptest.NewRequest(http.MethodGet, "/users?firstName=John", nil)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+
+	var body map[string]any
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	assert.Equal(t, float64(1), body["total"])
+}
+
+func TestListUsersInvalidFilter(t *testing.T) {
+	handler := newTestHandler()
+	r := httptest.NewRequest(http.MethodGet, "/users?dateOfBirth[gte]=last-year", nil)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	var resp map[string]any
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, "INVALID_QUERY", resp["code"])
+}
+
+func TestListUserPassportsFilter(t *testing.T) {
+	handler := newTestHandler()
+	r := httptest.NewRequest(http.MethodGet, "/users/0/passports?authority=IPS", nil)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var body map[string]any
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	assert.Equal(t, float64(0), body["count"])
+}
+
+func TestListUserPassportsInvalidSort(t *testing.T) {
+	handler := newTestHandler()
+	r := httptest.NewRequest(http.MethodGet, "/users/0/passports?sort=lastName", nil)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+// --- Search ---
+
+type searchResponse struct {
+	Results []struct {
+		User       models.User       `json:"user"`
+		Score      float64           `json:"score"`
+		Highlights map[string]string `json:"highlights"`
+	} `json:"results"`
+	Count int `json:"count"`
+	Total int `json:"total"`
+}
+
+func doSearch(t *testing.T, handler http.Handler, q string) searchResponse {
+	t.Helper()
+	r := httptest.NewRequest(http.MethodGet, "/search?q="+q, nil)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	require.Equal(t, http.StatusOK, w.Code)
+	var body searchResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	return body
+}
+
+func TestSearchByPartialName(t *testing.T) {
+	body := doSearch(t, newTestHandler(), "ja")
+	require.Equal(t, 1, body.Total)
+	assert.Equal(t, "Jane", body.Results[0].User.FirstName)
+	assert.Equal(t, "<mark>Jane</mark>", body.Results[0].Highlights["firstName"])
+}
+
+func TestSearchByBirthplaceAndAuthority(t *testing.T) {
+	handler := newTestHandler()
+	body := doSearch(t, handler, "milton+hmpo")
+	require.Equal(t, 1, body.Total)
+	assert.Equal(t, 1, body.Results[0].User.ID)
+	assert.Equal(t, "<mark>HMPO</mark>", body.Results[0].Highlights["passports.authority"])
+
+	assert.Equal(t, 2, doSearch(t, handler, "doe").Total)
+}
+
+func TestSearchMissingQuery(t *testing.T) {
+	handler := newTestHandler()
+	r := httptest.NewRequest(http.MethodGet, "/search", nil)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestSearchIndexFollowsMutations(t *testing.T) {
+	handler := newTestHandler()
+
+	body := `{"firstName":"Apple","lastName":"Jack","dateOfBirth":"1972-03-07T00:00:00Z","locationOfBirth":"Cambridge"}`
+	r := httptest.NewRequest(http.MethodPost, "/users", strings.NewReader(body))
+	r.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	require.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t, 1, doSearch(t, handler, "cambr").Total)
+
+	body = `{"id":"111222333","dateOfIssue":"2024-01-01T00:00:00Z","dateOfExpiry":"2034-01-01T00:00:00Z","authority":"IPS"}`
+	r = httptest.NewRequest(http.MethodPost, "/users/2/passports", strings.NewReader(body))
+	r.Header.Set("Content-Type", "application/json")
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	require.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t, 1, doSearch(t, handler, "jack+ips").Total)
+
+	r = httptest.NewRequest(http.MethodDelete, "/passports/111222333", nil)
+	handler.ServeHTTP(httptest.NewRecorder(), r)
+	assert.Equal(t, 0, doSearch(t, handler, "ips").Total)
+
+	r = httptest.NewRequest(http.MethodDelete, "/users/2", nil)
+	handler.ServeHTTP(httptest.NewRecorder(), r)
+	assert.Equal(t, 0, doSearch(t, handler, "jack").Total)
+}
+
+// --- Embedding related resources ---
+
+// countingPassportStore records how often passports are loaded, to check that
+// ?include=passports batches its storage calls.
+type countingPassportStore struct {
+	models.PassportStorage
+	single, batch int
+}
+
+func (s *countingPassportStore) ListPassportsByUser(ctx context.Context, userID int, q query.Query) ([]models.Passport, error) {
+	s.single++
+	return s.PassportStorage.ListPassportsByUser(ctx, userID, q)
+}
+
+func (s *countingPassportStore) ListPassportsByUsers(ctx context.Context, userIDs []int) (map[int][]models.Passport, error) {
+	s.batch++
+	return s.PassportStorage.ListPassportsByUsers(ctx, userIDs)
+}
+
+// userWithPassports is a user as returned with ?include=passports.
+type userWithPassports struct {
+	models.User
+	Passports []models.Passport `json:"passports"`
+}
+
+func TestListUsersIncludePassports(t *testing.T) {
+	store := &countingPassportStore{PassportStorage: NewPassportService(CreateMockPassportDataSet())}
+	srv := NewServer(NewUserService(CreateMockDataSet()), store, NewVisaService(CreateMockVisaDataSet()), slog.Default(), ServerOptions{Env: "LOCAL"})
+	handler := srv.middleware(srv.routes())
+	store.single, store.batch = 0, 0 // ignore search index build
+
+	r := httptest.NewRequest(http.MethodGet, "/users?include=passports", nil)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var body struct {
+		Users []userWithPassports `json:"users"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	require.Len(t, body.Users, 2)
+	assert.Equal(t, "John", body.Users[0].FirstName)
+	require.Len(t, body.Users[0].Passports, 1)
+	assert.Equal(t, "012345678", body.Users[0].Passports[0].ID)
+	assert.Equal(t, "987654321", body.Users[1].Passports[0].ID)
+	assert.Equal(t, 0, store.single)
+	assert.Equal(t, 1, store.batch)
+}
+
+func TestGetUserIncludePassports(t *testing.T) {
+	handler := newTestHandler()
+	r := httptest.NewRequest(http.MethodGet, "/users/1?include=passports", nil)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var body userWithPassports
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	assert.Equal(t, "Jane", body.FirstName)
+	require.Len(t, body.Passports, 1)
+	assert.Equal(t, "987654321", body.Passports[0].ID)
+}
+
+func TestGetUserIncludePassportsWithFields(t *testing.T) {
+	handler := newTestHandler()
+	r := httptest.NewRequest(http.MethodGet, "/users/0?include=passports&fields=lastName", nil)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var body map[string]any
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	assert.Len(t, body, 2)
+	assert.Equal(t, "Doe", body["lastName"])
+	assert.Len(t, body["passports"], 1)
+}
+
+func TestListUsersUnknownInclude(t *testing.T) {
+	handler := newTestHandler()
+	r := httptest.NewRequest(http.MethodGet, "/users?include=visas", nil)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	var resp map[string]any
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, "INVALID_INCLUDE", resp["code"])
+}
+
+// --- HAL ---
+
+func getHAL(t *testing.T, handler http.Handler, target string) map[string]any {
+	t.Helper()
+	r := httptest.NewRequest(http.MethodGet, target, nil)
+	r.Header.Set("Accept", "application/hal+json")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/hal+json", w.Header().Get("Content-Type"))
+	var body map[string]any
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	return body
+}
+
+func href(t *testing.T, body map[string]any, rel string) string {
+	t.Helper()
+	links, ok := body["_links"].(map[string]any)
+	require.True(t, ok, "missing _links")
+	l, ok := links[rel].(map[string]any)
+	if !ok {
+		return ""
+	}
+	return l["href"].(string)
+}
+
+func TestGetUserHAL(t *testing.T) {
+	body := getHAL(t, newTestHandler(), "/users/0")
+	assert.Equal(t, "John", body["firstName"])
+	assert.Equal(t, "/users/0", href(t, body, "self"))
+	assert.Equal(t, "/users/0/passports", href(t, body, "passports"))
+}
+
+func TestGetUserHALIncludePassports(t *testing.T) {
+	body := getHAL(t, newTestHandler(), "/users/0?include=passports")
+	assert.NotContains(t, body, "passports")
+	embedded := body["_embedded"].(map[string]any)["passports"].([]any)
+	require.Len(t, embedded, 1)
+	assert.Equal(t, "/users/0", href(t, embedded[0].(map[string]any), "owner"))
+}
+
+func TestGetPassportHAL(t *testing.T) {
+	body := getHAL(t, newTestHandler(), "/passports/012345678")
+	assert.Equal(t, "HMPO", body["authority"])
+	assert.Equal(t, "/passports/012345678", href(t, body, "self"))
+	assert.Equal(t, "/users/0", href(t, body, "owner"))
+}
+
+func TestListUsersHALPaginationLinks(t *testing.T) {
+	body := getHAL(t, newTestHandler(), "/users?limit=1")
+	assert.Equal(t, "/users?limit=1&offset=0", href(t, body, "self"))
+	assert.Equal(t, "/users?limit=1&offset=0", href(t, body, "first"))
+	assert.Equal(t, "/users?limit=1&offset=1", href(t, body, "next"))
+	assert.Equal(t, "/users?limit=1&offset=1", href(t, body, "last"))
+	assert.Empty(t, href(t, body, "prev"))
+	assert.Equal(t, float64(2), body["total"])
+
+	users := body["_embedded"].(map[string]any)["users"].([]any)
+	require.Len(t, users, 1)
+	assert.Equal(t, "/users/0", href(t, users[0].(map[string]any), "self"))
+}
+
+func TestListUserPassportsHAL(t *testing.T) {
+	body := getHAL(t, newTestHandler(), "/users/1/passports")
+	assert.Equal(t, "/users/1", href(t, body, "owner"))
+	passports := body["_embedded"].(map[string]any)["passports"].([]any)
+	require.Len(t, passports, 1)
+	assert.Equal(t, "/passports/987654321", href(t, passports[0].(map[string]any), "self"))
+}
+
+func TestGetUserPlainJSONHasNoLinks(t *testing.T) {
+	handler := newTestHandler()
+	r := httptest.NewRequest(http.MethodGet, "/users/0", nil)
+	r.Header.Set("Accept", "application/json")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+	assert.NotContains(t, w.Body.String(), "_links")
+}
+
+// --- Link headers and HEAD ---
+
+func TestListUsersLinkHeader(t *testing.T) {
+	handler := newTestHandler()
+	r := httptest.NewRequest(http.MethodGet, "/users?lastName=Doe&limit=1&offset=1", nil)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "2", w.Header().Get("X-Total-Count"))
+	assert.Equal(t,
+		`</users?lastName=Doe&limit=1&offset=0>; rel="first", </users?lastName=Doe&limit=1&offset=0>; rel="prev", `+
+			`</users?lastName=Doe&limit=1&offset=1>; rel="last"`,
+		w.Header().Get("Link"))
+}
+
+func TestListUserPassportsPagination(t *testing.T) {
+	handler := newTestHandler()
+	r := httptest.NewRequest(http.MethodGet, "/users/0/passports?limit=10", nil)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "1", w.Header().Get("X-Total-Count"))
+	assert.Contains(t, w.Header().Get("Link"), `</users/0/passports?limit=10&offset=0>; rel="first"`)
+	var body map[string]any
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	assert.Equal(t, float64(1), body["total"])
+	assert.Equal(t, float64(10), body["limit"])
+}
+
+func TestHeadOnGetRoutes(t *testing.T) {
+	paths := []string{
+		"/healthcheck",
+		"/ready",
+		"/errors",
+		"/users",
+		"/users/0",
+		"/search?q=doe",
+		"/users/0/passports",
+		"/passports/012345678",
+	}
+	for _, path := range paths {
+		t.Run(path, func(t *testing.T) {
+			handler := newTestHandler()
+			get := httptest.NewRecorder()
+			handler.ServeHTTP(get, httptest.NewRequest(http.MethodGet, path, nil))
+
+			head := httptest.NewRecorder()
+			handler.ServeHTTP(head, httptest.NewRequest(http.MethodHead, path, nil))
+
+			assert.Equal(t, get.Code, head.Code)
+			assert.Empty(t, head.Body.String())
+			assert.Equal(t, strconv.Itoa(get.Body.Len()), head.Header().Get("Content-Length"))
+			assert.Equal(t, get.Header().Get("Content-Type"), head.Header().Get("Content-Type"))
+			assert.Equal(t, get.Header().Get("Link"), head.Header().Get("Link"))
+			assert.Equal(t, get.Header().Get("X-Total-Count"), head.Header().Get("X-Total-Count"))
+		})
+	}
+}
+
+func TestHeadNotFound(t *testing.T) {
+	handler := newTestHandler()
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodHead, "/users/99", nil))
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Empty(t, w.Body.String())
+}
+
+// --- Idempotency keys ---
+
+func TestCreateUserIdempotencyKey(t *testing.T) {
+	handler := newTestHandler()
+	body := `{"firstName":"Apple","lastName":"Jack","dateOfBirth":"1972-03-07T00:00:00Z","locationOfBirth":"Cambridge"}`
+
+	first := postWithKey(handler, "/users", "retry-1", body)
+	second := postWithKey(handler, "/users", "retry-1", body)
+	assert.Equal(t, http.StatusCreated, first.Code)
+	assert.Equal(t, http.StatusCreated, second.Code)
+	assert.Equal(t, first.Body.String(), second.Body.String())
+
+	r := httptest.NewRequest(http.MethodGet, "/users", nil)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	assert.Equal(t, "3", w.Header().Get("X-Total-Count"), "retry must not create a second user")
+
+	third := postWithKey(handler, "/users", "", body)
+	assert.Contains(t, third.Body.String(), `"id":3`)
+}
+
+func TestCreatePassportIdempotencyKey(t *testing.T) {
+	handler := newTestHandler()
+	body := `{"id":"PA1234567","dateOfIssue":"2024-01-01T00:00:00Z","dateOfExpiry":"2034-01-01T00:00:00Z","authority":"DFA"}`
+
+	assert.Equal(t, http.StatusCreated, postWithKey(handler, "/users/0/passports", "p-1", body).Code)
+	// Without the key, the retry would fail with 409 because the ID exists.
+	assert.Equal(t, http.StatusCreated, postWithKey(handler, "/users/0/passports", "p-1", body).Code)
+	assert.Equal(t, http.StatusConflict, postWithKey(handler, "/users/0/passports", "", body).Code)
+}
@@ -0,0 +1,244 @@
+package passport
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/leeprovoost/go-rest-api-template/pkg/status"
+)
+
+// defaultIdempotencyTTL is how long responses are kept when
+// ServerOptions.IdempotencyTTL is not set.
+const defaultIdempotencyTTL = 24 * time.Hour
+
+// maxIdempotencyKeyLength bounds the size of client-supplied keys.
+const maxIdempotencyKeyLength = 255
+
+// idempotencyReservationTTL is how long a key stays reserved for a request
+// that has not finished, after which a retry may run again. It is well above
+// the server's write timeout.
+const idempotencyReservationTTL = time.Minute
+
+// idempotencySweepInterval is how often expired entries of keys that aren't
+// used again are removed.
+const idempotencySweepInterval = time.Minute
+
+// idempotencyStore remembers the first response sent for each
+// Idempotency-Key so that retries can be answered without repeating the
+// side effect.
+// Note: like the rate limiter, this is per-instance. For distributed
+// systems, use an external store like Redis.
+type idempotencyStore struct {
+	mu        sync.Mutex
+	ttl       time.Duration
+	now       func() time.Time
+	entries   map[string]*idempotencyEntry
+	lastSweep time.Time
+}
+
+// idempotencyEntry is a stored response. An entry with done == false belongs
+// to a request that is still being processed, and expires when its
+// reservation lapses.
+type idempotencyEntry struct {
+	fingerprint string
+	expires     time.Time
+	done        bool
+	statusCode  int
+	header      http.Header
+	body        []byte
+}
+
+func newIdempotencyStore(ttl time.Duration) *idempotencyStore {
+	if ttl <= 0 {
+		ttl = defaultIdempotencyTTL
+	}
+	return &idempotencyStore{
+		ttl:     ttl,
+		now:     time.Now,
+		entries: make(map[string]*idempotencyEntry),
+	}
+}
+
+// begin looks up key. It returns the stored entry if one exists and hasn't
+// expired, or reserves the key for a new request and returns the reservation
+// with reserved set.
+func (st *idempotencyStore) begin(key, fingerprint string) (e *idempotencyEntry, reserved bool) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	now := st.now()
+	st.sweep(now)
+	if e, ok := st.entries[key]; ok && !now.After(e.expires) {
+		return e, false
+	}
+	e = &idempotencyEntry{fingerprint: fingerprint, expires: now.Add(idempotencyReservationTTL)}
+	st.entries[key] = e
+	return e, true
+}
+
+// sweep removes the expired entries, at most once per
+// idempotencySweepInterval, so that keys that are never used again don't
+// pile up. The caller holds the lock.
+func (st *idempotencyStore) sweep(now time.Time) {
+	if now.Sub(st.lastSweep) < idempotencySweepInterval {
+		return
+	}
+	for k, e := range st.entries {
+		if now.After(e.expires) {
+			delete(st.entries, k)
+		}
+	}
+	st.lastSweep = now
+}
+
+// finish stores the response for the reservation e of key. It does nothing
+// if the reservation has lapsed.
+func (st *idempotencyStore) finish(key string, e *idempotencyEntry, statusCode int, header http.Header, body []byte) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if st.entries[key] != e {
+		return
+	}
+	e.done = true
+	e.expires = st.now().Add(st.ttl)
+	e.statusCode = statusCode
+	e.header = header
+	e.body = body
+}
+
+// release forgets the reservation e of key so the request can be retried.
+func (st *idempotencyStore) release(key string, e *idempotencyEntry) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if st.entries[key] == e {
+		delete(st.entries, key)
+	}
+}
+
+// idempotencyScope returns the key under which a request's Idempotency-Key
+// is stored. Keys are scoped to the client, identified by its credentials
+// or, without any, its address like the rate limiter does, and to the
+// method and path, so clients can't replay each other's responses.
+func idempotencyScope(r *http.Request, key string) string {
+	sum := sha256.Sum256([]byte(clientIdentity(r) + "\n" + r.Method + " " + r.URL.Path + "\n" + key))
+	return hex.EncodeToString(sum[:])
+}
+
+// idempotent makes a POST handler safe to retry. When the request carries an
+// Idempotency-Key header, the first response is stored and replayed for
+// later requests from the same client to the same endpoint with the same key
+// and payload. Reusing a key with a different payload is rejected. Server
+// errors are not stored, so a request that failed can be retried with the
+// same key, and neither are responses to operations of an atomic batch that
+// rolled back.
+func (s *Server) idempotent(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get("Idempotency-Key")
+		if key == "" {
+			next(w, r)
+			return
+		}
+		if len(key) > maxIdempotencyKeyLength {
+			respondError(w, status.CodeInvalidIdempotencyKey, "Idempotency-Key must be at most 255 characters")
+			return
+		}
+
+		// The handler applies its own, usually lower, limit when it decodes
+		// the body; this only stops oversized bodies being held in memory.
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxImportBodyBytes))
+		if err != nil {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				respondError(w, status.CodeRequestTooLarge, "request body is too large")
+				return
+			}
+			respondError(w, status.CodeMalformedRequest, "can't read request body")
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		sum := sha256.Sum256(body)
+		fingerprint := hex.EncodeToString(sum[:])
+
+		scope := idempotencyScope(r, key)
+		e, reserved := s.idempotency.begin(scope, fingerprint)
+		if !reserved {
+			switch {
+			case e.fingerprint != fingerprint:
+				respondError(w, status.CodeIdempotencyKeyReused, "Idempotency-Key was already used with a different request")
+			case !e.done:
+				respondError(w, status.CodeIdempotencyKeyInUse, "a request with this Idempotency-Key is still being processed")
+			default:
+				for k, v := range e.header {
+					w.Header()[k] = slices.Clone(v)
+				}
+				w.Header().Set("Idempotent-Replayed", "true")
+				w.WriteHeader(e.statusCode)
+				w.Write(e.body)
+			}
+			return
+		}
+
+		// The reservation is released unless a response is stored, also if
+		// the handler panics.
+		stored := false
+		defer func() {
+			if !stored {
+				s.idempotency.release(scope, e)
+			}
+		}()
+		before := w.Header().Clone()
+		cw := &captureWriter{ResponseWriter: w, statusCode: http.StatusOK}
+		next(cw, r)
+		if cw.statusCode >= http.StatusInternalServerError {
+			return
+		}
+		// Inside an atomic batch the response only stands if the batch
+		// commits; after a rollback the key is free again.
+		stored = true
+		header, body := headersAddedSince(before, w.Header()), cw.body.Bytes()
+		afterEnd(r.Context(), func(committed bool) {
+			if !committed {
+				s.idempotency.release(scope, e)
+				return
+			}
+			s.idempotency.finish(scope, e, cw.statusCode, header, body)
+		})
+	}
+}
+
+// headersAddedSince returns the headers in after that were added or changed
+// since before, so that replays don't repeat values set by outer middleware
+// such as X-Request-ID.
+func headersAddedSince(before, after http.Header) http.Header {
+	added := make(http.Header)
+	for k, v := range after {
+		if !slices.Equal(before[k], v) {
+			added[k] = slices.Clone(v)
+		}
+	}
+	return added
+}
+
+// captureWriter passes the response through while keeping a copy of the
+// status code and body.
+type captureWriter struct {
+	http.ResponseWriter
+	statusCode int
+	body       bytes.Buffer
+}
+
+func (cw *captureWriter) WriteHeader(code int) {
+	cw.statusCode = code
+	cw.ResponseWriter.WriteHeader(code)
+}
+
+func (cw *captureWriter) Write(b []byte) (int, error) {
+	cw.body.Write(b)
+	return cw.ResponseWriter.Write(b)
+}
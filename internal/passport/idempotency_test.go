@@ -0,0 +1,216 @@
+package passport
+
+import (
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func postWithKey(handler http.Handler, path, key, body string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+	r.Header.Set("Content-Type", "application/json")
+	if key != "" {
+		r.Header.Set("Idempotency-Key", key)
+	}
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	return w
+}
+
+func TestIdempotentReplaysFirstResponse(t *testing.T) {
+	srv := NewTestServer()
+	calls := 0
+	handler := requestID(srv.idempotent(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Header().Set("Location", "/things/1")
+		respond(w, http.StatusCreated, map[string]int{"call": calls})
+	}))
+
+	first := postWithKey(handler, "/things", "abc", `{"a":1}`)
+	second := postWithKey(handler, "/things", "abc", `{"a":1}`)
+
+	assert.Equal(t, 1, calls)
+	assert.Equal(t, http.StatusCreated, second.Code)
+	assert.Equal(t, first.Body.String(), second.Body.String())
+	assert.Equal(t, "/things/1", second.Header().Get("Location"))
+	assert.Equal(t, "true", second.Header().Get("Idempotent-Replayed"))
+	assert.Empty(t, first.Header().Get("Idempotent-Replayed"))
+	assert.NotEqual(t, first.Header().Get("X-Request-ID"), second.Header().Get("X-Request-ID"))
+}
+
+func TestIdempotentRejectsDifferentPayload(t *testing.T) {
+	srv := NewTestServer()
+	handler := srv.idempotent(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+	})
+
+	postWithKey(handler, "/things", "abc", `{"a":1}`)
+	w := postWithKey(handler, "/things", "abc", `{"a":2}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	assert.Contains(t, w.Body.String(), "IDEMPOTENCY_KEY_REUSED")
+
+}
+
+func TestIdempotentKeysAreScoped(t *testing.T) {
+	srv := NewTestServer()
+	calls := 0
+	handler := srv.idempotent(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusCreated)
+	})
+	post := func(path, auth, remoteAddr string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodPost, path, strings.NewReader(`{}`))
+		r.Header.Set("Idempotency-Key", "abc")
+		if auth != "" {
+			r.Header.Set("Authorization", auth)
+		}
+		r.RemoteAddr = remoteAddr
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w
+	}
+
+	post("/things", "", "1.2.3.4:1234")
+	assert.Equal(t, "true", post("/things", "", "1.2.3.4:5678").Header().Get("Idempotent-Replayed"))
+	// Another endpoint, address or credential doesn't see the response.
+	for _, w := range []*httptest.ResponseRecorder{
+		post("/other", "", "1.2.3.4:1234"),
+		post("/things", "", "5.6.7.8:1234"),
+		post("/things", "Bearer alice", "1.2.3.4:1234"),
+		post("/things", "Bearer bob", "1.2.3.4:1234"),
+	} {
+		assert.Equal(t, http.StatusCreated, w.Code)
+		assert.Empty(t, w.Header().Get("Idempotent-Replayed"))
+	}
+	assert.Equal(t, 5, calls)
+	assert.Equal(t, "true", post("/things", "Bearer alice", "5.6.7.8:1234").Header().Get("Idempotent-Replayed"))
+}
+
+func TestIdempotentReleasesKeyOnPanic(t *testing.T) {
+	srv := NewTestServer()
+	calls := 0
+	handler := srv.idempotent(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls == 1 {
+			panic("boom")
+		}
+		w.WriteHeader(http.StatusCreated)
+	})
+
+	assert.Panics(t, func() { postWithKey(handler, "/things", "abc", `{}`) })
+	assert.Equal(t, http.StatusCreated, postWithKey(handler, "/things", "abc", `{}`).Code)
+	assert.Equal(t, 2, calls)
+}
+
+func TestIdempotentReservationsExpire(t *testing.T) {
+	st := newIdempotencyStore(time.Hour)
+	now := time.Now()
+	st.now = func() time.Time { return now }
+
+	stale, reserved := st.begin("abc", "f")
+	assert.True(t, reserved)
+	_, reserved = st.begin("abc", "f")
+	assert.False(t, reserved)
+
+	// A request that never finished no longer blocks the key, and can't
+	// overwrite the response of the retry that took it over.
+	now = now.Add(idempotencyReservationTTL + time.Second)
+	e, reserved := st.begin("abc", "f")
+	assert.True(t, reserved)
+	st.finish("abc", e, http.StatusCreated, nil, []byte("retry"))
+	st.finish("abc", stale, http.StatusCreated, nil, []byte("stale"))
+	st.release("abc", stale)
+	e, reserved = st.begin("abc", "f")
+	assert.False(t, reserved)
+	assert.Equal(t, "retry", string(e.body))
+}
+
+func TestIdempotentStoreSweepsExpiredEntries(t *testing.T) {
+	st := newIdempotencyStore(time.Hour)
+	now := time.Now()
+	st.now = func() time.Time { return now }
+
+	e, _ := st.begin("a", "f")
+	st.finish("a", e, http.StatusCreated, nil, nil)
+	st.begin("b", "f")
+
+	// Keys that are used again are expired when they are looked up.
+	now = now.Add(idempotencyReservationTTL + time.Second)
+	_, reserved := st.begin("b", "f")
+	assert.True(t, reserved)
+
+	// The others when the next sweep is due.
+	now = now.Add(2 * time.Hour)
+	st.begin("c", "f")
+	assert.Equal(t, []string{"c"}, slices.Collect(maps.Keys(st.entries)))
+}
+
+func TestIdempotentKeyInUse(t *testing.T) {
+	srv := NewTestServer()
+	var inner *httptest.ResponseRecorder
+	var handler http.HandlerFunc
+	handler = srv.idempotent(func(w http.ResponseWriter, r *http.Request) {
+		// A retry arriving while the first request is still running.
+		inner = postWithKey(handler, "/things", "abc", `{}`)
+		w.WriteHeader(http.StatusCreated)
+	})
+
+	postWithKey(handler, "/things", "abc", `{}`)
+	assert.Equal(t, http.StatusConflict, inner.Code)
+	assert.Contains(t, inner.Body.String(), "IDEMPOTENCY_KEY_IN_USE")
+}
+
+func TestIdempotentDoesNotStoreServerErrors(t *testing.T) {
+	srv := NewTestServer()
+	codes := []int{http.StatusInternalServerError, http.StatusCreated}
+	calls := 0
+	handler := srv.idempotent(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(codes[calls])
+		calls++
+	})
+
+	assert.Equal(t, http.StatusInternalServerError, postWithKey(handler, "/things", "abc", `{}`).Code)
+	assert.Equal(t, http.StatusCreated, postWithKey(handler, "/things", "abc", `{}`).Code)
+	assert.Equal(t, 2, calls)
+}
+
+func TestIdempotentEntriesExpire(t *testing.T) {
+	srv := NewTestServer()
+	now := time.Now()
+	srv.idempotency = newIdempotencyStore(time.Minute)
+	srv.idempotency.now = func() time.Time { return now }
+	calls := 0
+	handler := srv.idempotent(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusCreated)
+	})
+
+	postWithKey(handler, "/things", "abc", `{}`)
+	now = now.Add(30 * time.Second)
+	postWithKey(handler, "/things", "abc", `{}`)
+	assert.Equal(t, 1, calls)
+
+	now = now.Add(time.Minute)
+	postWithKey(handler, "/things", "abc", `{"new":true}`)
+	assert.Equal(t, 2, calls)
+}
+
+func TestIdempotentKeyTooLong(t *testing.T) {
+	srv := NewTestServer()
+	handler := srv.idempotent(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+	})
+
+	w := postWithKey(handler, "/things", strings.Repeat("k", 256), `{}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestNewIdempotencyStoreDefaultTTL(t *testing.T) {
+	assert.Equal(t, defaultIdempotencyTTL, newIdempotencyStore(0).ttl)
+}
@@ -0,0 +1,61 @@
+package passport
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/leeprovoost/go-rest-api-template/internal/passport/models"
+)
+
+// parseInclude reads the comma-separated include query parameter and checks
+// each name against allowed. It returns an empty set when nothing was asked for.
+func parseInclude(r *http.Request, allowed ...string) (map[string]bool, error) {
+	include := make(map[string]bool)
+	raw := r.URL.Query().Get("include")
+	if raw == "" {
+		return include, nil
+	}
+	for _, name := range strings.Split(raw, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		valid := false
+		for _, a := range allowed {
+			if name == a {
+				valid = true
+				break
+			}
+		}
+		if !valid {
+			return nil, fmt.Errorf("unknown include %q", name)
+		}
+		include[name] = true
+	}
+	return include, nil
+}
+
+// loadPassports fetches the passports of all given users with a single
+// storage call, so embedding passports in a page of users costs one query
+// rather than one per user.
+func (s *Server) loadPassports(ctx context.Context, users []models.User) (map[int][]models.Passport, error) {
+	ids := make([]int, len(users))
+	for i, u := range users {
+		ids[i] = u.ID
+	}
+	return s.passportStore.ListPassportsByUsers(ctx, ids)
+}
+
+// embedPassports returns u in version v, restricted to fields, with
+// passports embedded under the "passports" key.
+func embedPassports(v *apiVersion, u models.User, fields []string, passports []models.Passport) any {
+	obj := make(map[string]json.RawMessage)
+	if dat, err := json.Marshal(project(v.user(u), fields)); err == nil {
+		json.Unmarshal(dat, &obj)
+	}
+	obj["passports"], _ = json.Marshal(mapAll(passports, v.passport))
+	return obj
+}
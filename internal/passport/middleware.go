@@ -35,8 +35,9 @@ func cors(allowedOrigins string) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			w.Header().Set("Access-Control-Allow-Origin", allowedOrigins)
-			w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-			w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-Request-ID")
+			w.Header().Set("Access-Control-Allow-Methods", "GET, HEAD, POST, PUT, DELETE, OPTIONS")
+			w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-Request-ID, Idempotency-Key")
+			w.Header().Set("Access-Control-Expose-Headers", "Link, X-Total-Count, X-Request-ID, Idempotent-Replayed, Deprecation, Sunset")
 
 			if r.Method == http.MethodOptions {
 				w.WriteHeader(http.StatusNoContent)
@@ -48,6 +49,41 @@ func cors(allowedOrigins string) func(http.Handler) http.Handler {
 	}
 }
 
+// headMethod discards the response body for HEAD requests. ServeMux routes
+// HEAD to the matching GET handler; this wrapper holds back the status line
+// until the handler returns so the headers, including Content-Length, match
+// what GET would have sent.
+func headMethod(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodHead {
+			next.ServeHTTP(w, r)
+			return
+		}
+		hw := &headResponseWriter{ResponseWriter: w, statusCode: http.StatusOK}
+		next.ServeHTTP(hw, r)
+		if hw.written > 0 && w.Header().Get("Content-Length") == "" {
+			w.Header().Set("Content-Length", strconv.Itoa(hw.written))
+		}
+		w.WriteHeader(hw.statusCode)
+	})
+}
+
+// headResponseWriter counts and discards body bytes, deferring WriteHeader.
+type headResponseWriter struct {
+	http.ResponseWriter
+	statusCode int
+	written    int
+}
+
+func (hw *headResponseWriter) WriteHeader(code int) {
+	hw.statusCode = code
+}
+
+func (hw *headResponseWriter) Write(b []byte) (int, error) {
+	hw.written += len(b)
+	return len(b), nil
+}
+
 // rateLimiter implements per-IP rate limiting using a token bucket algorithm.
 // Note: This is suitable for single-instance deployments. For distributed
 // systems, use an external store like Redis.
@@ -81,16 +117,22 @@ func (rl *rateLimiter) middleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		ip := clientIP(r)
 		if !rl.getLimiter(ip).Allow() {
-			respond(w, http.StatusTooManyRequests, status.Response{
-				Status:  strconv.Itoa(http.StatusTooManyRequests),
-				Message: "rate limit exceeded",
-			})
+			respondError(w, status.CodeRateLimited, "rate limit exceeded")
 			return
 		}
 		next.ServeHTTP(w, r)
 	})
 }
 
+// clientIdentity identifies the client of a request: by its credentials if
+// it sends any, or else by its address.
+func clientIdentity(r *http.Request) string {
+	if auth := r.Header.Get("Authorization"); auth != "" {
+		return auth
+	}
+	return "ip:" + clientIP(r)
+}
+
 func clientIP(r *http.Request) string {
 	if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
 		return forwarded
@@ -100,6 +100,7 @@ func TestRateLimiterBlocks(t *testing.T) {
 	w = httptest.NewRecorder()
 	handler.ServeHTTP(w, r)
 	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Contains(t, w.Body.String(), `"code":"RATE_LIMITED"`)
 }
 
 func TestClientIPFromXForwardedFor(t *testing.T) {
@@ -119,3 +120,24 @@ func TestClientIPFallback(t *testing.T) {
 	r.RemoteAddr = "no-port"
 	assert.Equal(t, "no-port", clientIP(r))
 }
+
+func TestHeadMethodDiscardsBody(t *testing.T) {
+	handler := headMethod(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		w.WriteHeader(http.StatusAccepted)
+		w.Write([]byte("hello"))
+	}))
+
+	r := httptest.NewRequest(http.MethodHead, "/", nil)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+
+	assert.Equal(t, http.StatusAccepted, w.Code)
+	assert.Equal(t, "5", w.Header().Get("Content-Length"))
+	assert.Empty(t, w.Body.String())
+
+	r = httptest.NewRequest(http.MethodGet, "/", nil)
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	assert.Equal(t, "hello", w.Body.String())
+}
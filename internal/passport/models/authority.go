@@ -0,0 +1,79 @@
+package models
+
+import "regexp"
+
+// authorityRules are the rules a passport from an issuing authority must
+// follow.
+type authorityRules struct {
+	// number matches valid document numbers, and numberFormat describes them.
+	number       *regexp.Regexp
+	numberFormat string
+	// maxValidityYears is the longest a passport may be valid for.
+	maxValidityYears int
+	// issuingState is the ICAO code of the authority's state, e.g. GBR.
+	issuingState string
+}
+
+// authorities holds the rules of the issuing authorities we know about.
+// Authorities that aren't listed follow defaultAuthorityRules.
+var authorities = map[string]authorityRules{
+	// HM Passport Office, and the Identity and Passport Service it replaced.
+	"HMPO": {number: regexp.MustCompile(`^[0-9]{9}$`), numberFormat: "9 digits", maxValidityYears: 10, issuingState: "GBR"},
+	"IPS":  {number: regexp.MustCompile(`^[0-9]{9}$`), numberFormat: "9 digits", maxValidityYears: 10, issuingState: "GBR"},
+	// Irish Department of Foreign Affairs.
+	"DFA": {number: regexp.MustCompile(`^[A-Z]{2}[0-9]{7}$`), numberFormat: "2 letters followed by 7 digits", maxValidityYears: 10, issuingState: "IRL"},
+	// US Department of State.
+	"USDOS": {number: regexp.MustCompile(`^[A-Z0-9][0-9]{8}$`), numberFormat: "a letter or digit followed by 8 digits", maxValidityYears: 10, issuingState: "USA"},
+}
+
+// authorityByState is the current issuing authority of each state in
+// authorities.
+var authorityByState = map[string]string{
+	"GBR": "HMPO",
+	"IRL": "DFA",
+	"USA": "USDOS",
+}
+
+// defaultAuthorityRules allow any document number that fits the 9 characters
+// of the machine readable zone (ICAO 9303).
+var defaultAuthorityRules = authorityRules{
+	number:           regexp.MustCompile(`^[A-Z0-9]{1,9}$`),
+	numberFormat:     "1 to 9 capital letters or digits",
+	maxValidityYears: 10,
+}
+
+// rulesFor returns the rules of the given issuing authority.
+func rulesFor(authority string) authorityRules {
+	if rules, ok := authorities[authority]; ok {
+		return rules
+	}
+	return defaultAuthorityRules
+}
+
+// IssuingState returns the ICAO code of the state of an issuing authority,
+// and false if it isn't known.
+func IssuingState(authority string) (string, bool) {
+	rules, ok := authorities[authority]
+	return rules.issuingState, ok
+}
+
+// SameIssuer reports whether two authorities issue passports for the same
+// state, like HMPO and IPS, the authority it replaced. Authorities that
+// aren't known are only the same as themselves.
+func SameIssuer(a, b string) bool {
+	if a == b {
+		return true
+	}
+	sa, okA := IssuingState(a)
+	sb, okB := IssuingState(b)
+	return okA && okB && sa == sb
+}
+
+// AuthorityOf returns the authority that issues the passports of the state
+// with the given ICAO code. The code itself is returned for other states.
+func AuthorityOf(state string) string {
+	if authority, ok := authorityByState[state]; ok {
+		return authority
+	}
+	return state
+}
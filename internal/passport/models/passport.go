@@ -2,23 +2,92 @@ package models
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"time"
+
+	"github.com/leeprovoost/go-rest-api-template/pkg/civil"
+	"github.com/leeprovoost/go-rest-api-template/pkg/query"
+	"github.com/leeprovoost/go-rest-api-template/pkg/validate"
 )
 
-// Passport holds passport data.
+// Passport holds passport data. Replaces and ReplacedBy link a renewed
+// passport and its successor.
 type Passport struct {
-	ID           string    `json:"id"`
-	DateOfIssue  time.Time `json:"dateOfIssue"`
-	DateOfExpiry time.Time `json:"dateOfExpiry"`
-	Authority    string    `json:"authority"`
-	UserID       int       `json:"userId"`
+	ID           string         `json:"id" validate:"required"`
+	DateOfIssue  civil.Date     `json:"dateOfIssue" validate:"required,past"`
+	DateOfExpiry civil.Date     `json:"dateOfExpiry" validate:"required,after=DateOfIssue"`
+	Authority    string         `json:"authority" validate:"required"`
+	UserID       int            `json:"userId"`
+	Status       PassportStatus `json:"status" validate:"oneof=issued lost stolen revoked expired replaced"`
+	Replaces     string         `json:"replaces,omitempty"`
+	ReplacedBy   string         `json:"replacedBy,omitempty"`
+}
+
+// Validate checks the document number format and validity period of the
+// issuing authority.
+func (p Passport) Validate(s *validate.Scope) {
+	rules := rulesFor(p.Authority)
+	if p.ID != "" && !rules.number.MatchString(p.ID) {
+		s.Fail("id", "format", fmt.Sprintf("must be %s for authority %s", rules.numberFormat, p.Authority))
+	}
+	if p.DateOfIssue.IsZero() || p.DateOfExpiry.IsZero() {
+		return
+	}
+	if p.DateOfExpiry.After(p.DateOfIssue.AddDate(rules.maxValidityYears, 0, 0)) {
+		s.Fail("dateOfExpiry", "maxValidity", fmt.Sprintf("must be at most %d years after dateOfIssue for authority %s", rules.maxValidityYears, p.Authority))
+	}
 }
 
+// Active reports whether the passport can still be used at now: it is
+// issued, rather than lost or replaced for example, and hasn't expired. A
+// passport expires at the start of its expiry date, in UTC.
+func (p Passport) Active(now time.Time) bool {
+	return (p.Status == StatusIssued || p.Status == "") && p.DateOfExpiry.After(civil.DateOf(now.UTC()))
+}
+
+// Errors returned by PassportStorage implementations, possibly wrapped.
+var (
+	// ErrPassportExists means a passport with the same ID already exists.
+	ErrPassportExists = errors.New("passport already exists")
+	// ErrActivePassportExists means the passport's user already has another
+	// active passport from the same authority, or from another authority of
+	// the same state (see SameIssuer).
+	ErrActivePassportExists = errors.New("user already has an active passport from this issuing state")
+)
+
 // PassportStorage defines all the database operations for passports.
 type PassportStorage interface {
-	ListPassportsByUser(ctx context.Context, userID int) ([]Passport, error)
+	// ListPassportsByUser returns the user's passports matching q, ordered by
+	// q.Sort and then by ID.
+	ListPassportsByUser(ctx context.Context, userID int, q query.Query) ([]Passport, error)
+	// ListPassportsByUsers returns the passports of several users in one call,
+	// keyed by user ID and sorted by passport ID. Every requested user has an
+	// entry, even if it is empty.
+	ListPassportsByUsers(ctx context.Context, userIDs []int) (map[int][]Passport, error)
 	GetPassport(ctx context.Context, id string) (Passport, error)
+	// AddPassport stores a new passport, which is issued unless it has a
+	// status. It fails with ErrPassportExists if the ID is taken and with
+	// ErrActivePassportExists if p is active and its user has another active
+	// passport from the same issuing state.
 	AddPassport(ctx context.Context, p Passport) (Passport, error)
+	// UpdatePassport replaces the details of a passport but keeps its
+	// status and links, which only change through TransitionPassport. Like
+	// AddPassport, it fails with ErrActivePassportExists rather than leave a
+	// user with two active passports from the same issuing state.
 	UpdatePassport(ctx context.Context, p Passport) (Passport, error)
+	// DeletePassport removes a passport and its transitions.
 	DeletePassport(ctx context.Context, id string) error
+	// TransitionPassport moves a passport to status t.To and records t, with
+	// From set to the previous status. It fails with ErrInvalidTransition if
+	// the current status can't move to t.To. A transition to replaced sets
+	// the passport's ReplacedBy to t.ReplacedBy.
+	TransitionPassport(ctx context.Context, id string, t Transition) (Transition, error)
+	// ListTransitions returns the recorded transitions of a passport, oldest
+	// first.
+	ListTransitions(ctx context.Context, id string) ([]Transition, error)
+	// PassportStats counts passports, in total and by authority, and the
+	// expiries in each of the months calendar months starting with the
+	// month of from.
+	PassportStats(ctx context.Context, from time.Time, months int) (PassportStats, error)
 }
@@ -0,0 +1,60 @@
+package models
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/leeprovoost/go-rest-api-template/pkg/civil"
+)
+
+// AgeBands are the lower bounds of the age bands users are counted in, in
+// years: 0–17, 18–24, 25–34 and so on, up to 65 and over.
+var AgeBands = []int{0, 18, 25, 35, 45, 55, 65}
+
+// AgeBandLabel returns the name of the i-th band of AgeBands, such as
+// "18-24" or "65+".
+func AgeBandLabel(i int) string {
+	if i == len(AgeBands)-1 {
+		return strconv.Itoa(AgeBands[i]) + "+"
+	}
+	return strconv.Itoa(AgeBands[i]) + "-" + strconv.Itoa(AgeBands[i+1]-1)
+}
+
+// Age returns the age in whole years, on the day of now in UTC, of someone
+// born on dob.
+func Age(dob civil.Date, now time.Time) int {
+	today := civil.DateOf(now.UTC())
+	age := today.Year - dob.Year
+	if today.Month < dob.Month || today.Month == dob.Month && today.Day < dob.Day {
+		age--
+	}
+	return age
+}
+
+// AgeBand returns the index in AgeBands of the band age falls in.
+func AgeBand(age int) int {
+	band := 0
+	for i, min := range AgeBands {
+		if age >= min {
+			band = i
+		}
+	}
+	return band
+}
+
+// UserStats are aggregate figures about users.
+type UserStats struct {
+	Total int
+	// ByAge counts users by age band, in the order of AgeBands.
+	ByAge []int
+}
+
+// PassportStats are aggregate figures about passports.
+type PassportStats struct {
+	Total       int
+	ByAuthority map[string]int
+	// ExpiriesByMonth counts the issued passports expiring in each calendar
+	// month, starting with the month asked for. Passports that were lost,
+	// replaced and so on aren't counted, as they won't be renewed.
+	ExpiriesByMonth []int
+}
@@ -0,0 +1,61 @@
+package models
+
+import (
+	"errors"
+	"time"
+)
+
+// PassportStatus is the lifecycle state of a passport.
+type PassportStatus string
+
+const (
+	StatusIssued   PassportStatus = "issued"
+	StatusLost     PassportStatus = "lost"
+	StatusStolen   PassportStatus = "stolen"
+	StatusRevoked  PassportStatus = "revoked"
+	StatusExpired  PassportStatus = "expired"
+	StatusReplaced PassportStatus = "replaced"
+)
+
+// statusTransitions lists the statuses each status can move to. A passport
+// that was reported lost or stolen stays cancelled even if it turns up, so
+// the only way on is a replacement. Revoked and replaced passports are final.
+var statusTransitions = map[PassportStatus][]PassportStatus{
+	StatusIssued:   {StatusLost, StatusStolen, StatusRevoked, StatusExpired, StatusReplaced},
+	StatusLost:     {StatusReplaced},
+	StatusStolen:   {StatusReplaced},
+	StatusExpired:  {StatusReplaced},
+	StatusRevoked:  nil,
+	StatusReplaced: nil,
+}
+
+// Next returns the statuses s can move to.
+func (s PassportStatus) Next() []PassportStatus {
+	return statusTransitions[s]
+}
+
+// CanTransitionTo reports whether s can move to status to.
+func (s PassportStatus) CanTransitionTo(to PassportStatus) bool {
+	for _, next := range statusTransitions[s] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
+// Transition is a recorded change of a passport's status. ReplacedBy is the
+// successor of a passport that was replaced by renewing it.
+type Transition struct {
+	From       PassportStatus `json:"from"`
+	To         PassportStatus `json:"to"`
+	Reason     string         `json:"reason"`
+	Actor      string         `json:"actor"`
+	At         time.Time      `json:"at"`
+	ReplacedBy string         `json:"replacedBy,omitempty"`
+}
+
+// ErrInvalidTransition is returned by PassportStorage.TransitionPassport,
+// possibly wrapped, when the passport's status can't move to the requested
+// one.
+var ErrInvalidTransition = errors.New("invalid status transition")
@@ -0,0 +1,16 @@
+package models
+
+import "context"
+
+// Tx is a storage transaction.
+type Tx interface {
+	Commit() error
+	Rollback() error
+}
+
+// Transactor is implemented by storages that support transactions. Begin
+// returns a context bound to the transaction: storage calls made with that
+// context take part in it, and their changes are discarded on Rollback.
+type Transactor interface {
+	Begin(ctx context.Context) (context.Context, Tx, error)
+}
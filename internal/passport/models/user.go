@@ -3,22 +3,28 @@ package models
 import (
 	"context"
 	"time"
+
+	"github.com/leeprovoost/go-rest-api-template/pkg/civil"
+	"github.com/leeprovoost/go-rest-api-template/pkg/query"
 )
 
 // User holds personal user information.
 type User struct {
-	ID              int       `json:"id"`
-	FirstName       string    `json:"firstName"`
-	LastName        string    `json:"lastName"`
-	DateOfBirth     time.Time `json:"dateOfBirth"`
-	LocationOfBirth string    `json:"locationOfBirth"`
+	ID              int        `json:"id"`
+	FirstName       string     `json:"firstName" validate:"required"`
+	LastName        string     `json:"lastName" validate:"required"`
+	DateOfBirth     civil.Date `json:"dateOfBirth" validate:"required"`
+	LocationOfBirth string     `json:"locationOfBirth" validate:"required"`
 }
 
 // UserStorage defines all the database operations for users.
 type UserStorage interface {
-	ListUsers(ctx context.Context) ([]User, error)
+	// ListUsers returns the users matching q, ordered by q.Sort and then by ID.
+	ListUsers(ctx context.Context, q query.Query) ([]User, error)
 	GetUser(ctx context.Context, id int) (User, error)
 	AddUser(ctx context.Context, u User) (User, error)
 	UpdateUser(ctx context.Context, u User) (User, error)
 	DeleteUser(ctx context.Context, id int) error
+	// UserStats counts users, in total and by their age at now.
+	UserStats(ctx context.Context, now time.Time) (UserStats, error)
 }
@@ -0,0 +1,34 @@
+package models
+
+import (
+	"context"
+
+	"github.com/leeprovoost/go-rest-api-template/pkg/civil"
+	"github.com/leeprovoost/go-rest-api-template/pkg/query"
+)
+
+// Visa is a visa in a passport. It allows its holder to enter Country,
+// identified by its ICAO code, between ValidFrom and ValidUntil, as often as
+// Entries allows.
+type Visa struct {
+	ID         int        `json:"id"`
+	PassportID string     `json:"passportId"`
+	Country    string     `json:"country" validate:"required,pattern=^[A-Z]{3}$"`
+	ValidFrom  civil.Date `json:"validFrom" validate:"required"`
+	ValidUntil civil.Date `json:"validUntil" validate:"required,after=ValidFrom"`
+	Entries    string     `json:"entries" validate:"required,oneof=single double multiple"`
+}
+
+// VisaStorage defines all the database operations for visas.
+type VisaStorage interface {
+	// ListVisasByPassport returns the visas in a passport that match q,
+	// ordered by q.Sort and then by ID.
+	ListVisasByPassport(ctx context.Context, passportID string, q query.Query) ([]Visa, error)
+	GetVisa(ctx context.Context, id int) (Visa, error)
+	// AddVisa stores a new visa with an auto-generated ID.
+	AddVisa(ctx context.Context, v Visa) (Visa, error)
+	// UpdateVisa replaces the details of a visa but keeps the passport it is
+	// in.
+	UpdateVisa(ctx context.Context, v Visa) (Visa, error)
+	DeleteVisa(ctx context.Context, id int) error
+}
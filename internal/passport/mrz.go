@@ -0,0 +1,123 @@
+package passport
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/leeprovoost/go-rest-api-template/internal/passport/models"
+	"github.com/leeprovoost/go-rest-api-template/pkg/mrz"
+	"github.com/leeprovoost/go-rest-api-template/pkg/status"
+	"github.com/leeprovoost/go-rest-api-template/pkg/validate"
+)
+
+// mrzRequest is the body of POST /passports/mrz, and mrzResponse the body of
+// GET /passports/{id}/mrz. Both carry the two lines of the MRZ separated by
+// a newline, so a generated MRZ can be parsed again as it is.
+type mrzRequest struct {
+	MRZ string `json:"mrz" validate:"required"`
+}
+
+type mrzResponse struct {
+	MRZ string `json:"mrz"`
+}
+
+// mrzParseResponse is a parsed MRZ: the passport fields it holds, and the
+// fields of its holder. The MRZ has no date of issue, so neither does the
+// passport. Dates are rendered like those of the models in the request's
+// API version.
+type mrzParseResponse struct {
+	Passport mrzPassport `json:"passport"`
+	Holder   mrzHolder   `json:"holder"`
+}
+
+type mrzPassport struct {
+	ID           string `json:"id"`
+	DocumentCode string `json:"documentCode"`
+	IssuingState string `json:"issuingState"`
+	Authority    string `json:"authority"`
+	DateOfExpiry any    `json:"dateOfExpiry"`
+}
+
+type mrzHolder struct {
+	FirstName      string `json:"firstName"`
+	LastName       string `json:"lastName"`
+	DateOfBirth    any    `json:"dateOfBirth"`
+	Nationality    string `json:"nationality"`
+	Sex            string `json:"sex,omitempty"`
+	PersonalNumber string `json:"personalNumber,omitempty"`
+}
+
+// handleParseMRZ parses the MRZ of a scanned passport so that its data
+// doesn't have to be retyped. It doesn't store anything.
+func (s *Server) handleParseMRZ(w http.ResponseWriter, r *http.Request) {
+	var req mrzRequest
+	if !s.decode(w, r, &req, status.CodeMalformedRequest) {
+		return
+	}
+	if errs := validate.Struct(req); len(errs) > 0 {
+		respondValidationErrors(w, errs)
+		return
+	}
+	m, err := mrz.Parse(req.MRZ)
+	if err != nil {
+		respondError(w, status.CodeMRZInvalid, "invalid MRZ: "+err.Error())
+		return
+	}
+	v := versionOf(r)
+	respond(w, http.StatusOK, mrzParseResponse{
+		Passport: mrzPassport{
+			ID:           m.DocumentNumber,
+			DocumentCode: m.DocumentCode,
+			IssuingState: m.IssuingState,
+			Authority:    models.AuthorityOf(m.IssuingState),
+			DateOfExpiry: v.date(m.DateOfExpiry),
+		},
+		Holder: mrzHolder{
+			FirstName:      m.GivenNames,
+			LastName:       m.Surname,
+			DateOfBirth:    v.date(m.DateOfBirth),
+			Nationality:    m.Nationality,
+			Sex:            m.Sex,
+			PersonalNumber: m.PersonalNumber,
+		},
+	})
+}
+
+// handleGetMRZ generates the MRZ of a stored passport from the passport and
+// its user. We don't store the holder's nationality or sex, so the
+// nationality is taken to be the issuing state and the sex is unspecified.
+func (s *Server) handleGetMRZ(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	p, err := s.passportStore.GetPassport(r.Context(), id)
+	if err != nil {
+		respondError(w, status.CodePassportNotFound, "can't find passport")
+		return
+	}
+	u, err := s.userStore.GetUser(r.Context(), p.UserID)
+	if err != nil {
+		s.logger.Error("passport owner not found", "id", id, "userId", p.UserID, "error", err)
+		respondError(w, status.CodeUserNotFound, "can't find the passport's owner")
+		return
+	}
+	state, ok := models.IssuingState(p.Authority)
+	if !ok {
+		respondError(w, status.CodeMRZUnavailable, "the issuing state of authority "+p.Authority+" is not known")
+		return
+	}
+	lines, err := mrz.Passport{
+		DocumentCode:   "P",
+		IssuingState:   state,
+		Surname:        u.LastName,
+		GivenNames:     u.FirstName,
+		DocumentNumber: p.ID,
+		Nationality:    state,
+		DateOfBirth:    u.DateOfBirth.In(time.UTC),
+		DateOfExpiry:   p.DateOfExpiry.In(time.UTC),
+	}.Lines()
+	if err != nil {
+		respondError(w, status.CodeMRZUnavailable, "can't write MRZ: "+strings.ReplaceAll(err.Error(), "\n", "; "))
+		return
+	}
+	respond(w, http.StatusOK, mrzResponse{MRZ: lines[0] + "\n" + lines[1]})
+}
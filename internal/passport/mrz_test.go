@@ -0,0 +1,101 @@
+package passport
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/leeprovoost/go-rest-api-template/internal/passport/models"
+	"github.com/leeprovoost/go-rest-api-template/pkg/civil"
+	"github.com/leeprovoost/go-rest-api-template/pkg/status"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const johnDoeMRZ = "P<GBRDOE<<JOHN<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<\n" +
+	"0123456784GBR8512316<3001156<<<<<<<<<<<<<<<4"
+
+// postMRZ sends an MRZ to POST target.
+func postMRZ(t *testing.T, handler http.Handler, target, mrz string) *httptest.ResponseRecorder {
+	t.Helper()
+	body, err := json.Marshal(mrzRequest{MRZ: mrz})
+	require.NoError(t, err)
+	r := httptest.NewRequest(http.MethodPost, target, strings.NewReader(string(body)))
+	r.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	return w
+}
+
+func TestGetMRZ(t *testing.T) {
+	_, body := getJSON(t, newTestHandler(), "/v2/passports/012345678/mrz")
+	assert.Equal(t, johnDoeMRZ, body["mrz"])
+}
+
+func TestParseMRZ(t *testing.T) {
+	w := postMRZ(t, newTestHandler(), "/v2/passports/mrz", johnDoeMRZ)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var body map[string]any
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	assert.Equal(t, map[string]any{
+		"id":           "012345678",
+		"documentCode": "P",
+		"issuingState": "GBR",
+		"authority":    "HMPO",
+		"dateOfExpiry": "2030-01-15",
+	}, body["passport"])
+	assert.Equal(t, map[string]any{
+		"firstName":   "JOHN",
+		"lastName":    "DOE",
+		"dateOfBirth": "1985-12-31",
+		"nationality": "GBR",
+	}, body["holder"])
+
+	// v1 renders dates as timestamps, like its models.
+	w = postMRZ(t, newTestHandler(), "/v1/passports/mrz", johnDoeMRZ)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"dateOfExpiry":"2030-01-15T00:00:00Z"`)
+}
+
+func TestParseMRZInvalid(t *testing.T) {
+	handler := newTestHandler()
+	bad := johnDoeMRZ[:len(johnDoeMRZ)-1] + "5"
+	w := postMRZ(t, handler, "/passports/mrz", bad)
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	var resp status.Response
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, status.CodeMRZInvalid, resp.Code)
+	assert.Equal(t, "invalid MRZ: line 2: composite check digit is '5', expected '4'", resp.Message)
+
+	w = postMRZ(t, handler, "/passports/mrz", "")
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	assert.Contains(t, w.Body.String(), "mrz is required")
+}
+
+func TestGetMRZUnavailable(t *testing.T) {
+	srv := NewTestServer()
+	_, err := srv.passportStore.AddPassport(context.Background(), models.Passport{
+		ID:           "X1",
+		DateOfIssue:  civil.Date{Year: 2020, Month: time.January, Day: 15},
+		DateOfExpiry: civil.Date{Year: 2030, Month: time.January, Day: 15},
+		Authority:    "XYZ",
+		UserID:       1,
+	})
+	require.NoError(t, err)
+	handler := srv.middleware(srv.routes())
+
+	r := httptest.NewRequest(http.MethodGet, "/passports/X1/mrz", nil)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	assert.Contains(t, w.Body.String(), string(status.CodeMRZUnavailable))
+
+	r = httptest.NewRequest(http.MethodGet, "/passports/nope/mrz", nil)
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
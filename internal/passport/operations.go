@@ -0,0 +1,294 @@
+package passport
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/leeprovoost/go-rest-api-template/pkg/status"
+)
+
+// operationTTL is how long finished operations can still be polled.
+const operationTTL = 24 * time.Hour
+
+// operationRetryAfter is the polling interval suggested to clients, in seconds.
+const operationRetryAfter = "1"
+
+// operationStatus is the state of a long-running operation.
+type operationStatus string
+
+const (
+	operationRunning    operationStatus = "running"
+	operationCancelling operationStatus = "cancelling"
+	operationSucceeded  operationStatus = "succeeded"
+	operationFailed     operationStatus = "failed"
+	operationCancelled  operationStatus = "cancelled"
+)
+
+// operationFunc does the work of an operation. It reports progress through
+// run, should stop early once ctx is cancelled, and returns the result that
+// GET /operations/{id}/result serves.
+type operationFunc func(ctx context.Context, run *operationRun) (any, error)
+
+// operation is a request whose work continues after its response was sent,
+// such as an export or import. Clients poll GET /operations/{id}.
+type operation struct {
+	id        string
+	kind      string
+	status    operationStatus
+	completed int
+	total     int
+	errors    []status.Response
+	result    any
+	created   time.Time
+	updated   time.Time
+	cancel    context.CancelFunc
+}
+
+// finished reports whether the operation has reached a final state.
+func (op *operation) finished() bool {
+	return op.status == operationSucceeded || op.status == operationFailed || op.status == operationCancelled
+}
+
+// operationStore runs operations in the background and keeps their state.
+// Note: like the idempotency store, this is per-instance and in memory, so
+// operations are lost on restart. For distributed systems, persist them and
+// run the work from a queue.
+type operationStore struct {
+	mu  sync.Mutex
+	wg  sync.WaitGroup
+	now func() time.Time
+	ops map[string]*operation
+}
+
+func newOperationStore() *operationStore {
+	return &operationStore{
+		now: time.Now,
+		ops: make(map[string]*operation),
+	}
+}
+
+// start runs fn in the background and returns the ID of its operation. The
+// work is detached from the request, so it outlives the response.
+func (st *operationStore) start(kind string, fn operationFunc) string {
+	ctx, cancel := context.WithCancel(context.Background())
+	st.mu.Lock()
+	now := st.now()
+	for id, op := range st.ops {
+		if op.finished() && now.Sub(op.updated) > operationTTL {
+			delete(st.ops, id)
+		}
+	}
+	op := &operation{
+		id:      generateID(),
+		kind:    kind,
+		status:  operationRunning,
+		created: now,
+		updated: now,
+		cancel:  cancel,
+	}
+	st.ops[op.id] = op
+	st.mu.Unlock()
+
+	st.wg.Add(1)
+	go func() {
+		defer st.wg.Done()
+		defer cancel()
+		result, err := fn(ctx, &operationRun{st: st, op: op})
+		st.finish(op, result, err, ctx.Err() != nil)
+	}()
+	return op.id
+}
+
+// finish records the outcome of an operation.
+func (st *operationStore) finish(op *operation, result any, err error, cancelled bool) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	op.updated = st.now()
+	switch {
+	case cancelled:
+		op.status = operationCancelled
+	case err != nil:
+		op.status = operationFailed
+		op.errors = append(op.errors, status.New(status.CodeInternal, "operation failed"))
+	case len(op.errors) > 0:
+		op.status = operationFailed
+		op.result = result
+	default:
+		op.status = operationSucceeded
+		op.result = result
+	}
+}
+
+// get returns a copy of the operation with the given ID.
+func (st *operationStore) get(id string) (operation, bool) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	op, ok := st.ops[id]
+	if !ok {
+		return operation{}, false
+	}
+	return *op, true
+}
+
+// cancel asks a running operation to stop. It returns the operation and
+// whether it was still running.
+func (st *operationStore) cancel(id string) (operation, bool, bool) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	op, ok := st.ops[id]
+	if !ok {
+		return operation{}, false, false
+	}
+	if op.finished() {
+		return *op, true, false
+	}
+	op.cancel()
+	op.status = operationCancelling
+	op.updated = st.now()
+	return *op, true, true
+}
+
+// shutdown cancels every running operation and waits for them to stop, or
+// for ctx to expire.
+func (st *operationStore) shutdown(ctx context.Context) {
+	st.mu.Lock()
+	for _, op := range st.ops {
+		if !op.finished() {
+			op.cancel()
+		}
+	}
+	st.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		st.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+	}
+}
+
+// operationRun is how an operationFunc reports progress.
+type operationRun struct {
+	st *operationStore
+	op *operation
+}
+
+// setTotal sets the number of items the operation will process.
+func (run *operationRun) setTotal(n int) {
+	run.st.mu.Lock()
+	defer run.st.mu.Unlock()
+	run.op.total = n
+	run.op.updated = run.st.now()
+}
+
+// advance marks n more items as processed.
+func (run *operationRun) advance(n int) {
+	run.st.mu.Lock()
+	defer run.st.mu.Unlock()
+	run.op.completed += n
+	run.op.updated = run.st.now()
+}
+
+// fail records an error for a single item. The operation carries on, but
+// finishes as failed.
+func (run *operationRun) fail(code status.Code, message string) {
+	run.st.mu.Lock()
+	defer run.st.mu.Unlock()
+	run.op.errors = append(run.op.errors, status.New(code, message))
+	run.op.updated = run.st.now()
+}
+
+// --- Handlers ---
+
+// operationResponse is the representation of an operation.
+type operationResponse struct {
+	ID       string            `json:"id"`
+	Kind     string            `json:"kind"`
+	Status   operationStatus   `json:"status"`
+	Progress operationProgress `json:"progress"`
+	Errors   []status.Response `json:"errors,omitempty"`
+	Created  time.Time         `json:"createdAt"`
+	Updated  time.Time         `json:"updatedAt"`
+	Links    map[string]link   `json:"links"`
+}
+
+type operationProgress struct {
+	Completed int `json:"completed"`
+	Total     int `json:"total"`
+}
+
+func newOperationResponse(v *apiVersion, op operation) operationResponse {
+	self := v.prefix + "/operations/" + op.id
+	links := map[string]link{"self": {Href: self}}
+	if op.result != nil {
+		links["result"] = link{Href: self + "/result"}
+	}
+	return operationResponse{
+		ID:       op.id,
+		Kind:     op.kind,
+		Status:   op.status,
+		Progress: operationProgress{Completed: op.completed, Total: op.total},
+		Errors:   op.errors,
+		Created:  op.created,
+		Updated:  op.updated,
+		Links:    links,
+	}
+}
+
+// respondOperation writes an operation, asking clients to poll again while
+// it is still running.
+func respondOperation(w http.ResponseWriter, r *http.Request, code int, op operation) {
+	if !op.finished() {
+		w.Header().Set("Retry-After", operationRetryAfter)
+	}
+	respond(w, code, newOperationResponse(versionOf(r), op))
+}
+
+// acceptOperation starts fn and responds with 202 Accepted and the location
+// of the new operation.
+func (s *Server) acceptOperation(w http.ResponseWriter, r *http.Request, kind string, fn operationFunc) {
+	id := s.operations.start(kind, fn)
+	op, _ := s.operations.get(id)
+	w.Header().Set("Location", versionOf(r).prefix+"/operations/"+id)
+	respondOperation(w, r, http.StatusAccepted, op)
+}
+
+func (s *Server) handleGetOperation(w http.ResponseWriter, r *http.Request) {
+	op, ok := s.operations.get(r.PathValue("id"))
+	if !ok {
+		respondError(w, status.CodeOperationNotFound, "can't find operation")
+		return
+	}
+	respondOperation(w, r, http.StatusOK, op)
+}
+
+func (s *Server) handleGetOperationResult(w http.ResponseWriter, r *http.Request) {
+	op, ok := s.operations.get(r.PathValue("id"))
+	if !ok {
+		respondError(w, status.CodeOperationNotFound, "can't find operation")
+		return
+	}
+	if op.result == nil {
+		respondError(w, status.CodeOperationResultUnavailable, "operation has no result")
+		return
+	}
+	respond(w, http.StatusOK, op.result)
+}
+
+func (s *Server) handleCancelOperation(w http.ResponseWriter, r *http.Request) {
+	op, ok, running := s.operations.cancel(r.PathValue("id"))
+	if !ok {
+		respondError(w, status.CodeOperationNotFound, "can't find operation")
+		return
+	}
+	if !running {
+		respondError(w, status.CodeOperationFinished, "operation has already finished")
+		return
+	}
+	respondOperation(w, r, http.StatusAccepted, op)
+}
@@ -0,0 +1,189 @@
+package passport
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/leeprovoost/go-rest-api-template/pkg/status"
+	"github.com/leeprovoost/go-rest-api-template/pkg/validate"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// startOperation sends a request that should start an operation and returns
+// the operation's location.
+func startOperation(t *testing.T, handler http.Handler, target, body string) string {
+	t.Helper()
+	r := httptest.NewRequest(http.MethodPost, target, strings.NewReader(body))
+	r.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	require.Equal(t, http.StatusAccepted, w.Code, w.Body.String())
+	assert.Equal(t, "1", w.Header().Get("Retry-After"))
+	location := w.Header().Get("Location")
+	require.NotEmpty(t, location)
+	return location
+}
+
+// waitForOperation polls an operation until it has finished.
+func waitForOperation(t *testing.T, handler http.Handler, location string) operationResponse {
+	t.Helper()
+	var op operationResponse
+	require.Eventually(t, func() bool {
+		w, body := getJSON(t, handler, location)
+		dat, _ := json.Marshal(body)
+		require.NoError(t, json.Unmarshal(dat, &op))
+		return w.Header().Get("Retry-After") == ""
+	}, time.Second, 5*time.Millisecond)
+	return op
+}
+
+func TestExportOperation(t *testing.T) {
+	handler := newTestHandler()
+
+	location := startOperation(t, handler, "/v2/exports?lastName=Doe&sort=-id", "")
+	assert.True(t, strings.HasPrefix(location, "/v2/operations/"))
+
+	op := waitForOperation(t, handler, location)
+	assert.Equal(t, "export", op.Kind)
+	assert.Equal(t, operationSucceeded, op.Status)
+	assert.Equal(t, operationProgress{Completed: 2, Total: 2}, op.Progress)
+	assert.Empty(t, op.Errors)
+	assert.Equal(t, location+"/result", op.Links["result"].Href)
+
+	_, result := getJSON(t, handler, op.Links["result"].Href)
+	assert.Equal(t, float64(2), result["count"])
+	users := result["users"].([]any)
+	jane := users[0].(map[string]any)
+	assert.Equal(t, "Jane", jane["firstName"])
+	assert.Equal(t, "1992-01-01", jane["dateOfBirth"])
+	assert.Len(t, jane["passports"], 1)
+}
+
+func TestImportOperation(t *testing.T) {
+	handler := newTestHandler()
+
+	location := startOperation(t, handler, "/imports", `{"users": [
+		{"firstName":"Apple","lastName":"Jack","dateOfBirth":"1972-03-07T00:00:00Z","locationOfBirth":"Cambridge",
+		 "passports": [{"id":"111111111","dateOfIssue":"2020-01-15T00:00:00Z","dateOfExpiry":"2030-01-15T00:00:00Z","authority":"HMPO",
+		                "status":"replaced","replaces":"012345678","replacedBy":"222222222"}]},
+		{"firstName":"Pear","lastName":"Jack","dateOfBirth":"1975-05-09T00:00:00Z","locationOfBirth":"Oxford",
+		 "passports": [{"id":"987654321","dateOfIssue":"2020-01-15T00:00:00Z","dateOfExpiry":"2030-01-15T00:00:00Z","authority":"HMPO"}]}
+	]}`)
+
+	op := waitForOperation(t, handler, location)
+	assert.Equal(t, "import", op.Kind)
+	assert.Equal(t, operationFailed, op.Status)
+	assert.Equal(t, operationProgress{Completed: 2, Total: 2}, op.Progress)
+	require.Len(t, op.Errors, 1)
+	assert.Equal(t, status.CodePassportDuplicate, op.Errors[0].Code)
+	assert.Contains(t, op.Errors[0].Message, "users[1].passports[0]")
+
+	_, result := getJSON(t, handler, op.Links["result"].Href)
+	assert.Equal(t, []any{
+		map[string]any{"href": "/users/2"},
+		map[string]any{"href": "/users/3"},
+	}, result["users"])
+	_, passport := getJSON(t, handler, "/passports/111111111")
+	assert.Equal(t, float64(2), passport["userId"])
+	// Imported passports are issued, without links.
+	assert.Equal(t, "issued", passport["status"])
+	assert.NotContains(t, passport, "replaces")
+	assert.NotContains(t, passport, "replacedBy")
+}
+
+func TestImportValidation(t *testing.T) {
+	handler := newTestHandler()
+
+	r := httptest.NewRequest(http.MethodPost, "/imports", strings.NewReader(`{"users": [
+		{"firstName":"Apple","lastName":"Jack","dateOfBirth":"1972-03-07T00:00:00Z","locationOfBirth":"Cambridge",
+		 "passports": [{"id":"111111111","authority":"HMPO"}]}
+	]}`))
+	r.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	var resp status.Response
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Contains(t, resp.Errors, "users[0].passports[0].dateOfIssue is required")
+	assert.Contains(t, resp.Fields, validate.Error{
+		Pointer: "/users/0/passports/0/dateOfIssue",
+		Rule:    "required",
+		Message: "users[0].passports[0].dateOfIssue is required",
+	})
+}
+
+func TestCancelOperation(t *testing.T) {
+	srv := NewTestServer()
+	handler := srv.middleware(srv.routes())
+	started := make(chan struct{})
+	id := srv.operations.start("test", func(ctx context.Context, run *operationRun) (any, error) {
+		run.setTotal(10)
+		run.advance(3)
+		close(started)
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+	<-started
+
+	w, body := getJSON(t, handler, "/operations/"+id)
+	assert.Equal(t, "running", body["status"])
+	assert.Equal(t, map[string]any{"completed": float64(3), "total": float64(10)}, body["progress"])
+	assert.Equal(t, "1", w.Header().Get("Retry-After"))
+
+	r := httptest.NewRequest(http.MethodGet, "/operations/"+id+"/result", nil)
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Contains(t, w.Body.String(), string(status.CodeOperationResultUnavailable))
+
+	r = httptest.NewRequest(http.MethodDelete, "/operations/"+id, nil)
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	assert.Equal(t, http.StatusAccepted, w.Code)
+	assert.Contains(t, w.Body.String(), `"status":"cancelling"`)
+
+	op := waitForOperation(t, handler, "/operations/"+id)
+	assert.Equal(t, operationCancelled, op.Status)
+	assert.NotContains(t, op.Links, "result")
+
+	r = httptest.NewRequest(http.MethodDelete, "/operations/"+id, nil)
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Contains(t, w.Body.String(), string(status.CodeOperationFinished))
+}
+
+func TestOperationNotFound(t *testing.T) {
+	handler := newTestHandler()
+	for _, method := range []string{http.MethodGet, http.MethodDelete} {
+		r := httptest.NewRequest(method, "/operations/nope", nil)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+		assert.Contains(t, w.Body.String(), string(status.CodeOperationNotFound))
+	}
+}
+
+func TestOperationStoreShutdown(t *testing.T) {
+	st := newOperationStore()
+	id := st.start("test", func(ctx context.Context, _ *operationRun) (any, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	st.shutdown(ctx)
+	require.NoError(t, ctx.Err())
+
+	op, ok := st.get(id)
+	require.True(t, ok)
+	assert.Equal(t, operationCancelled, op.status)
+}
@@ -0,0 +1,91 @@
+package passport
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// parsePagination reads the offset and limit query parameters, falling back
+// to the defaults for missing or out-of-range values.
+func parsePagination(r *http.Request) (offset, limit int) {
+	offset, _ = strconv.Atoi(r.URL.Query().Get("offset"))
+	limit, _ = strconv.Atoi(r.URL.Query().Get("limit"))
+	return clampPagination(offset, limit)
+}
+
+// clampPagination replaces out-of-range offset and limit values with the
+// defaults.
+func clampPagination(offset, limit int) (int, int) {
+	if offset < 0 {
+		offset = 0
+	}
+	if limit <= 0 || limit > 100 {
+		limit = 25
+	}
+	return offset, limit
+}
+
+// paginate returns the slice of list selected by offset and limit.
+func paginate[T any](list []T, offset, limit int) []T {
+	if offset > len(list) {
+		return []T{}
+	}
+	end := min(offset+limit, len(list))
+	return list[offset:end]
+}
+
+// page describes the pagination state of a list response.
+type page struct {
+	Offset int
+	Limit  int
+	Total  int
+}
+
+// links returns the self, first, last and, where they exist, prev and next
+// links for the page, keeping every other query parameter of u intact. Past
+// the end of the list, prev leads back to the last page.
+func (p page) links(u *url.URL) map[string]link {
+	at := func(offset int) link {
+		q := u.Query()
+		q.Set("offset", strconv.Itoa(offset))
+		q.Set("limit", strconv.Itoa(p.Limit))
+		return link{Href: u.Path + "?" + q.Encode()}
+	}
+	last := 0
+	if p.Total > 0 {
+		last = (p.Total - 1) / p.Limit * p.Limit
+	}
+	links := map[string]link{
+		"self":  at(p.Offset),
+		"first": at(0),
+		"last":  at(last),
+	}
+	if p.Offset > 0 {
+		links["prev"] = at(min(max(p.Offset-p.Limit, 0), last))
+	}
+	if p.Offset+p.Limit < p.Total {
+		links["next"] = at(p.Offset + p.Limit)
+	}
+	return links
+}
+
+// linkRelations is the order in which pagination links appear in the Link
+// header.
+var linkRelations = []string{"first", "prev", "next", "last"}
+
+// setHeaders writes the RFC 8288 Link header with the page's navigation links,
+// and X-Total-Count with the total number of items.
+func (p page) setHeaders(w http.ResponseWriter, u *url.URL) {
+	links := p.links(u)
+	var parts []string
+	for _, rel := range linkRelations {
+		if l, ok := links[rel]; ok {
+			parts = append(parts, fmt.Sprintf("<%s>; rel=%q", l.Href, rel))
+		}
+	}
+	w.Header().Set("Link", strings.Join(parts, ", "))
+	w.Header().Set("X-Total-Count", strconv.Itoa(p.Total))
+}
@@ -0,0 +1,72 @@
+package passport
+
+import (
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPaginate(t *testing.T) {
+	list := []int{1, 2, 3, 4, 5}
+	assert.Equal(t, []int{1, 2}, paginate(list, 0, 2))
+	assert.Equal(t, []int{5}, paginate(list, 4, 2))
+	assert.Equal(t, []int{}, paginate(list, 5, 2))
+	assert.Equal(t, []int{}, paginate(list, 10, 2))
+}
+
+func TestPageLinks(t *testing.T) {
+	u, _ := url.Parse("/users?lastName=Doe&offset=10&limit=10")
+	links := page{Offset: 10, Limit: 10, Total: 35}.links(u)
+
+	assert.Equal(t, "/users?lastName=Doe&limit=10&offset=10", links["self"].Href)
+	assert.Equal(t, "/users?lastName=Doe&limit=10&offset=0", links["first"].Href)
+	assert.Equal(t, "/users?lastName=Doe&limit=10&offset=0", links["prev"].Href)
+	assert.Equal(t, "/users?lastName=Doe&limit=10&offset=20", links["next"].Href)
+	assert.Equal(t, "/users?lastName=Doe&limit=10&offset=30", links["last"].Href)
+}
+
+func TestPageLinksFirstAndLastPage(t *testing.T) {
+	u, _ := url.Parse("/users")
+
+	links := page{Offset: 0, Limit: 25, Total: 2}.links(u)
+	assert.NotContains(t, links, "prev")
+	assert.NotContains(t, links, "next")
+	assert.Equal(t, "/users?limit=25&offset=0", links["last"].Href)
+
+	links = page{Offset: 0, Limit: 25, Total: 0}.links(u)
+	assert.Equal(t, "/users?limit=25&offset=0", links["last"].Href)
+
+	links = page{Offset: 3, Limit: 2, Total: 4}.links(u)
+	assert.Equal(t, "/users?limit=2&offset=1", links["prev"].Href)
+	assert.NotContains(t, links, "next")
+}
+
+func TestPageLinksPastTheEnd(t *testing.T) {
+	u, _ := url.Parse("/users")
+
+	links := page{Offset: 100, Limit: 10, Total: 35}.links(u)
+	assert.Equal(t, "/users?limit=10&offset=30", links["prev"].Href)
+	assert.Equal(t, "/users?limit=10&offset=30", links["last"].Href)
+	assert.NotContains(t, links, "next")
+
+	// Just past the end, the previous page still holds the final items.
+	links = page{Offset: 35, Limit: 10, Total: 35}.links(u)
+	assert.Equal(t, "/users?limit=10&offset=25", links["prev"].Href)
+
+	links = page{Offset: 50, Limit: 25, Total: 0}.links(u)
+	assert.Equal(t, "/users?limit=25&offset=0", links["prev"].Href)
+}
+
+func TestPageSetHeaders(t *testing.T) {
+	u, _ := url.Parse("/users?offset=2&limit=2")
+	w := httptest.NewRecorder()
+	page{Offset: 2, Limit: 2, Total: 5}.setHeaders(w, u)
+
+	assert.Equal(t, "5", w.Header().Get("X-Total-Count"))
+	assert.Equal(t,
+		`</users?limit=2&offset=0>; rel="first", </users?limit=2&offset=0>; rel="prev", `+
+			`</users?limit=2&offset=4>; rel="next", </users?limit=2&offset=4>; rel="last"`,
+		w.Header().Get("Link"))
+}
@@ -0,0 +1,105 @@
+package passport
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/leeprovoost/go-rest-api-template/internal/passport/models"
+	"github.com/leeprovoost/go-rest-api-template/pkg/civil"
+	"github.com/leeprovoost/go-rest-api-template/pkg/status"
+	"github.com/leeprovoost/go-rest-api-template/pkg/validate"
+)
+
+// renewRequest is the body of POST /passports/{id}/renew: the successor's
+// details and who renewed it. The successor belongs to the same user and,
+// unless Authority is set, is issued by the same authority.
+type renewRequest struct {
+	ID           string     `json:"id"`
+	DateOfIssue  civil.Date `json:"dateOfIssue"`
+	DateOfExpiry civil.Date `json:"dateOfExpiry"`
+	Authority    string     `json:"authority"`
+	Actor        string     `json:"actor" validate:"required,max=100"`
+}
+
+// handleRenewPassport replaces a passport by a new one. In one transaction it
+// marks the old passport replaced, linking it to its successor, and adds the
+// successor, linked back to it; if either step fails, neither happens.
+func (s *Server) handleRenewPassport(w http.ResponseWriter, r *http.Request) {
+	var req renewRequest
+	if !s.decode(w, r, &req, status.CodeMalformedPassport) {
+		return
+	}
+
+	ctx, tx, err := s.beginTx(r.Context())
+	if errors.Is(err, errTxUnsupported) {
+		respondError(w, status.CodeTransactionsUnsupported, "renewals are not supported by this storage backend")
+		return
+	}
+	if err != nil {
+		s.logger.Error("failed to begin transaction", "error", err)
+		respondError(w, status.CodeInternal, "failed to begin transaction")
+		return
+	}
+	defer tx.Rollback()
+
+	id := r.PathValue("id")
+	old, err := s.passportStore.GetPassport(ctx, id)
+	if err != nil {
+		respondError(w, status.CodePassportNotFound, "can't find passport")
+		return
+	}
+	successor := models.Passport{
+		ID:           req.ID,
+		DateOfIssue:  req.DateOfIssue,
+		DateOfExpiry: req.DateOfExpiry,
+		Authority:    req.Authority,
+		UserID:       old.UserID,
+		Replaces:     old.ID,
+	}
+	if successor.Authority == "" {
+		successor.Authority = old.Authority
+	}
+	errs := validate.Struct(successor)
+	errs = append(errs, validate.Struct(req)...)
+	if len(errs) > 0 {
+		respondValidationErrors(w, errs)
+		return
+	}
+
+	// The old passport goes first, so that it is no longer active when its
+	// successor is checked against the user's other passports.
+	_, err = s.passportStore.TransitionPassport(ctx, id, models.Transition{
+		To:         models.StatusReplaced,
+		Reason:     "renewed as " + successor.ID,
+		Actor:      req.Actor,
+		At:         time.Now().UTC(),
+		ReplacedBy: successor.ID,
+	})
+	if errors.Is(err, models.ErrInvalidTransition) {
+		respondError(w, status.CodeInvalidTransition, err.Error())
+		return
+	}
+	if err != nil {
+		s.logger.Error("failed to replace passport", "id", id, "error", err)
+		respondError(w, status.CodeInternal, "something went wrong")
+		return
+	}
+	created, err := s.passportStore.AddPassport(ctx, successor)
+	if code, ok := passportConflict(err); ok {
+		respondError(w, code, err.Error())
+		return
+	}
+	if err != nil {
+		s.logger.Error("failed to create passport", "error", err)
+		respondError(w, status.CodeInternal, "something went wrong")
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		s.logger.Error("failed to commit transaction", "error", err)
+		respondError(w, status.CodeInternal, "failed to commit transaction")
+		return
+	}
+	s.logger.Info("passport renewed", "id", id, "successor", created.ID, "actor", req.Actor)
+	respondPassport(w, r, http.StatusCreated, created, nil)
+}
@@ -0,0 +1,114 @@
+package passport
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/leeprovoost/go-rest-api-template/internal/passport/models"
+	"github.com/leeprovoost/go-rest-api-template/pkg/status"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const renewal = `{"id":"111111111","dateOfIssue":"2024-03-01T00:00:00Z","dateOfExpiry":"2034-03-01T00:00:00Z","actor":"jdoe"}`
+
+func TestRenewPassport(t *testing.T) {
+	handler := newTestHandler()
+
+	w := postWithKey(handler, "/v2/passports/012345678/renew", "", renewal)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	var successor map[string]any
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &successor))
+	assert.Equal(t, map[string]any{
+		"id":           "111111111",
+		"dateOfIssue":  "2024-03-01",
+		"dateOfExpiry": "2034-03-01",
+		"authority":    "HMPO",
+		"userId":       float64(0),
+		"status":       "issued",
+		"replaces":     "012345678",
+	}, successor)
+
+	_, old := getJSON(t, handler, "/v2/passports/012345678")
+	assert.Equal(t, "replaced", old["status"])
+	assert.Equal(t, "111111111", old["replacedBy"])
+
+	_, body := getJSON(t, handler, "/passports/012345678/transitions")
+	transitions := body["transitions"].([]any)
+	require.Len(t, transitions, 1)
+	assert.Equal(t, "111111111", transitions[0].(map[string]any)["replacedBy"])
+	assert.Equal(t, "jdoe", transitions[0].(map[string]any)["actor"])
+
+	hal := getHAL(t, handler, "/v2/passports/111111111")
+	assert.Equal(t, "/v2/passports/012345678", href(t, hal, "replaces"))
+	hal = getHAL(t, handler, "/v2/passports/012345678")
+	assert.Equal(t, "/v2/passports/111111111", href(t, hal, "replacedBy"))
+
+	// A replaced passport can't be renewed again.
+	w = postWithKey(handler, "/passports/012345678/renew", "", `{"id":"222222222","dateOfIssue":"2024-03-01T00:00:00Z","dateOfExpiry":"2034-03-01T00:00:00Z","actor":"jdoe"}`)
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Contains(t, w.Body.String(), string(status.CodeInvalidTransition))
+}
+
+func TestRenewPassportIsAtomic(t *testing.T) {
+	srv := NewTestServer()
+	handler := srv.middleware(srv.routes())
+
+	// The successor's ID is taken, so the old passport must stay issued.
+	w := postWithKey(handler, "/passports/012345678/renew", "", `{"id":"987654321","dateOfIssue":"2024-03-01T00:00:00Z","dateOfExpiry":"2034-03-01T00:00:00Z","actor":"jdoe"}`)
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Contains(t, w.Body.String(), string(status.CodePassportDuplicate))
+
+	p, err := srv.passportStore.GetPassport(context.Background(), "012345678")
+	require.NoError(t, err)
+	assert.Equal(t, models.StatusIssued, p.Status)
+	assert.Empty(t, p.ReplacedBy)
+	transitions, err := srv.passportStore.ListTransitions(context.Background(), "012345678")
+	require.NoError(t, err)
+	assert.Empty(t, transitions)
+}
+
+func TestRenewPassportInAtomicBatch(t *testing.T) {
+	handler := newTestHandler()
+
+	// The renewal joins the batch's transaction.
+	w, resp := postBatch(t, handler, `{"atomic":true,"operations":[
+		{"method":"POST","path":"/passports/012345678/renew","body":`+renewal+`}
+	]}`)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, http.StatusCreated, resp.Results[0].Status, string(resp.Results[0].Body))
+	require.NotNil(t, resp.Committed)
+	assert.True(t, *resp.Committed)
+	_, old := getJSON(t, handler, "/passports/012345678")
+	assert.Equal(t, "replaced", old["status"])
+
+	// A later failure rolls the renewal back with the rest of the batch.
+	w, resp = postBatch(t, handler, `{"atomic":true,"operations":[
+		{"method":"POST","path":"/passports/987654321/renew","body":{"id":"222222222","dateOfIssue":"2024-03-01","dateOfExpiry":"2034-03-01","actor":"jdoe"}},
+		{"method":"GET","path":"/users/99"}
+	]}`)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, http.StatusCreated, resp.Results[0].Status, string(resp.Results[0].Body))
+	assert.False(t, *resp.Committed)
+	_, old = getJSON(t, handler, "/passports/987654321")
+	assert.Equal(t, "issued", old["status"])
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/passports/222222222", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestRenewPassportErrors(t *testing.T) {
+	handler := newTestHandler()
+
+	w := postWithKey(handler, "/passports/012345678/renew", "", `{"id":"ABC","dateOfIssue":"2024-03-01T00:00:00Z"}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	assert.Contains(t, w.Body.String(), "dateOfExpiry is required")
+	assert.Contains(t, w.Body.String(), "id must be 9 digits for authority HMPO")
+	assert.Contains(t, w.Body.String(), "actor is required")
+
+	w = postWithKey(handler, "/passports/nope/renew", "", renewal)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
@@ -7,20 +7,74 @@ func (s *Server) routes() http.Handler {
 
 	mux.HandleFunc("GET /healthcheck", s.handleHealthcheck)
 	mux.HandleFunc("GET /ready", s.handleReady)
+	mux.HandleFunc("GET /errors", s.handleListErrorCodes)
+
+	// Batch
+	mux.HandleFunc("POST /batch", s.idempotent(s.handleBatch))
+
+	// GraphQL
+	mux.HandleFunc("POST /graphql", s.idempotent(s.handleGraphQL))
+
+	for _, v := range apiVersions {
+		s.mountAPI(mux, v)
+	}
+
+	return mux
+}
+
+// mountAPI registers the resource routes under the prefix of version v.
+func (s *Server) mountAPI(mux *http.ServeMux, v *apiVersion) {
+	handle := func(method, path string, h http.HandlerFunc) {
+		mux.Handle(method+" "+v.prefix+path, s.versioned(v, h))
+	}
 
 	// Users
-	mux.HandleFunc("GET /users", s.handleListUsers)
-	mux.HandleFunc("GET /users/{id}", s.handleGetUser)
-	mux.HandleFunc("POST /users", s.handleCreateUser)
-	mux.HandleFunc("PUT /users/{id}", s.handleUpdateUser)
-	mux.HandleFunc("DELETE /users/{id}", s.handleDeleteUser)
+	handle("GET", "/users", variesByAccept(s.handleListUsers))
+	handle("GET", "/users/{id}", variesByAccept(s.handleGetUser))
+	handle("POST", "/users", variesByAccept(s.idempotent(s.handleCreateUser)))
+	handle("PUT", "/users/{id}", variesByAccept(s.handleUpdateUser))
+	handle("DELETE", "/users/{id}", s.handleDeleteUser)
+	handle("GET", "/users/{id}/duplicates", s.handleListDuplicates)
+	handle("POST", "/users/{id}/merge", variesByAccept(s.idempotent(s.handleMergeUsers)))
+
+	// Search
+	handle("GET", "/search", s.handleSearch)
+
+	// Reporting
+	handle("GET", "/stats", s.handleStats)
 
 	// Passports
-	mux.HandleFunc("GET /users/{uid}/passports", s.handleListUserPassports)
-	mux.HandleFunc("GET /passports/{id}", s.handleGetPassport)
-	mux.HandleFunc("POST /users/{uid}/passports", s.handleCreatePassport)
-	mux.HandleFunc("PUT /passports/{id}", s.handleUpdatePassport)
-	mux.HandleFunc("DELETE /passports/{id}", s.handleDeletePassport)
+	handle("GET", "/users/{uid}/passports", variesByAccept(s.handleListUserPassports))
+	handle("GET", "/passports/{id}", variesByAccept(s.handleGetPassport))
+	handle("POST", "/users/{uid}/passports", variesByAccept(s.idempotent(s.handleCreatePassport)))
+	handle("PUT", "/passports/{id}", variesByAccept(s.handleUpdatePassport))
+	handle("DELETE", "/passports/{id}", s.handleDeletePassport)
+	handle("POST", "/passports/mrz", s.handleParseMRZ)
+	handle("GET", "/passports/{id}/mrz", s.handleGetMRZ)
+	handle("GET", "/passports/{id}/transitions", s.handleListTransitions)
+	handle("POST", "/passports/{id}/transitions", s.handleTransitionPassport)
+	handle("POST", "/passports/{id}/renew", variesByAccept(s.idempotent(s.handleRenewPassport)))
 
-	return mux
+	// Attachments
+	handle("GET", "/passports/{id}/attachments", s.handleListAttachments)
+	handle("POST", "/passports/{id}/attachments", s.outsideTx(s.handleUploadAttachment))
+	handle("GET", "/passports/{id}/attachments/{aid}", s.handleDownloadAttachment)
+	handle("DELETE", "/passports/{id}/attachments/{aid}", s.outsideTx(s.handleDeleteAttachment))
+
+	// Visas
+	handle("GET", "/passports/{id}/visas", variesByAccept(s.handleListVisas))
+	handle("GET", "/passports/{id}/visas/{vid}", variesByAccept(s.handleGetVisa))
+	handle("POST", "/passports/{id}/visas", variesByAccept(s.idempotent(s.handleCreateVisa)))
+	handle("PUT", "/passports/{id}/visas/{vid}", variesByAccept(s.handleUpdateVisa))
+	handle("DELETE", "/passports/{id}/visas/{vid}", s.handleDeleteVisa)
+
+	// Identity verification
+	handle("POST", "/verifications", s.handleVerify)
+
+	// Exports, imports and the operations that run them
+	handle("POST", "/exports", s.outsideTx(s.idempotent(s.handleExport)))
+	handle("POST", "/imports", s.outsideTx(s.idempotent(s.handleImport)))
+	handle("GET", "/operations/{id}", s.handleGetOperation)
+	handle("GET", "/operations/{id}/result", s.handleGetOperationResult)
+	handle("DELETE", "/operations/{id}", s.outsideTx(s.handleCancelOperation))
 }
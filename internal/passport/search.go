@@ -0,0 +1,193 @@
+package passport
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/leeprovoost/go-rest-api-template/internal/passport/models"
+	"github.com/leeprovoost/go-rest-api-template/pkg/query"
+	"github.com/leeprovoost/go-rest-api-template/pkg/search"
+	"github.com/leeprovoost/go-rest-api-template/pkg/status"
+)
+
+// userIndex maintains a full-text index of users. Each user document also
+// carries the issuing authorities of the user's passports, so a search for
+// "doe hmpo" finds users called Doe holding an HMPO passport.
+type userIndex struct {
+	index     *search.Index
+	users     models.UserStorage
+	passports models.PassportStorage
+	logger    *slog.Logger
+}
+
+func newUserIndex(users models.UserStorage, passports models.PassportStorage, logger *slog.Logger) *userIndex {
+	return &userIndex{
+		index:     search.New(),
+		users:     users,
+		passports: passports,
+		logger:    logger,
+	}
+}
+
+// rebuild indexes every user in the store.
+func (ui *userIndex) rebuild(ctx context.Context) error {
+	list, err := ui.users.ListUsers(ctx, query.Query{})
+	if err != nil {
+		return err
+	}
+	for _, u := range list {
+		ui.reindex(ctx, u.ID)
+	}
+	return nil
+}
+
+// reindex refreshes the document for a user, removing it if the user no
+// longer exists.
+func (ui *userIndex) reindex(ctx context.Context, uid int) {
+	touch(ctx, uid)
+	id := strconv.Itoa(uid)
+	u, err := ui.users.GetUser(ctx, uid)
+	if err != nil {
+		ui.index.Delete(id)
+		return
+	}
+	passports, err := ui.passports.ListPassportsByUser(ctx, uid, query.Query{})
+	if err != nil {
+		ui.logger.Error("failed to index passports", "userId", uid, "error", err)
+	}
+	ui.index.Index(userDocument(u, passports))
+}
+
+func userDocument(u models.User, passports []models.Passport) search.Document {
+	seen := make(map[string]bool)
+	var authorities []string
+	for _, p := range passports {
+		if !seen[p.Authority] {
+			seen[p.Authority] = true
+			authorities = append(authorities, p.Authority)
+		}
+	}
+	sort.Strings(authorities)
+	return search.Document{
+		ID: strconv.Itoa(u.ID),
+		Fields: []search.Field{
+			{Name: "firstName", Text: u.FirstName, Boost: 2},
+			{Name: "lastName", Text: u.LastName, Boost: 2},
+			{Name: "locationOfBirth", Text: u.LocationOfBirth},
+			{Name: "passports.authority", Text: strings.Join(authorities, ", "), Boost: 0.5},
+		},
+	}
+}
+
+// indexedUserStore wraps a models.UserStorage and keeps the search index in
+// sync with every mutation.
+type indexedUserStore struct {
+	models.UserStorage
+	index *userIndex
+}
+
+func (s *indexedUserStore) AddUser(ctx context.Context, u models.User) (models.User, error) {
+	u, err := s.UserStorage.AddUser(ctx, u)
+	if err == nil {
+		s.index.reindex(ctx, u.ID)
+	}
+	return u, err
+}
+
+func (s *indexedUserStore) UpdateUser(ctx context.Context, u models.User) (models.User, error) {
+	u, err := s.UserStorage.UpdateUser(ctx, u)
+	if err == nil {
+		s.index.reindex(ctx, u.ID)
+	}
+	return u, err
+}
+
+func (s *indexedUserStore) DeleteUser(ctx context.Context, id int) error {
+	err := s.UserStorage.DeleteUser(ctx, id)
+	if err == nil {
+		s.index.reindex(ctx, id)
+	}
+	return err
+}
+
+// indexedPassportStore wraps a models.PassportStorage and re-indexes the
+// owning users whenever a passport changes.
+type indexedPassportStore struct {
+	models.PassportStorage
+	index *userIndex
+}
+
+func (s *indexedPassportStore) AddPassport(ctx context.Context, p models.Passport) (models.Passport, error) {
+	p, err := s.PassportStorage.AddPassport(ctx, p)
+	if err == nil {
+		s.index.reindex(ctx, p.UserID)
+	}
+	return p, err
+}
+
+func (s *indexedPassportStore) UpdatePassport(ctx context.Context, p models.Passport) (models.Passport, error) {
+	old, oldErr := s.PassportStorage.GetPassport(ctx, p.ID)
+	p, err := s.PassportStorage.UpdatePassport(ctx, p)
+	if err == nil {
+		s.index.reindex(ctx, p.UserID)
+		if oldErr == nil && old.UserID != p.UserID {
+			s.index.reindex(ctx, old.UserID)
+		}
+	}
+	return p, err
+}
+
+func (s *indexedPassportStore) DeletePassport(ctx context.Context, id string) error {
+	old, oldErr := s.PassportStorage.GetPassport(ctx, id)
+	err := s.PassportStorage.DeletePassport(ctx, id)
+	if err == nil && oldErr == nil {
+		s.index.reindex(ctx, old.UserID)
+	}
+	return err
+}
+
+// searchResult is a single user returned by GET /search. User is rendered
+// in the API version of the request.
+type searchResult struct {
+	User       any               `json:"user"`
+	Score      float64           `json:"score"`
+	Highlights map[string]string `json:"highlights"`
+}
+
+func (s *Server) handleSearch(w http.ResponseWriter, r *http.Request) {
+	q := strings.TrimSpace(r.URL.Query().Get("q"))
+	if q == "" {
+		respondError(w, status.CodeInvalidQuery, "q is required")
+		return
+	}
+	offset, limit := parsePagination(r)
+	res := s.search.index.Search(q, offset, limit)
+
+	v := versionOf(r)
+	results := make([]searchResult, 0, len(res.Hits))
+	for _, hit := range res.Hits {
+		uid, _ := strconv.Atoi(hit.ID)
+		u, err := s.userStore.GetUser(r.Context(), uid)
+		if err != nil {
+			s.logger.Error("search hit not found in store", "id", uid, "error", err)
+			continue
+		}
+		results = append(results, searchResult{
+			User:       v.user(u),
+			Score:      hit.Score,
+			Highlights: hit.Highlights,
+		})
+	}
+
+	respond(w, http.StatusOK, map[string]any{
+		"results": results,
+		"count":   len(results),
+		"total":   res.Total,
+		"offset":  offset,
+		"limit":   limit,
+	})
+}
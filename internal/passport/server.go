@@ -2,42 +2,64 @@ package passport
 
 import (
 	"context"
+	"errors"
 	"log/slog"
+	"net"
 	"net/http"
-	"os"
 	"os/signal"
 	"syscall"
 	"time"
 
+	"github.com/graphql-go/graphql"
 	"github.com/leeprovoost/go-rest-api-template/internal/passport/models"
+	"github.com/leeprovoost/go-rest-api-template/pkg/blob"
+	"google.golang.org/grpc"
 )
 
 // Server holds application dependencies and provides HTTP handlers.
 type Server struct {
 	userStore     models.UserStorage
 	passportStore models.PassportStorage
+	visaStore     models.VisaStorage
+	blobs         blob.Store
+	search        *userIndex
+	transactors   []models.Transactor // nil unless every store supports transactions
 	logger        *slog.Logger
 	version       string
 	env           string
 	port          string
+	grpcPort      string
 	corsOrigins   string
 	rateLimiter   *rateLimiter
+	idempotency   *idempotencyStore
+	operations    *operationStore
+	deprecations  *deprecationLog
+	verifications *verificationLimiter
+	graphql       graphql.Schema
+	handler       http.Handler // routes wrapped in middleware, built once
+
+	maxAttachmentBytes int64
 }
 
 // ServerOptions configures the server.
 type ServerOptions struct {
-	Version     string
-	Env         string
-	Port        string
-	CORSOrigins string
-	RateLimit   float64 // requests per second; 0 disables rate limiting
-	RateBurst   int     // burst size for rate limiter
+	Version        string
+	Env            string
+	Port           string
+	GRPCPort       string // port for the gRPC API; empty disables it
+	CORSOrigins    string
+	RateLimit      float64       // requests per second; 0 disables rate limiting
+	RateBurst      int           // burst size for rate limiter
+	IdempotencyTTL time.Duration // how long Idempotency-Key responses are replayed; 0 means 24h
+	Blobs          blob.Store    // where attachments are kept; nil keeps them in memory
+	MaxAttachment  int64         // largest attachment in bytes; 0 means 10 MiB
 }
 
 // NewServer creates a new Server with the given dependencies.
 func NewServer(
 	userStore models.UserStorage,
 	passportStore models.PassportStorage,
+	visaStore models.VisaStorage,
 	logger *slog.Logger,
 	opts ServerOptions,
 ) *Server {
@@ -45,16 +67,61 @@ func NewServer(
 	if opts.RateLimit > 0 {
 		rl = newRateLimiter(opts.RateLimit, opts.RateBurst)
 	}
-	return &Server{
-		userStore:     userStore,
-		passportStore: passportStore,
+
+	// Wrap the stores so every mutation keeps the search index up to date.
+	index := newUserIndex(userStore, passportStore, logger)
+	if err := index.rebuild(context.Background()); err != nil {
+		logger.Error("failed to build search index", "error", err)
+	}
+
+	// Atomic batches need a transaction on every store.
+	var transactors []models.Transactor
+	ut, uok := userStore.(models.Transactor)
+	pt, pok := passportStore.(models.Transactor)
+	vt, vok := visaStore.(models.Transactor)
+	if uok && pok && vok {
+		transactors = []models.Transactor{ut, pt, vt}
+	}
+
+	blobs := opts.Blobs
+	if blobs == nil {
+		blobs = blob.NewMemory()
+	}
+	maxAttachment := opts.MaxAttachment
+	if maxAttachment <= 0 {
+		maxAttachment = defaultMaxAttachmentBytes
+	}
+
+	s := &Server{
+		userStore:     &indexedUserStore{UserStorage: userStore, index: index},
+		passportStore: &indexedPassportStore{PassportStorage: passportStore, index: index},
+		visaStore:     visaStore,
+		blobs:         blobs,
+		search:        index,
+		transactors:   transactors,
 		logger:        logger,
 		version:       opts.Version,
 		env:           opts.Env,
 		port:          opts.Port,
+		grpcPort:      opts.GRPCPort,
 		corsOrigins:   opts.CORSOrigins,
 		rateLimiter:   rl,
+		idempotency:   newIdempotencyStore(opts.IdempotencyTTL),
+		operations:    newOperationStore(),
+		deprecations:  newDeprecationLog(),
+		verifications: newVerificationLimiter(),
+
+		maxAttachmentBytes: maxAttachment,
 	}
+
+	// The schema is static, so failing to build it is a programming error.
+	schema, err := s.newGraphQLSchema()
+	if err != nil {
+		panic("invalid GraphQL schema: " + err.Error())
+	}
+	s.graphql = schema
+	s.handler = s.middleware(s.routes())
+	return s
 }
 
 // NewTestServer creates a Server configured for testing.
@@ -62,6 +129,7 @@ func NewTestServer() *Server {
 	return NewServer(
 		NewUserService(CreateMockDataSet()),
 		NewPassportService(CreateMockPassportDataSet()),
+		NewVisaService(CreateMockVisaDataSet()),
 		slog.Default(),
 		ServerOptions{
 			Version: "0.0.0",
@@ -71,51 +139,84 @@ func NewTestServer() *Server {
 	)
 }
 
-// Run starts the HTTP server and blocks until shutdown.
+// Run starts the HTTP server, and the gRPC server if a gRPC port is
+// configured, and blocks until SIGINT or SIGTERM.
 func (s *Server) Run() error {
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	return s.serve(ctx)
+}
+
+// serve runs the servers until ctx is done or one of them fails, and then
+// shuts both down. Both ports are bound before either server starts, so a
+// port that is taken fails startup without leaving the other one open.
+func (s *Server) serve(ctx context.Context) error {
 	srv := &http.Server{
-		Addr:         s.addr(),
-		Handler:      s.middleware(s.routes()),
+		Addr:         s.addr(s.port),
+		Handler:      s.handler,
 		ReadTimeout:  10 * time.Second,
 		WriteTimeout: 10 * time.Second,
 		IdleTimeout:  120 * time.Second,
 	}
+	lis, err := net.Listen("tcp", srv.Addr)
+	if err != nil {
+		return err
+	}
+	var grpcLis net.Listener
+	if s.grpcPort != "" {
+		grpcLis, err = net.Listen("tcp", s.addr(s.grpcPort))
+		if err != nil {
+			lis.Close()
+			return err
+		}
+	}
 
-	errCh := make(chan error, 1)
+	errCh := make(chan error, 2)
 	go func() {
 		s.logger.Info("starting server",
 			"version", s.version,
 			"env", s.env,
-			"addr", srv.Addr,
+			"addr", lis.Addr().String(),
 		)
-		errCh <- srv.ListenAndServe()
+		errCh <- srv.Serve(lis)
 	}()
+	var grpcSrv *grpc.Server
+	if grpcLis != nil {
+		grpcSrv = s.newGRPCServer()
+		go func() {
+			s.logger.Info("starting gRPC server", "addr", grpcLis.Addr().String())
+			errCh <- grpcSrv.Serve(grpcLis)
+		}()
+	}
 
-	quit := make(chan os.Signal, 1)
-	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
-
+	var serveErr error
 	select {
-	case err := <-errCh:
-		return err
-	case sig := <-quit:
-		s.logger.Info("shutting down server", "signal", sig)
+	case serveErr = <-errCh:
+		s.logger.Error("server failed, shutting down", "error", serveErr)
+	case <-ctx.Done():
+		s.logger.Info("shutting down server", "cause", context.Cause(ctx))
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
-	return srv.Shutdown(ctx)
+	if grpcSrv != nil {
+		stopGRPC(shutdownCtx, grpcSrv)
+	}
+	s.operations.shutdown(shutdownCtx)
+	return errors.Join(serveErr, srv.Shutdown(shutdownCtx))
 }
 
-func (s *Server) addr() string {
+func (s *Server) addr(port string) string {
 	if s.env == "LOCAL" {
-		return "localhost:" + s.port
+		return "localhost:" + port
 	}
-	return ":" + s.port
+	return ":" + port
 }
 
 // middleware chains all middleware in order of execution.
 func (s *Server) middleware(next http.Handler) http.Handler {
 	h := next
+	h = headMethod(h)
 	h = clacksOverhead(h)
 	h = securityHeaders(h)
 	if s.corsOrigins != "" {
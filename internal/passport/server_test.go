@@ -1,36 +1,42 @@
 package passport
 
 import (
+	"context"
 	"log/slog"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestAddrLocal(t *testing.T) {
 	srv := NewTestServer()
-	assert.Equal(t, "localhost:3001", srv.addr())
+	assert.Equal(t, "localhost:3001", srv.addr(srv.port))
 }
 
 func TestAddrNonLocal(t *testing.T) {
 	srv := NewServer(
 		NewUserService(CreateMockDataSet()),
 		NewPassportService(CreateMockPassportDataSet()),
+		NewVisaService(CreateMockVisaDataSet()),
 		slog.Default(),
 		ServerOptions{
 			Env:  "PRD",
 			Port: "8080",
 		},
 	)
-	assert.Equal(t, ":8080", srv.addr())
+	assert.Equal(t, ":8080", srv.addr(srv.port))
 }
 
 func TestNewServerWithRateLimiter(t *testing.T) {
 	srv := NewServer(
 		NewUserService(CreateMockDataSet()),
 		NewPassportService(CreateMockPassportDataSet()),
+		NewVisaService(CreateMockVisaDataSet()),
 		slog.Default(),
 		ServerOptions{
 			Env:       "LOCAL",
@@ -51,6 +57,7 @@ func TestMiddlewareWithCORSAndRateLimiter(t *testing.T) {
 	srv := NewServer(
 		NewUserService(CreateMockDataSet()),
 		NewPassportService(CreateMockPassportDataSet()),
+		NewVisaService(CreateMockVisaDataSet()),
 		slog.Default(),
 		ServerOptions{
 			Env:         "LOCAL",
@@ -72,3 +79,54 @@ func TestMiddlewareWithCORSAndRateLimiter(t *testing.T) {
 	assert.NotEmpty(t, w.Header().Get("X-Request-ID"))
 	assert.Equal(t, "nosniff", w.Header().Get("X-Content-Type-Options"))
 }
+
+// freePort returns a port on localhost that nothing is listening on.
+func freePort(t *testing.T) string {
+	t.Helper()
+	lis, err := net.Listen("tcp", "localhost:0")
+	require.NoError(t, err)
+	defer lis.Close()
+	_, port, err := net.SplitHostPort(lis.Addr().String())
+	require.NoError(t, err)
+	return port
+}
+
+func TestServeStopsBothServers(t *testing.T) {
+	srv := NewTestServer()
+	srv.port, srv.grpcPort = freePort(t), freePort(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- srv.serve(ctx) }()
+
+	require.Eventually(t, func() bool {
+		resp, err := http.Get("http://" + srv.addr(srv.port) + "/healthcheck")
+		if err != nil {
+			return false
+		}
+		resp.Body.Close()
+		return true
+	}, 5*time.Second, 10*time.Millisecond)
+	cancel()
+	require.NoError(t, <-done)
+
+	for _, port := range []string{srv.port, srv.grpcPort} {
+		lis, err := net.Listen("tcp", srv.addr(port))
+		require.NoError(t, err, "port %s is still in use", port)
+		lis.Close()
+	}
+}
+
+func TestServeReleasesHTTPPortWhenGRPCPortIsTaken(t *testing.T) {
+	taken, err := net.Listen("tcp", "localhost:0")
+	require.NoError(t, err)
+	defer taken.Close()
+	_, grpcPort, _ := net.SplitHostPort(taken.Addr().String())
+
+	srv := NewTestServer()
+	srv.port, srv.grpcPort = freePort(t), grpcPort
+	assert.Error(t, srv.serve(context.Background()))
+
+	lis, err := net.Listen("tcp", srv.addr(srv.port))
+	require.NoError(t, err, "HTTP port is still in use")
+	lis.Close()
+}
@@ -0,0 +1,90 @@
+package passport
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/leeprovoost/go-rest-api-template/internal/passport/models"
+	"github.com/leeprovoost/go-rest-api-template/pkg/status"
+)
+
+// statsMonths is the number of months, starting with the current one, that
+// GET /stats reports passport expiries for unless the months query parameter
+// asks for up to maxStatsMonths.
+const (
+	statsMonths    = 24
+	maxStatsMonths = 120
+)
+
+// bandCount is the number of users in an age band.
+type bandCount struct {
+	Band  string `json:"band"`
+	Count int    `json:"count"`
+}
+
+// monthCount is the number of passports expiring in a month, written as
+// YYYY-MM.
+type monthCount struct {
+	Month string `json:"month"`
+	Count int    `json:"count"`
+}
+
+// statsResponse is the body of GET /stats.
+type statsResponse struct {
+	Users struct {
+		Total           int         `json:"total"`
+		AgeDistribution []bandCount `json:"ageDistribution"`
+	} `json:"users"`
+	Passports struct {
+		Total           int            `json:"total"`
+		ByAuthority     map[string]int `json:"byAuthority"`
+		ExpiriesByMonth []monthCount   `json:"expiriesByMonth"`
+	} `json:"passports"`
+	GeneratedAt time.Time `json:"generatedAt"`
+}
+
+// handleStats reports aggregate figures about users and passports. The
+// counting is left to the stores, so that a database can do it without
+// sending every record.
+func (s *Server) handleStats(w http.ResponseWriter, r *http.Request) {
+	months := statsMonths
+	if v := r.URL.Query().Get("months"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxStatsMonths {
+			respondError(w, status.CodeInvalidQuery, fmt.Sprintf("months must be a number from 1 to %d", maxStatsMonths))
+			return
+		}
+		months = n
+	}
+	now := time.Now().UTC()
+	users, err := s.userStore.UserStats(r.Context(), now)
+	if err != nil {
+		s.logger.Error("failed to count users", "error", err)
+		respondError(w, status.CodeInternal, "failed to compute statistics")
+		return
+	}
+	passports, err := s.passportStore.PassportStats(r.Context(), now, months)
+	if err != nil {
+		s.logger.Error("failed to count passports", "error", err)
+		respondError(w, status.CodeInternal, "failed to compute statistics")
+		return
+	}
+
+	var resp statsResponse
+	resp.GeneratedAt = now
+	resp.Users.Total = users.Total
+	resp.Users.AgeDistribution = make([]bandCount, len(users.ByAge))
+	for i, n := range users.ByAge {
+		resp.Users.AgeDistribution[i] = bandCount{Band: models.AgeBandLabel(i), Count: n}
+	}
+	resp.Passports.Total = passports.Total
+	resp.Passports.ByAuthority = passports.ByAuthority
+	resp.Passports.ExpiriesByMonth = make([]monthCount, len(passports.ExpiriesByMonth))
+	first := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	for i, n := range passports.ExpiriesByMonth {
+		resp.Passports.ExpiriesByMonth[i] = monthCount{Month: first.AddDate(0, i, 0).Format("2006-01"), Count: n}
+	}
+	respond(w, http.StatusOK, resp)
+}
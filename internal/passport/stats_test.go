@@ -0,0 +1,91 @@
+package passport
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/leeprovoost/go-rest-api-template/pkg/civil"
+	"github.com/leeprovoost/go-rest-api-template/pkg/status"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStatsHandler(t *testing.T) {
+	handler := newTestHandler()
+
+	_, body := getJSON(t, handler, "/v2/stats")
+	users := body["users"].(map[string]any)
+	assert.EqualValues(t, 2, users["total"])
+	ages := users["ageDistribution"].([]any)
+	require.Len(t, ages, 7)
+	assert.Equal(t, map[string]any{"band": "0-17", "count": float64(0)}, ages[0])
+	assert.Equal(t, "65+", ages[6].(map[string]any)["band"])
+
+	passports := body["passports"].(map[string]any)
+	assert.EqualValues(t, 2, passports["total"])
+	assert.Equal(t, map[string]any{"HMPO": float64(2)}, passports["byAuthority"])
+	months := passports["expiriesByMonth"].([]any)
+	require.Len(t, months, 24)
+	assert.Equal(t, time.Now().UTC().Format("2006-01"), months[0].(map[string]any)["month"])
+}
+
+func TestStatsHandlerCounts(t *testing.T) {
+	handler := newTestHandler()
+	today := civil.Today()
+	thisMonth := time.Date(today.Year, today.Month, 1, 0, 0, 0, 0, time.UTC)
+	// expiring returns a passport of authority expiring mid-month, months
+	// from now, and issued five years before.
+	expiring := func(id, authority string, months int) string {
+		expiry := civil.DateOf(thisMonth.AddDate(0, months, 14))
+		return fmt.Sprintf(`{"id":%q,"dateOfIssue":%q,"dateOfExpiry":%q,"authority":%q}`, id, expiry.AddDate(-5, 0, 0), expiry, authority)
+	}
+
+	// A child with a passport expiring next month, and a pensioner with one
+	// expiring in five months and a lost one expiring next month.
+	for _, req := range []struct{ path, body string }{
+		{"/users", fmt.Sprintf(`{"firstName":"Ann","lastName":"Kid","dateOfBirth":%q,"locationOfBirth":"Leeds"}`, today.AddDate(-10, 0, 0))},
+		{"/users", fmt.Sprintf(`{"firstName":"Bob","lastName":"Old","dateOfBirth":%q,"locationOfBirth":"Cork"}`, today.AddDate(-70, 0, 0))},
+		{"/users/2/passports", expiring("222222222", "HMPO", 1)},
+		{"/users/3/passports", expiring("PA2222222", "DFA", 1)},
+		{"/users/3/passports", expiring("333333333", "HMPO", 5)},
+		{"/passports/PA2222222/transitions", `{"status":"lost","reason":"left on a train","actor":"jdoe"}`},
+	} {
+		w := postWithKey(handler, req.path, "", req.body)
+		require.Equal(t, http.StatusCreated, w.Code, "%s: %s", req.path, w.Body.String())
+	}
+
+	var stats statsResponse
+	w, _ := getJSON(t, handler, "/v2/stats")
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &stats))
+	assert.Equal(t, 4, stats.Users.Total)
+	assert.Equal(t, bandCount{Band: "0-17", Count: 1}, stats.Users.AgeDistribution[0])
+	assert.Equal(t, bandCount{Band: "65+", Count: 1}, stats.Users.AgeDistribution[6])
+	assert.Equal(t, 5, stats.Passports.Total)
+	assert.Equal(t, map[string]int{"HMPO": 4, "DFA": 1}, stats.Passports.ByAuthority)
+	require.Len(t, stats.Passports.ExpiriesByMonth, 24)
+	// The lost passport isn't counted.
+	assert.Equal(t, monthCount{Month: thisMonth.AddDate(0, 1, 0).Format("2006-01"), Count: 1}, stats.Passports.ExpiriesByMonth[1])
+	assert.Equal(t, monthCount{Month: thisMonth.AddDate(0, 5, 0).Format("2006-01"), Count: 1}, stats.Passports.ExpiriesByMonth[5])
+
+	// A shorter window leaves out the later expiry.
+	w, _ = getJSON(t, handler, "/v2/stats?months=3")
+	stats = statsResponse{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &stats))
+	require.Len(t, stats.Passports.ExpiriesByMonth, 3)
+	assert.Equal(t, []monthCount{
+		{Month: thisMonth.Format("2006-01"), Count: 0},
+		{Month: thisMonth.AddDate(0, 1, 0).Format("2006-01"), Count: 1},
+		{Month: thisMonth.AddDate(0, 2, 0).Format("2006-01"), Count: 0},
+	}, stats.Passports.ExpiriesByMonth)
+
+	for _, months := range []string{"0", "121", "soon"} {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v2/stats?months="+months, nil))
+		assert.Equal(t, http.StatusBadRequest, w.Code, months)
+		assert.Contains(t, w.Body.String(), string(status.CodeInvalidQuery), months)
+	}
+}
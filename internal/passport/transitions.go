@@ -0,0 +1,64 @@
+package passport
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/leeprovoost/go-rest-api-template/internal/passport/models"
+	"github.com/leeprovoost/go-rest-api-template/pkg/status"
+	"github.com/leeprovoost/go-rest-api-template/pkg/validate"
+)
+
+// transitionRequest is the body of POST /passports/{id}/transitions. There
+// is no authentication yet, so the client names the actor. A passport only
+// becomes replaced when it is renewed, which links it to its successor.
+type transitionRequest struct {
+	Status models.PassportStatus `json:"status" validate:"required,oneof=lost stolen revoked expired"`
+	Reason string                `json:"reason" validate:"required,max=500"`
+	Actor  string                `json:"actor" validate:"required,max=100"`
+}
+
+// handleTransitionPassport changes the status of a passport, if its current
+// status allows it, and records who did it and why.
+func (s *Server) handleTransitionPassport(w http.ResponseWriter, r *http.Request) {
+	var req transitionRequest
+	if !s.decode(w, r, &req, status.CodeMalformedRequest) {
+		return
+	}
+	if errs := validate.Struct(req); len(errs) > 0 {
+		respondValidationErrors(w, errs)
+		return
+	}
+	id := r.PathValue("id")
+	t, err := s.passportStore.TransitionPassport(r.Context(), id, models.Transition{
+		To:     req.Status,
+		Reason: req.Reason,
+		Actor:  req.Actor,
+		At:     time.Now().UTC(),
+	})
+	if errors.Is(err, models.ErrInvalidTransition) {
+		respondError(w, status.CodeInvalidTransition, err.Error())
+		return
+	}
+	if err != nil {
+		respondError(w, status.CodePassportNotFound, "can't find passport")
+		return
+	}
+	s.logger.Info("passport status changed", "id", id, "from", t.From, "to", t.To, "actor", t.Actor)
+	respond(w, http.StatusCreated, t)
+}
+
+// handleListTransitions lists the status changes of a passport, oldest
+// first.
+func (s *Server) handleListTransitions(w http.ResponseWriter, r *http.Request) {
+	transitions, err := s.passportStore.ListTransitions(r.Context(), r.PathValue("id"))
+	if err != nil {
+		respondError(w, status.CodePassportNotFound, "can't find passport")
+		return
+	}
+	respond(w, http.StatusOK, map[string]any{
+		"transitions": transitions,
+		"count":       len(transitions),
+	})
+}
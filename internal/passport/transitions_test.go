@@ -0,0 +1,70 @@
+package passport
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/leeprovoost/go-rest-api-template/internal/passport/models"
+	"github.com/leeprovoost/go-rest-api-template/pkg/status"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTransitionPassportHandler(t *testing.T) {
+	handler := newTestHandler()
+
+	w := postWithKey(handler, "/passports/012345678/transitions", "", `{"status":"lost","reason":"left on a train","actor":"jdoe"}`)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	var lost models.Transition
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &lost))
+	assert.Equal(t, models.StatusIssued, lost.From)
+	assert.Equal(t, models.StatusLost, lost.To)
+	assert.Equal(t, "jdoe", lost.Actor)
+	assert.False(t, lost.At.IsZero())
+
+	w = postWithKey(handler, "/passports/012345678/transitions", "", `{"status":"revoked","reason":"fraud","actor":"jdoe"}`)
+	assert.Equal(t, http.StatusConflict, w.Code)
+	var resp status.Response
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, status.CodeInvalidTransition, resp.Code)
+	assert.Contains(t, resp.Message, "is lost and can't become revoked")
+
+	// Only a renewal can replace a passport, so that it has a successor.
+	w = postWithKey(handler, "/passports/012345678/transitions", "", `{"status":"replaced","reason":"new passport issued","actor":"hmpo"}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	w = postWithKey(handler, "/passports/012345678/renew", "", renewal)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+
+	_, body := getJSON(t, handler, "/passports/012345678/transitions")
+	assert.EqualValues(t, 2, body["count"])
+	_, body = getJSON(t, handler, "/v2/passports/012345678")
+	assert.Equal(t, "replaced", body["status"])
+}
+
+func TestTransitionPassportHandlerErrors(t *testing.T) {
+	handler := newTestHandler()
+
+	w := postWithKey(handler, "/passports/012345678/transitions", "", `{"status":"found","actor":"jdoe"}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	assert.Contains(t, w.Body.String(), "status must be one of")
+	assert.Contains(t, w.Body.String(), "reason is required")
+
+	w = postWithKey(handler, "/passports/nope/transitions", "", `{"status":"lost","reason":"gone","actor":"jdoe"}`)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestListPassportsByStatus(t *testing.T) {
+	handler := newTestHandler()
+	w := postWithKey(handler, "/passports/012345678/transitions", "", `{"status":"stolen","reason":"burglary","actor":"jdoe"}`)
+	require.Equal(t, http.StatusCreated, w.Code)
+
+	_, body := getJSON(t, handler, "/users/0/passports?status=stolen")
+	assert.EqualValues(t, 1, body["count"])
+	_, body = getJSON(t, handler, "/users/0/passports?status=issued")
+	assert.EqualValues(t, 0, body["count"])
+
+	// A stolen passport is no longer active, so it doesn't block a new one.
+	w = postWithKey(handler, "/users/0/passports", "", `{"id":"111111111","dateOfIssue":"2024-03-01T00:00:00Z","dateOfExpiry":"2034-03-01T00:00:00Z","authority":"HMPO"}`)
+	assert.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+}
@@ -0,0 +1,120 @@
+package passport
+
+import (
+	"context"
+	"errors"
+
+	"github.com/leeprovoost/go-rest-api-template/internal/passport/models"
+)
+
+// errTxUnsupported is returned by beginTx when a store does not implement
+// models.Transactor.
+var errTxUnsupported = errors.New("storage does not support transactions")
+
+// storeTx is a transaction spanning the user and passport stores. It also
+// remembers which users were re-indexed, so the search index can be brought
+// back in line with the stores after a rollback.
+type storeTx struct {
+	txs     []models.Tx
+	index   *userIndex
+	touched map[int]bool
+	joined  bool // part of a transaction begun by a caller
+	onEnd   []func(committed bool)
+}
+
+type storeTxKey struct{}
+
+// beginTx starts a transaction on every store. Store calls made with the
+// returned context take part in it. If ctx already carries a transaction,
+// such as that of an atomic batch, the returned one joins it: its Commit and
+// Rollback do nothing, and the caller's transaction decides the outcome.
+func (s *Server) beginTx(ctx context.Context) (context.Context, *storeTx, error) {
+	if _, ok := ctx.Value(storeTxKey{}).(*storeTx); ok {
+		return ctx, &storeTx{joined: true}, nil
+	}
+	if s.transactors == nil {
+		return ctx, nil, errTxUnsupported
+	}
+	tx := &storeTx{index: s.search, touched: make(map[int]bool)}
+	txCtx := ctx
+	for _, t := range s.transactors {
+		var (
+			stx models.Tx
+			err error
+		)
+		txCtx, stx, err = t.Begin(txCtx)
+		if err != nil {
+			tx.Rollback()
+			return ctx, nil, err
+		}
+		tx.txs = append(tx.txs, stx)
+	}
+	return context.WithValue(txCtx, storeTxKey{}, tx), tx, nil
+}
+
+// txFrom returns the transaction carried by ctx, or nil.
+func txFrom(ctx context.Context) *storeTx {
+	tx, _ := ctx.Value(storeTxKey{}).(*storeTx)
+	return tx
+}
+
+// afterEnd registers f to be called once the transaction carried by ctx
+// commits or rolls back, with whether it committed. Without a transaction,
+// f is called right away.
+func afterEnd(ctx context.Context, f func(committed bool)) {
+	tx := txFrom(ctx)
+	if tx == nil {
+		f(true)
+		return
+	}
+	tx.onEnd = append(tx.onEnd, f)
+}
+
+// end calls the functions registered with afterEnd.
+func (tx *storeTx) end(committed bool) {
+	for _, f := range tx.onEnd {
+		f(committed)
+	}
+	tx.onEnd = nil
+}
+
+// touch records that the search document for uid changed inside the
+// transaction carried by ctx, if any.
+func touch(ctx context.Context, uid int) {
+	if tx, ok := ctx.Value(storeTxKey{}).(*storeTx); ok {
+		tx.touched[uid] = true
+	}
+}
+
+// Commit commits the store transactions in reverse order of Begin.
+func (tx *storeTx) Commit() error {
+	if tx.joined {
+		return nil
+	}
+	var errs []error
+	for i := len(tx.txs) - 1; i >= 0; i-- {
+		errs = append(errs, tx.txs[i].Commit())
+	}
+	err := errors.Join(errs...)
+	if err == nil {
+		tx.end(true)
+	}
+	return err
+}
+
+// Rollback rolls back the store transactions and re-indexes the users whose
+// documents changed inside the transaction.
+func (tx *storeTx) Rollback() error {
+	if tx.joined {
+		return nil
+	}
+	var errs []error
+	for i := len(tx.txs) - 1; i >= 0; i-- {
+		errs = append(errs, tx.txs[i].Rollback())
+	}
+	for uid := range tx.touched {
+		tx.index.reindex(context.Background(), uid)
+	}
+	tx.end(false)
+	return errors.Join(errs...)
+}
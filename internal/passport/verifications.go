@@ -0,0 +1,214 @@
+package passport
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/leeprovoost/go-rest-api-template/internal/passport/models"
+	"github.com/leeprovoost/go-rest-api-template/pkg/civil"
+	"github.com/leeprovoost/go-rest-api-template/pkg/mrz"
+	"github.com/leeprovoost/go-rest-api-template/pkg/status"
+	"github.com/leeprovoost/go-rest-api-template/pkg/validate"
+)
+
+// verificationRequest is the body of POST /verifications: what a partner
+// was told about a person and their passport.
+type verificationRequest struct {
+	PassportNumber string     `json:"passportNumber" validate:"required,max=20"`
+	FirstName      string     `json:"firstName" validate:"required,max=100"`
+	LastName       string     `json:"lastName" validate:"required,max=100"`
+	DateOfBirth    civil.Date `json:"dateOfBirth" validate:"required"`
+}
+
+// Outcomes of the checks of a verification. Fields the partner supplied
+// match or don't; the passport itself is valid or not. Nothing is checked
+// against a passport number we don't know.
+const (
+	outcomeMatch      = "match"
+	outcomeNoMatch    = "no_match"
+	outcomeValid      = "valid"
+	outcomeInvalid    = "invalid"
+	outcomeNotChecked = "not_checked"
+)
+
+// verificationChecks are the outcomes of each check. They say whether the
+// supplied data matches, never what we hold instead: a lost passport is
+// just invalid, and a name that doesn't match isn't corrected.
+type verificationChecks struct {
+	PassportNumber string `json:"passportNumber"`
+	FirstName      string `json:"firstName"`
+	LastName       string `json:"lastName"`
+	DateOfBirth    string `json:"dateOfBirth"`
+	Expiry         string `json:"expiry"`
+	Status         string `json:"status"`
+}
+
+// verificationResponse is the result of a verification. Verified is true
+// only if every field matched and the passport is valid.
+type verificationResponse struct {
+	Verified  bool               `json:"verified"`
+	Checks    verificationChecks `json:"checks"`
+	CheckedAt time.Time          `json:"checkedAt"`
+}
+
+// handleVerify answers "does this person hold this passport?" for a
+// partner. It doesn't store anything, and it doesn't log the personal data
+// it was sent. Failed verifications are limited per client and passport (see
+// verificationLimiter).
+func (s *Server) handleVerify(w http.ResponseWriter, r *http.Request) {
+	var req verificationRequest
+	if !s.decode(w, r, &req, status.CodeMalformedRequest) {
+		return
+	}
+	if errs := validate.Struct(req); len(errs) > 0 {
+		respondValidationErrors(w, errs)
+		return
+	}
+	number := strings.ToUpper(strings.TrimSpace(req.PassportNumber))
+	key := verificationKey(r, number)
+	if ok, retry := s.verifications.begin(key); !ok {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retry.Seconds()))))
+		respondError(w, status.CodeVerificationLimited, "too many failed verifications of this passport; try again later")
+		return
+	}
+	now := time.Now().UTC()
+	resp := verificationResponse{
+		Checks: verificationChecks{
+			PassportNumber: outcomeNoMatch,
+			FirstName:      outcomeNotChecked,
+			LastName:       outcomeNotChecked,
+			DateOfBirth:    outcomeNotChecked,
+			Expiry:         outcomeNotChecked,
+			Status:         outcomeNotChecked,
+		},
+		CheckedAt: now,
+	}
+
+	p, err := s.passportStore.GetPassport(r.Context(), number)
+	if err == nil {
+		c := &resp.Checks
+		c.PassportNumber = outcomeMatch
+		c.Expiry = outcome(p.DateOfExpiry.After(civil.DateOf(now)), outcomeValid, outcomeInvalid)
+		c.Status = outcome(p.Status == models.StatusIssued || p.Status == "", outcomeValid, outcomeInvalid)
+		// A passport whose owner is gone can't match anyone.
+		u, err := s.userStore.GetUser(r.Context(), p.UserID)
+		c.FirstName = outcome(err == nil && sameName(u.FirstName, req.FirstName), outcomeMatch, outcomeNoMatch)
+		c.LastName = outcome(err == nil && sameName(u.LastName, req.LastName), outcomeMatch, outcomeNoMatch)
+		c.DateOfBirth = outcome(err == nil && u.DateOfBirth == req.DateOfBirth, outcomeMatch, outcomeNoMatch)
+		resp.Verified = *c == verificationChecks{
+			PassportNumber: outcomeMatch,
+			FirstName:      outcomeMatch,
+			LastName:       outcomeMatch,
+			DateOfBirth:    outcomeMatch,
+			Expiry:         outcomeValid,
+			Status:         outcomeValid,
+		}
+	}
+	if resp.Verified {
+		s.verifications.succeeded(key)
+	}
+	s.logger.Info("verification", "verified", resp.Verified, "request_id", w.Header().Get("X-Request-ID"))
+	respond(w, http.StatusOK, resp)
+}
+
+// Limits on failed verifications. A client that knows a passport number could
+// otherwise try dates of birth until the per-field outcomes show a match.
+const (
+	maxVerificationFailures = 5
+	verificationWindow      = time.Hour
+)
+
+// verificationLimiter counts the failed verifications of each passport by
+// each client, in windows of verificationWindow. Like the rate limiter, it is
+// per-instance.
+type verificationLimiter struct {
+	mu        sync.Mutex
+	now       func() time.Time
+	attempts  map[string]*verificationAttempts
+	lastSweep time.Time
+}
+
+// verificationAttempts are the failures counted against a key in the window
+// that ends at reset.
+type verificationAttempts struct {
+	failures int
+	reset    time.Time
+}
+
+func newVerificationLimiter() *verificationLimiter {
+	return &verificationLimiter{
+		now:      time.Now,
+		attempts: make(map[string]*verificationAttempts),
+	}
+}
+
+// verificationKey identifies the client of r and the passport it verifies,
+// hashed so that neither is kept in memory.
+func verificationKey(r *http.Request, number string) string {
+	sum := sha256.Sum256([]byte(clientIdentity(r) + "\n" + number))
+	return hex.EncodeToString(sum[:])
+}
+
+// begin counts an attempt against key as failed until succeeded says
+// otherwise, so that concurrent attempts can't overrun the limit. Once key
+// has used up its attempts, begin returns false with the time until the
+// window ends.
+func (l *verificationLimiter) begin(key string) (ok bool, retry time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+	if now.Sub(l.lastSweep) >= verificationWindow {
+		for k, a := range l.attempts {
+			if !now.Before(a.reset) {
+				delete(l.attempts, k)
+			}
+		}
+		l.lastSweep = now
+	}
+	a := l.attempts[key]
+	if a == nil || !now.Before(a.reset) {
+		a = &verificationAttempts{reset: now.Add(verificationWindow)}
+		l.attempts[key] = a
+	}
+	if a.failures >= maxVerificationFailures {
+		return false, a.reset.Sub(now)
+	}
+	a.failures++
+	return true, 0
+}
+
+// succeeded takes back the failure begin counted for a verification that
+// succeeded, so that partners can verify the same passport repeatedly.
+func (l *verificationLimiter) succeeded(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if a := l.attempts[key]; a != nil && a.failures > 0 {
+		a.failures--
+	}
+}
+
+// outcome returns yes if ok is true and no otherwise.
+func outcome(ok bool, yes, no string) string {
+	if ok {
+		return yes
+	}
+	return no
+}
+
+// normalizeName reduces a name to the form it takes in the machine readable
+// zone, with words separated by single spaces, so that "Jean-Luc Müller"
+// and "JEAN LUC MULLER" compare equal.
+func normalizeName(name string) string {
+	return strings.Join(strings.FieldsFunc(mrz.Transliterate(name), func(r rune) bool { return r == '<' }), " ")
+}
+
+// sameName reports whether two names are the same after normalizeName.
+func sameName(a, b string) bool {
+	return normalizeName(a) == normalizeName(b)
+}
@@ -0,0 +1,125 @@
+package passport
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/leeprovoost/go-rest-api-template/pkg/status"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// verify posts body to /verifications and returns the result.
+func verify(t *testing.T, handler http.Handler, body string) verificationResponse {
+	t.Helper()
+	w := sendJSON(handler, http.MethodPost, "/verifications", body)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var resp verificationResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	return resp
+}
+
+func TestVerify(t *testing.T) {
+	handler := newTestHandler()
+
+	resp := verify(t, handler, `{"passportNumber":"012345678","firstName":"john","lastName":"DOE","dateOfBirth":"1985-12-31T00:00:00Z"}`)
+	assert.True(t, resp.Verified)
+	assert.Equal(t, verificationChecks{
+		PassportNumber: "match",
+		FirstName:      "match",
+		LastName:       "match",
+		DateOfBirth:    "match",
+		Expiry:         "valid",
+		Status:         "valid",
+	}, resp.Checks)
+
+	resp = verify(t, handler, `{"passportNumber":"012345678","firstName":"Jane","lastName":"Doe","dateOfBirth":"1985-12-30T00:00:00Z"}`)
+	assert.False(t, resp.Verified)
+	assert.Equal(t, "no_match", resp.Checks.FirstName)
+	assert.Equal(t, "match", resp.Checks.LastName)
+	assert.Equal(t, "no_match", resp.Checks.DateOfBirth)
+}
+
+func TestVerifyUnknownPassport(t *testing.T) {
+	w := sendJSON(newTestHandler(), http.MethodPost, "/verifications", `{"passportNumber":"999999999","firstName":"John","lastName":"Doe","dateOfBirth":"1985-12-31T00:00:00Z"}`)
+	require.Equal(t, http.StatusOK, w.Code)
+	var resp verificationResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.False(t, resp.Verified)
+	assert.Equal(t, "no_match", resp.Checks.PassportNumber)
+	assert.Equal(t, "not_checked", resp.Checks.FirstName)
+	assert.Equal(t, "not_checked", resp.Checks.Status)
+}
+
+func TestVerifyLostPassport(t *testing.T) {
+	handler := newTestHandler()
+	w := postWithKey(handler, "/passports/012345678/transitions", "", `{"status":"lost","reason":"left on a train","actor":"jdoe"}`)
+	require.Equal(t, http.StatusCreated, w.Code)
+
+	w = sendJSON(handler, http.MethodPost, "/verifications", `{"passportNumber":"012345678","firstName":"John","lastName":"Doe","dateOfBirth":"1985-12-31T00:00:00Z"}`)
+	require.Equal(t, http.StatusOK, w.Code)
+	// The result says the passport is invalid, not that it was lost.
+	assert.NotContains(t, w.Body.String(), "lost")
+	var resp verificationResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.False(t, resp.Verified)
+	assert.Equal(t, "invalid", resp.Checks.Status)
+	assert.Equal(t, "valid", resp.Checks.Expiry)
+	assert.Equal(t, "match", resp.Checks.FirstName)
+}
+
+func TestVerifyLimitsFailedAttempts(t *testing.T) {
+	srv := NewTestServer()
+	handler := srv.middleware(srv.routes())
+	now := time.Date(2026, time.October, 19, 12, 0, 0, 0, time.UTC)
+	srv.verifications.now = func() time.Time { return now }
+	const wrong = `{"passportNumber":"012345678","firstName":"John","lastName":"Doe","dateOfBirth":"1985-12-30"}`
+	const right = `{"passportNumber":"012345678","firstName":"John","lastName":"Doe","dateOfBirth":"1985-12-31"}`
+
+	// Successful verifications don't count against the limit.
+	for range maxVerificationFailures + 1 {
+		assert.True(t, verify(t, handler, right).Verified)
+	}
+	for range maxVerificationFailures {
+		assert.False(t, verify(t, handler, wrong).Verified)
+	}
+
+	// Once the failures are used up, not even the right date gets an answer.
+	for _, body := range []string{wrong, right} {
+		w := sendJSON(handler, http.MethodPost, "/verifications", body)
+		assert.Equal(t, http.StatusTooManyRequests, w.Code)
+		assert.Contains(t, w.Body.String(), string(status.CodeVerificationLimited))
+		assert.Equal(t, "3600", w.Header().Get("Retry-After"))
+		assert.NotContains(t, w.Body.String(), "match")
+	}
+
+	// The limit is per client and passport.
+	r := httptest.NewRequest(http.MethodPost, "/verifications", strings.NewReader(right))
+	r.Header.Set("Content-Type", "application/json")
+	r.Header.Set("Authorization", "Bearer partner-2")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "match", verify(t, handler, `{"passportNumber":"987654321","firstName":"Jane","lastName":"Doe","dateOfBirth":"1992-01-01"}`).Checks.PassportNumber)
+
+	// It is lifted when the window ends.
+	now = now.Add(verificationWindow)
+	assert.True(t, verify(t, handler, right).Verified)
+}
+
+func TestVerifyValidation(t *testing.T) {
+	w := sendJSON(newTestHandler(), http.MethodPost, "/verifications", `{"passportNumber":"012345678"}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	assert.Contains(t, w.Body.String(), "firstName is required")
+	assert.Contains(t, w.Body.String(), "dateOfBirth is required")
+}
+
+func TestNormalizeName(t *testing.T) {
+	assert.Equal(t, "JEAN LUC MULLER", normalizeName(" Jean-Luc  Müller "))
+	assert.True(t, sameName("O'Brien", "OBRIEN"))
+	assert.False(t, sameName("Ann", "Anne"))
+}
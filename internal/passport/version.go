@@ -0,0 +1,209 @@
+package passport
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/leeprovoost/go-rest-api-template/internal/passport/models"
+)
+
+// apiVersion is a version of the REST API. All versions share the same
+// handlers; they differ in how models are rendered in responses.
+type apiVersion struct {
+	name        string
+	prefix      string    // path prefix the routes are mounted under
+	deprecation time.Time // zero unless the version is (to be) deprecated
+	sunset      time.Time // when a deprecated version will be removed
+	user        func(models.User) any
+	passport    func(models.Passport) any
+	visa        func(models.Visa) any
+	date        func(time.Time) any // renders dates outside of models
+}
+
+var (
+	apiV1 = &apiVersion{
+		name:        "v1",
+		prefix:      "/v1",
+		deprecation: time.Date(2027, time.January, 1, 0, 0, 0, 0, time.UTC),
+		sunset:      time.Date(2027, time.July, 1, 0, 0, 0, 0, time.UTC),
+		user:        userV1,
+		passport:    passportV1,
+		visa:        visaV1,
+		date:        func(t time.Time) any { return t },
+	}
+	apiV2 = &apiVersion{
+		name:     "v2",
+		prefix:   "/v2",
+		user:     func(u models.User) any { return u },
+		passport: func(p models.Passport) any { return p },
+		visa:     func(v models.Visa) any { return v },
+		date:     func(t time.Time) any { return t.Format(time.DateOnly) },
+	}
+	// apiUnversioned serves the original unversioned paths. They predate
+	// /v1 and behave exactly like it, including its deprecation.
+	apiUnversioned = func() *apiVersion {
+		v := *apiV1
+		v.prefix = ""
+		return &v
+	}()
+)
+
+// apiVersions lists every mounted version, including the unversioned paths.
+var apiVersions = []*apiVersion{apiV1, apiV2, apiUnversioned}
+
+type versionKey struct{}
+
+// versioned tags requests with the API version they were routed to. Calls to
+// a deprecated version get Deprecation and Sunset headers, which announce the
+// dates ahead of time. Once the version is deprecated, calls are also logged
+// (see deprecationLog), so remaining clients can be found before the version
+// is removed.
+func (s *Server) versioned(v *apiVersion, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !v.deprecation.IsZero() {
+			w.Header().Set("Deprecation", "@"+strconv.FormatInt(v.deprecation.Unix(), 10))
+			if !v.sunset.IsZero() {
+				w.Header().Set("Sunset", v.sunset.Format(http.TimeFormat))
+			}
+			if calls, ok := s.deprecations.record(v); ok {
+				s.logger.Warn("deprecated API version called",
+					"version", v.name,
+					"calls", calls,
+					"method", r.Method,
+					"path", r.URL.Path,
+					"sunset", v.sunset,
+					"request_id", w.Header().Get("X-Request-ID"),
+				)
+			}
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), versionKey{}, v)))
+	})
+}
+
+// deprecationLogInterval is how often calls to each deprecated version are
+// logged at most.
+const deprecationLogInterval = time.Minute
+
+// deprecationLog samples the calls to deprecated versions, so that busy
+// clients don't write a log line per request. Each line counts the calls
+// since the previous one.
+type deprecationLog struct {
+	mu    sync.Mutex
+	now   func() time.Time
+	last  map[*apiVersion]time.Time
+	calls map[*apiVersion]int
+}
+
+func newDeprecationLog() *deprecationLog {
+	return &deprecationLog{
+		now:   time.Now,
+		last:  make(map[*apiVersion]time.Time),
+		calls: make(map[*apiVersion]int),
+	}
+}
+
+// record counts a call to v. It reports whether the call should be logged,
+// with the number of calls since the last logged one. Calls before v's
+// deprecation date are neither counted nor logged.
+func (l *deprecationLog) record(v *apiVersion) (calls int, ok bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+	if now.Before(v.deprecation) {
+		return 0, false
+	}
+	l.calls[v]++
+	if last, logged := l.last[v]; logged && now.Sub(last) < deprecationLogInterval {
+		return 0, false
+	}
+	calls = l.calls[v]
+	l.last[v], l.calls[v] = now, 0
+	return calls, true
+}
+
+// versionOf returns the API version a request was routed to.
+func versionOf(r *http.Request) *apiVersion {
+	if v, ok := r.Context().Value(versionKey{}).(*apiVersion); ok {
+		return v
+	}
+	return apiUnversioned
+}
+
+// mapAll renders every element of list with f.
+func mapAll[T any](list []T, f func(T) any) []any {
+	out := make([]any, len(list))
+	for i, v := range list {
+		out[i] = f(v)
+	}
+	return out
+}
+
+// userV1Response is a user as rendered by v1, which represents the date of
+// birth as a timestamp at the start of the day in UTC, as it did before dates
+// had a type of their own.
+type userV1Response struct {
+	ID              int       `json:"id"`
+	FirstName       string    `json:"firstName"`
+	LastName        string    `json:"lastName"`
+	DateOfBirth     time.Time `json:"dateOfBirth"`
+	LocationOfBirth string    `json:"locationOfBirth"`
+}
+
+func userV1(u models.User) any {
+	return userV1Response{
+		ID:              u.ID,
+		FirstName:       u.FirstName,
+		LastName:        u.LastName,
+		DateOfBirth:     u.DateOfBirth.In(time.UTC),
+		LocationOfBirth: u.LocationOfBirth,
+	}
+}
+
+// passportV1Response is a passport as rendered by v1, with timestamps.
+type passportV1Response struct {
+	ID           string    `json:"id"`
+	DateOfIssue  time.Time `json:"dateOfIssue"`
+	DateOfExpiry time.Time `json:"dateOfExpiry"`
+	Authority    string    `json:"authority"`
+	UserID       int       `json:"userId"`
+	Status       string    `json:"status"`
+	Replaces     string    `json:"replaces,omitempty"`
+	ReplacedBy   string    `json:"replacedBy,omitempty"`
+}
+
+func passportV1(p models.Passport) any {
+	return passportV1Response{
+		ID:           p.ID,
+		DateOfIssue:  p.DateOfIssue.In(time.UTC),
+		DateOfExpiry: p.DateOfExpiry.In(time.UTC),
+		Authority:    p.Authority,
+		UserID:       p.UserID,
+		Status:       string(p.Status),
+		Replaces:     p.Replaces,
+		ReplacedBy:   p.ReplacedBy,
+	}
+}
+
+// visaV1Response is a visa as rendered by v1, with timestamps.
+type visaV1Response struct {
+	ID         int       `json:"id"`
+	PassportID string    `json:"passportId"`
+	Country    string    `json:"country"`
+	ValidFrom  time.Time `json:"validFrom"`
+	ValidUntil time.Time `json:"validUntil"`
+	Entries    string    `json:"entries"`
+}
+
+func visaV1(v models.Visa) any {
+	return visaV1Response{
+		ID:         v.ID,
+		PassportID: v.PassportID,
+		Country:    v.Country,
+		ValidFrom:  v.ValidFrom.In(time.UTC),
+		ValidUntil: v.ValidUntil.In(time.UTC),
+		Entries:    v.Entries,
+	}
+}
@@ -0,0 +1,165 @@
+package passport
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func getJSON(t *testing.T, handler http.Handler, target string) (*httptest.ResponseRecorder, map[string]any) {
+	t.Helper()
+	r := httptest.NewRequest(http.MethodGet, target, nil)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	require.Equal(t, http.StatusOK, w.Code)
+	var body map[string]any
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	return w, body
+}
+
+func TestVersionedUserRepresentation(t *testing.T) {
+	handler := newTestHandler()
+
+	_, v1 := getJSON(t, handler, "/v1/users/0")
+	assert.Equal(t, "1985-12-31T00:00:00Z", v1["dateOfBirth"])
+
+	_, v2 := getJSON(t, handler, "/v2/users/0")
+	assert.Equal(t, "1985-12-31", v2["dateOfBirth"])
+	assert.Equal(t, "John", v2["firstName"])
+
+	_, legacy := getJSON(t, handler, "/users/0")
+	assert.Equal(t, v1, legacy)
+}
+
+func TestDateInput(t *testing.T) {
+	handler := newTestHandler()
+	tests := []struct {
+		dateOfBirth string
+		want        string
+	}{
+		{"1972-03-07", "1972-03-07"},
+		// Legacy timestamps keep their own date, whatever the offset.
+		{"1972-03-07T23:30:00-05:00", "1972-03-07"},
+		{"1972-03-07T00:30:00+02:00", "1972-03-07"},
+	}
+	for _, tt := range tests {
+		w := postWithKey(handler, "/v2/users", "", `{"firstName":"Apple","lastName":"Jack","dateOfBirth":"`+tt.dateOfBirth+`","locationOfBirth":"Cambridge"}`)
+		require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+		var created map[string]any
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &created))
+		assert.Equal(t, tt.want, created["dateOfBirth"], tt.dateOfBirth)
+
+		_, v1 := getJSON(t, handler, fmt.Sprintf("/v1/users/%v", created["id"]))
+		assert.Equal(t, tt.want+"T00:00:00Z", v1["dateOfBirth"], tt.dateOfBirth)
+	}
+}
+
+// TestRoundTrip checks that every version accepts what it renders, so a
+// client can update a resource by sending back what it read.
+func TestRoundTrip(t *testing.T) {
+	handler := newTestHandler()
+	for _, prefix := range []string{"/v1", "/v2", ""} {
+		for _, path := range []string{"/users/0", "/passports/012345678", "/passports/012345678/visas/1"} {
+			w := sendJSON(handler, http.MethodGet, prefix+path, "")
+			require.Equal(t, http.StatusOK, w.Code, prefix+path)
+			fetched := w.Body.String()
+			w = sendJSON(handler, http.MethodPut, prefix+path, fetched)
+			require.Equal(t, http.StatusOK, w.Code, "%s: %s", prefix+path, w.Body.String())
+			assert.JSONEq(t, fetched, w.Body.String(), prefix+path)
+		}
+	}
+}
+
+func TestVersionedListsAndEmbeds(t *testing.T) {
+	handler := newTestHandler()
+
+	w, body := getJSON(t, handler, "/v2/users?limit=1&include=passports&fields=id,dateOfBirth")
+	assert.Contains(t, w.Header().Get("Link"), "</v2/users?")
+	users := body["users"].([]any)
+	require.Len(t, users, 1)
+	user := users[0].(map[string]any)
+	assert.Equal(t, "1985-12-31", user["dateOfBirth"])
+	passports := user["passports"].([]any)
+	require.Len(t, passports, 1)
+	assert.Equal(t, "2030-01-15", passports[0].(map[string]any)["dateOfExpiry"])
+
+	_, body = getJSON(t, handler, "/v2/users/0/passports")
+	assert.Equal(t, "2020-01-15", body["passports"].([]any)[0].(map[string]any)["dateOfIssue"])
+
+	_, body = getJSON(t, handler, "/v2/search?q=john")
+	result := body["results"].([]any)[0].(map[string]any)
+	assert.Equal(t, "1985-12-31", result["user"].(map[string]any)["dateOfBirth"])
+}
+
+func TestVersionedHALLinks(t *testing.T) {
+	handler := newTestHandler()
+
+	body := getHAL(t, handler, "/v2/passports/012345678")
+	assert.Equal(t, "/v2/passports/012345678", href(t, body, "self"))
+	assert.Equal(t, "/v2/users/0", href(t, body, "owner"))
+	assert.Equal(t, "2030-01-15", body["dateOfExpiry"])
+
+	body = getHAL(t, handler, "/users/0")
+	assert.Equal(t, "/users/0", href(t, body, "self"))
+}
+
+func TestDeprecatedVersionHeaders(t *testing.T) {
+	var logs bytes.Buffer
+	srv := NewTestServer()
+	srv.logger = slog.New(slog.NewTextHandler(&logs, nil))
+	handler := srv.middleware(srv.routes())
+
+	// Before the deprecation date, the headers announce it but nothing is
+	// logged.
+	now := time.Date(2026, time.October, 19, 0, 0, 0, 0, time.UTC)
+	srv.deprecations.now = func() time.Time { return now }
+	for _, target := range []string{"/v1/users", "/users"} {
+		w, _ := getJSON(t, handler, target)
+		assert.Equal(t, "@1798761600", w.Header().Get("Deprecation"), target)
+		assert.Equal(t, "Thu, 01 Jul 2027 00:00:00 GMT", w.Header().Get("Sunset"), target)
+	}
+	assert.NotContains(t, logs.String(), "deprecated")
+
+	// Once deprecated, calls are logged at most once a minute per version,
+	// with the number of calls since the last line.
+	now = time.Date(2027, time.January, 1, 0, 0, 0, 0, time.UTC)
+	for range 3 {
+		getJSON(t, handler, "/v1/users")
+	}
+	assert.Equal(t, 1, strings.Count(logs.String(), "deprecated API version called"))
+	assert.Contains(t, logs.String(), "version=v1 calls=1")
+
+	logs.Reset()
+	now = now.Add(time.Minute)
+	getJSON(t, handler, "/v1/users")
+	assert.Contains(t, logs.String(), "version=v1 calls=3")
+	getJSON(t, handler, "/users")
+	assert.Contains(t, logs.String(), "path=/users")
+
+	logs.Reset()
+	w, _ := getJSON(t, handler, "/v2/users")
+	assert.Empty(t, w.Header().Get("Deprecation"))
+	assert.Empty(t, w.Header().Get("Sunset"))
+	assert.NotContains(t, logs.String(), "deprecated")
+
+	// Operational endpoints are not versioned.
+	w, _ = getJSON(t, handler, "/healthcheck")
+	assert.Empty(t, w.Header().Get("Deprecation"))
+}
+
+func TestBatchDispatchesVersionedPaths(t *testing.T) {
+	_, resp := postBatch(t, newTestHandler(), `{"operations":[{"method":"GET","path":"/v2/users/1"}]}`)
+	require.Len(t, resp.Results, 1)
+	assert.Equal(t, http.StatusOK, resp.Results[0].Status)
+	assert.JSONEq(t, `{"id":1,"firstName":"Jane","lastName":"Doe","dateOfBirth":"1992-01-01","locationOfBirth":"Milton Keynes"}`, string(resp.Results[0].Body))
+	assert.NotContains(t, resp.Results[0].Headers, "Deprecation")
+}
@@ -0,0 +1,158 @@
+package passport
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/leeprovoost/go-rest-api-template/internal/passport/models"
+	"github.com/leeprovoost/go-rest-api-template/pkg/query"
+	"github.com/leeprovoost/go-rest-api-template/pkg/status"
+	"github.com/leeprovoost/go-rest-api-template/pkg/validate"
+)
+
+// visaInPassport returns the visa with the ID in the path, if it is in the
+// passport in the path. Otherwise it writes the error response and returns
+// false.
+func (s *Server) visaInPassport(w http.ResponseWriter, r *http.Request) (models.Visa, bool) {
+	vid, err := strconv.Atoi(r.PathValue("vid"))
+	if err != nil {
+		respondError(w, status.CodeInvalidVisaID, "invalid visa id")
+		return models.Visa{}, false
+	}
+	visa, err := s.visaStore.GetVisa(r.Context(), vid)
+	if err != nil || visa.PassportID != r.PathValue("id") {
+		respondError(w, status.CodeVisaNotFound, "can't find visa")
+		return models.Visa{}, false
+	}
+	return visa, true
+}
+
+func (s *Server) handleListVisas(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	fields, err := parseFields(r, visaSchema)
+	if err != nil {
+		respondError(w, status.CodeInvalidFields, err.Error())
+		return
+	}
+	q, err := query.Parse(r.URL.Query(), visaSchema, listParams...)
+	if err != nil {
+		respondError(w, status.CodeInvalidQuery, err.Error())
+		return
+	}
+	if _, err := s.passportStore.GetPassport(r.Context(), id); err != nil {
+		respondError(w, status.CodePassportNotFound, "can't find passport")
+		return
+	}
+	visas, err := s.visaStore.ListVisasByPassport(r.Context(), id, q)
+	if err != nil {
+		s.logger.Error("failed to list visas", "passportId", id, "error", err)
+		respondError(w, status.CodeInternal, "failed to list visas")
+		return
+	}
+
+	total := len(visas)
+	offset, limit := parsePagination(r)
+	visas = paginate(visas, offset, limit)
+	pg := page{Offset: offset, Limit: limit, Total: total}
+	pg.setHeaders(w, r.URL)
+
+	v := versionOf(r)
+	if wantsHAL(r) {
+		items := make([]any, len(visas))
+		for i, visa := range visas {
+			items[i] = halVisa(v, visa, fields)
+		}
+		links := pg.links(r.URL)
+		links["passport"] = link{Href: v.prefix + "/passports/" + url.PathEscape(id)}
+		respondHAL(w, http.StatusOK, map[string]any{
+			"_links":    links,
+			"_embedded": map[string]any{"visas": items},
+			"count":     len(visas),
+			"total":     total,
+			"offset":    offset,
+			"limit":     limit,
+		})
+		return
+	}
+	respond(w, http.StatusOK, map[string]any{
+		"visas":  projectAll(mapAll(visas, v.visa), fields),
+		"count":  len(visas),
+		"total":  total,
+		"offset": offset,
+		"limit":  limit,
+	})
+}
+
+func (s *Server) handleGetVisa(w http.ResponseWriter, r *http.Request) {
+	fields, err := parseFields(r, visaSchema)
+	if err != nil {
+		respondError(w, status.CodeInvalidFields, err.Error())
+		return
+	}
+	visa, ok := s.visaInPassport(w, r)
+	if !ok {
+		return
+	}
+	respondVisa(w, r, http.StatusOK, visa, fields)
+}
+
+func (s *Server) handleCreateVisa(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	var visa models.Visa
+	if !s.decode(w, r, &visa, status.CodeMalformedVisa) {
+		return
+	}
+	if _, err := s.passportStore.GetPassport(r.Context(), id); err != nil {
+		respondError(w, status.CodePassportNotFound, "can't find passport")
+		return
+	}
+	visa.PassportID = id
+	if errs := validate.Struct(visa); len(errs) > 0 {
+		respondValidationErrors(w, errs)
+		return
+	}
+	visa, err := s.visaStore.AddVisa(r.Context(), visa)
+	if err != nil {
+		s.logger.Error("failed to create visa", "error", err)
+		respondError(w, status.CodeInternal, "something went wrong")
+		return
+	}
+	respondVisa(w, r, http.StatusCreated, visa, nil)
+}
+
+func (s *Server) handleUpdateVisa(w http.ResponseWriter, r *http.Request) {
+	old, ok := s.visaInPassport(w, r)
+	if !ok {
+		return
+	}
+	var visa models.Visa
+	if !s.decode(w, r, &visa, status.CodeMalformedVisa) {
+		return
+	}
+	visa.ID, visa.PassportID = old.ID, old.PassportID
+	if errs := validate.Struct(visa); len(errs) > 0 {
+		respondValidationErrors(w, errs)
+		return
+	}
+	visa, err := s.visaStore.UpdateVisa(r.Context(), visa)
+	if err != nil {
+		s.logger.Error("failed to update visa", "error", err)
+		respondError(w, status.CodeInternal, "something went wrong")
+		return
+	}
+	respondVisa(w, r, http.StatusOK, visa, nil)
+}
+
+func (s *Server) handleDeleteVisa(w http.ResponseWriter, r *http.Request) {
+	visa, ok := s.visaInPassport(w, r)
+	if !ok {
+		return
+	}
+	if err := s.visaStore.DeleteVisa(r.Context(), visa.ID); err != nil {
+		s.logger.Error("failed to delete visa", "error", err)
+		respondError(w, status.CodeInternal, "something went wrong")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
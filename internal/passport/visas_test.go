@@ -0,0 +1,124 @@
+package passport
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/leeprovoost/go-rest-api-template/pkg/status"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const indiaVisa = `{"country":"IND","validFrom":"2024-01-01T00:00:00Z","validUntil":"2024-06-30T00:00:00Z","entries":"single"}`
+
+// sendJSON sends body to target with the given method.
+func sendJSON(handler http.Handler, method, target, body string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, target, strings.NewReader(body))
+	r.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	return w
+}
+
+func TestListVisas(t *testing.T) {
+	handler := newTestHandler()
+
+	_, body := getJSON(t, handler, "/v2/passports/012345678/visas")
+	assert.EqualValues(t, 1, body["total"])
+	assert.Equal(t, []any{map[string]any{
+		"id":         float64(1),
+		"passportId": "012345678",
+		"country":    "USA",
+		"validFrom":  "2023-05-01",
+		"validUntil": "2033-04-30",
+		"entries":    "multiple",
+	}}, body["visas"])
+
+	_, body = getJSON(t, handler, "/passports/012345678/visas?country=IND")
+	assert.EqualValues(t, 0, body["total"])
+	_, body = getJSON(t, handler, "/passports/012345678/visas?fields=country")
+	assert.Equal(t, []any{map[string]any{"country": "USA"}}, body["visas"])
+
+	hal := getHAL(t, handler, "/v2/passports/012345678/visas")
+	assert.Equal(t, "/v2/passports/012345678", href(t, hal, "passport"))
+
+	w := sendJSON(handler, http.MethodGet, "/passports/nope/visas", "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Contains(t, w.Body.String(), string(status.CodePassportNotFound))
+}
+
+func TestVisaLifecycle(t *testing.T) {
+	handler := newTestHandler()
+
+	w := sendJSON(handler, http.MethodPost, "/v2/passports/987654321/visas", indiaVisa)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	var created map[string]any
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &created))
+	assert.Equal(t, float64(2), created["id"])
+	assert.Equal(t, "987654321", created["passportId"])
+
+	hal := getHAL(t, handler, "/v2/passports/987654321/visas/2")
+	assert.Equal(t, "/v2/passports/987654321/visas/2", href(t, hal, "self"))
+	assert.Equal(t, "/v2/passports/987654321", href(t, hal, "passport"))
+
+	w = sendJSON(handler, http.MethodPut, "/v2/passports/987654321/visas/2", strings.Replace(indiaVisa, "single", "double", 1))
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Contains(t, w.Body.String(), `"entries":"double"`)
+
+	// The visa is only reachable through its own passport.
+	w = sendJSON(handler, http.MethodGet, "/passports/012345678/visas/2", "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Contains(t, w.Body.String(), string(status.CodeVisaNotFound))
+
+	w = sendJSON(handler, http.MethodDelete, "/passports/987654321/visas/2", "")
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	w = sendJSON(handler, http.MethodGet, "/passports/987654321/visas/2", "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestVisaDates(t *testing.T) {
+	handler := newTestHandler()
+
+	// A v2 client can send back the visa it read.
+	w := sendJSON(handler, http.MethodGet, "/v2/passports/012345678/visas/1", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	fetched := w.Body.String()
+	w = sendJSON(handler, http.MethodPut, "/v2/passports/012345678/visas/1", fetched)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.JSONEq(t, fetched, w.Body.String())
+
+	// v1 renders the same dates as timestamps.
+	_, body := getJSON(t, handler, "/v1/passports/012345678/visas/1")
+	assert.Equal(t, "2023-05-01T00:00:00Z", body["validFrom"])
+	assert.Equal(t, "2033-04-30T00:00:00Z", body["validUntil"])
+}
+
+func TestCreateVisaErrors(t *testing.T) {
+	handler := newTestHandler()
+
+	w := sendJSON(handler, http.MethodPost, "/passports/987654321/visas", `{"country":"India","validFrom":"2024-06-30T00:00:00Z","validUntil":"2024-01-01T00:00:00Z","entries":"twice"}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	var resp status.Response
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	fields := make(map[string]string)
+	for _, f := range resp.Fields {
+		fields[f.Path()] = f.Message
+	}
+	assert.Contains(t, fields, "country")
+	assert.Contains(t, fields, "validUntil")
+	assert.Contains(t, fields, "entries")
+
+	w = sendJSON(handler, http.MethodPost, "/passports/987654321/visas", `{"country":`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), string(status.CodeMalformedVisa))
+
+	w = sendJSON(handler, http.MethodPost, "/passports/nope/visas", indiaVisa)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	w = sendJSON(handler, http.MethodGet, "/passports/012345678/visas/abc", "")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), string(status.CodeInvalidVisaID))
+}
@@ -0,0 +1,59 @@
+// Package blob stores binary objects, such as uploaded files, under
+// slash-separated keys. Store is the interface the API depends on; FS keeps
+// blobs on the local filesystem and Memory keeps them in memory.
+package blob
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"time"
+)
+
+// ErrNotFound is returned when no blob exists with the given key.
+var ErrNotFound = errors.New("blob not found")
+
+// ErrInvalidKey is returned for keys that are empty, start or end with a
+// slash, or contain empty, "." or ".." segments or backslashes.
+var ErrInvalidKey = errors.New("invalid blob key")
+
+// Info describes a stored blob. Size and SHA256 are computed by the store
+// while the blob is written.
+type Info struct {
+	Key         string            `json:"key"`
+	Size        int64             `json:"size"`
+	ContentType string            `json:"contentType"`
+	SHA256      string            `json:"sha256"` // hex encoded
+	Metadata    map[string]string `json:"metadata,omitempty"`
+	ModTime     time.Time         `json:"modTime"`
+}
+
+// Store is a blob store. Implementations must be safe for concurrent use.
+type Store interface {
+	// Put writes the contents of r under key, replacing any blob with the
+	// same key. If reading r fails, nothing is stored and the error is
+	// returned as is, so callers can limit the size of r with a reader that
+	// fails once the limit is reached.
+	Put(ctx context.Context, key string, r io.Reader, contentType string, metadata map[string]string) (Info, error)
+	// Open returns the contents of the blob, which the caller must close,
+	// and its Info. The contents can be read in any order, for example to
+	// serve HTTP range requests.
+	Open(ctx context.Context, key string) (io.ReadSeekCloser, Info, error)
+	// List returns the blobs whose keys start with prefix, ordered by key.
+	List(ctx context.Context, prefix string) ([]Info, error)
+	Delete(ctx context.Context, key string) error
+}
+
+// checkKey returns ErrInvalidKey if key can't be used as a blob key.
+func checkKey(key string) error {
+	if key == "" || strings.ContainsAny(key, "\\\x00") {
+		return ErrInvalidKey
+	}
+	for _, seg := range strings.Split(key, "/") {
+		if seg == "" || seg == "." || seg == ".." {
+			return ErrInvalidKey
+		}
+	}
+	return nil
+}
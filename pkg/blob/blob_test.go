@@ -0,0 +1,99 @@
+package blob
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// stores returns a fresh instance of every Store implementation.
+func stores(t *testing.T) map[string]Store {
+	fs, err := NewFS(t.TempDir())
+	require.NoError(t, err)
+	return map[string]Store{"FS": fs, "Memory": NewMemory()}
+}
+
+func TestPutOpen(t *testing.T) {
+	for name, s := range stores(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			info, err := s.Put(ctx, "a/b/hello.txt", strings.NewReader("hello world"), "text/plain", map[string]string{"filename": "hello.txt"})
+			require.NoError(t, err)
+			assert.Equal(t, int64(11), info.Size)
+			assert.Equal(t, "b94d27b9934d3e08a52e52d7da7dabfac484efe37a5380ee9088f7ace2efcde9", info.SHA256)
+
+			rc, got, err := s.Open(ctx, "a/b/hello.txt")
+			require.NoError(t, err)
+			defer rc.Close()
+			assert.Equal(t, info.SHA256, got.SHA256)
+			assert.Equal(t, "text/plain", got.ContentType)
+			assert.Equal(t, "hello.txt", got.Metadata["filename"])
+
+			_, err = rc.Seek(6, io.SeekStart)
+			require.NoError(t, err)
+			rest, err := io.ReadAll(rc)
+			require.NoError(t, err)
+			assert.Equal(t, "world", string(rest))
+
+			_, _, err = s.Open(ctx, "a/b/missing")
+			assert.ErrorIs(t, err, ErrNotFound)
+		})
+	}
+}
+
+func TestPutFailedRead(t *testing.T) {
+	for name, s := range stores(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			tooLarge := errors.New("too large")
+			_, err := s.Put(ctx, "k", iotest.ErrReader(tooLarge), "text/plain", nil)
+			assert.ErrorIs(t, err, tooLarge)
+
+			_, _, err = s.Open(ctx, "k")
+			assert.ErrorIs(t, err, ErrNotFound)
+			list, err := s.List(ctx, "")
+			require.NoError(t, err)
+			assert.Empty(t, list)
+		})
+	}
+}
+
+func TestListDelete(t *testing.T) {
+	for name, s := range stores(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			for _, key := range []string{"p/2/b", "p/1/b", "p/1/a", "q/1/a"} {
+				_, err := s.Put(ctx, key, strings.NewReader(key), "text/plain", nil)
+				require.NoError(t, err)
+			}
+			list, err := s.List(ctx, "p/1/")
+			require.NoError(t, err)
+			require.Len(t, list, 2)
+			assert.Equal(t, "p/1/a", list[0].Key)
+			assert.Equal(t, "p/1/b", list[1].Key)
+
+			require.NoError(t, s.Delete(ctx, "p/1/a"))
+			assert.ErrorIs(t, s.Delete(ctx, "p/1/a"), ErrNotFound)
+			list, err = s.List(ctx, "p/")
+			require.NoError(t, err)
+			assert.Len(t, list, 2)
+		})
+	}
+}
+
+func TestInvalidKeys(t *testing.T) {
+	for name, s := range stores(t) {
+		t.Run(name, func(t *testing.T) {
+			for _, key := range []string{"", "/a", "a/", "a//b", "../a", "a/./b", `a\b`} {
+				_, err := s.Put(context.Background(), key, strings.NewReader("x"), "text/plain", nil)
+				assert.ErrorIs(t, err, ErrInvalidKey, key)
+			}
+		})
+	}
+}
@@ -0,0 +1,206 @@
+package blob
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/fs"
+	"maps"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+var _ Store = (*FS)(nil)
+
+// FS is a Store that keeps blobs as files under a directory: the contents of
+// a blob with key k in data/k, and its Info in meta/k.json. Files are
+// written under a temporary name and renamed into place, so readers never
+// see a partly written blob.
+type FS struct {
+	dir string
+}
+
+// NewFS returns an FS that keeps blobs under dir, which is created if it
+// doesn't exist.
+func NewFS(dir string) (*FS, error) {
+	for _, sub := range []string{"data", "meta"} {
+		if err := os.MkdirAll(filepath.Join(dir, sub), 0o750); err != nil {
+			return nil, err
+		}
+	}
+	return &FS{dir: dir}, nil
+}
+
+func (s *FS) dataPath(key string) string {
+	return filepath.Join(s.dir, "data", filepath.FromSlash(key))
+}
+
+func (s *FS) metaPath(key string) string {
+	return filepath.Join(s.dir, "meta", filepath.FromSlash(key)+".json")
+}
+
+// Put writes the contents of r under key.
+func (s *FS) Put(ctx context.Context, key string, r io.Reader, contentType string, metadata map[string]string) (Info, error) {
+	if err := checkKey(key); err != nil {
+		return Info{}, err
+	}
+	path := s.dataPath(key)
+	if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+		return Info{}, err
+	}
+	f, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return Info{}, err
+	}
+	defer os.Remove(f.Name()) // fails harmlessly once renamed
+
+	h := sha256.New()
+	size, err := io.Copy(io.MultiWriter(f, h), r)
+	if err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return Info{}, err
+	}
+
+	info := Info{
+		Key:         key,
+		Size:        size,
+		ContentType: contentType,
+		SHA256:      hex.EncodeToString(h.Sum(nil)),
+		Metadata:    maps.Clone(metadata),
+		ModTime:     time.Now().UTC(),
+	}
+	if err := os.Rename(f.Name(), path); err != nil {
+		return Info{}, err
+	}
+	if err := s.writeInfo(info); err != nil {
+		os.Remove(path)
+		return Info{}, err
+	}
+	return info, nil
+}
+
+// writeInfo writes the Info file of a blob.
+func (s *FS) writeInfo(info Info) error {
+	data, err := json.Marshal(info)
+	if err != nil {
+		return err
+	}
+	path := s.metaPath(info.Key)
+	if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	_, err = f.Write(data)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
+
+// readInfo reads the Info file of a blob.
+func (s *FS) readInfo(key string) (Info, error) {
+	data, err := os.ReadFile(s.metaPath(key))
+	if errors.Is(err, fs.ErrNotExist) {
+		return Info{}, ErrNotFound
+	}
+	if err != nil {
+		return Info{}, err
+	}
+	var info Info
+	err = json.Unmarshal(data, &info)
+	return info, err
+}
+
+// Open returns the contents and Info of the blob.
+func (s *FS) Open(ctx context.Context, key string) (io.ReadSeekCloser, Info, error) {
+	if err := checkKey(key); err != nil {
+		return nil, Info{}, ErrNotFound
+	}
+	info, err := s.readInfo(key)
+	if err != nil {
+		return nil, Info{}, err
+	}
+	f, err := os.Open(s.dataPath(key))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, Info{}, ErrNotFound
+	}
+	if err != nil {
+		return nil, Info{}, err
+	}
+	return f, info, nil
+}
+
+// List returns the blobs whose keys start with prefix, ordered by key.
+func (s *FS) List(ctx context.Context, prefix string) ([]Info, error) {
+	root := filepath.Join(s.dir, "meta")
+	list := []Info{}
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || strings.HasPrefix(d.Name(), ".tmp-") || !strings.HasSuffix(d.Name(), ".json") {
+			return nil
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		key := strings.TrimSuffix(filepath.ToSlash(rel), ".json")
+		if !strings.HasPrefix(key, prefix) {
+			return nil
+		}
+		info, err := s.readInfo(key)
+		if errors.Is(err, ErrNotFound) {
+			return nil // deleted while listing
+		}
+		if err != nil {
+			return err
+		}
+		list = append(list, info)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Key < list[j].Key
+	})
+	return list, nil
+}
+
+// Delete removes the blob. Its Info goes first, so that a blob whose
+// contents couldn't be removed is no longer listed.
+func (s *FS) Delete(ctx context.Context, key string) error {
+	if err := checkKey(key); err != nil {
+		return ErrNotFound
+	}
+	err := os.Remove(s.metaPath(key))
+	if errors.Is(err, fs.ErrNotExist) {
+		return ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(s.dataPath(key)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
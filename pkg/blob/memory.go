@@ -0,0 +1,99 @@
+package blob
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"maps"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+var _ Store = (*Memory)(nil)
+
+// Memory is a Store that keeps blobs in memory. It suits tests and local
+// development, where losing the blobs on restart doesn't matter.
+type Memory struct {
+	mu    sync.RWMutex
+	blobs map[string]memoryBlob
+}
+
+type memoryBlob struct {
+	data []byte
+	info Info
+}
+
+// NewMemory returns an empty Memory store.
+func NewMemory() *Memory {
+	return &Memory{blobs: make(map[string]memoryBlob)}
+}
+
+// Put stores the contents of r under key.
+func (m *Memory) Put(ctx context.Context, key string, r io.Reader, contentType string, metadata map[string]string) (Info, error) {
+	if err := checkKey(key); err != nil {
+		return Info{}, err
+	}
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return Info{}, err
+	}
+	sum := sha256.Sum256(data)
+	info := Info{
+		Key:         key,
+		Size:        int64(len(data)),
+		ContentType: contentType,
+		SHA256:      hex.EncodeToString(sum[:]),
+		Metadata:    maps.Clone(metadata),
+		ModTime:     time.Now().UTC(),
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.blobs[key] = memoryBlob{data: data, info: info}
+	return info, nil
+}
+
+// Open returns the contents and Info of the blob.
+func (m *Memory) Open(ctx context.Context, key string) (io.ReadSeekCloser, Info, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	b, ok := m.blobs[key]
+	if !ok {
+		return nil, Info{}, ErrNotFound
+	}
+	return nopCloser{bytes.NewReader(b.data)}, b.info, nil
+}
+
+// List returns the blobs whose keys start with prefix, ordered by key.
+func (m *Memory) List(ctx context.Context, prefix string) ([]Info, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	list := []Info{}
+	for key, b := range m.blobs {
+		if strings.HasPrefix(key, prefix) {
+			list = append(list, b.info)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Key < list[j].Key
+	})
+	return list, nil
+}
+
+// Delete removes the blob.
+func (m *Memory) Delete(ctx context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.blobs[key]; !ok {
+		return ErrNotFound
+	}
+	delete(m.blobs, key)
+	return nil
+}
+
+type nopCloser struct{ io.ReadSeeker }
+
+func (nopCloser) Close() error { return nil }
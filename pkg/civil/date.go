@@ -0,0 +1,175 @@
+// Package civil provides a calendar date without a time of day or time zone,
+// for dates such as birthdays that are the same wherever they are read.
+//
+// A Date is written as YYYY-MM-DD in JSON and text. For compatibility with
+// clients that send timestamps, it also accepts RFC 3339, keeping the date
+// in the timestamp's own offset, so "1985-12-31T23:00:00-05:00" is
+// 1985-12-31 rather than the next day in UTC.
+package civil
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"time"
+)
+
+// Date is a calendar date. The zero Date is not a valid date; IsZero reports
+// whether a Date is unset.
+type Date struct {
+	Year  int
+	Month time.Month
+	Day   int
+}
+
+// ParseError is returned for text that isn't a date.
+type ParseError struct {
+	Value    string // the text that was parsed
+	Expected string // the formats that are accepted
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("civil: invalid date %q: expected %s", e.Value, e.Expected)
+}
+
+// DateOf returns the date of t in t's location. The zero time.Time, which
+// Go code uses for "unset", has the zero Date.
+func DateOf(t time.Time) Date {
+	if t.IsZero() {
+		return Date{}
+	}
+	y, m, d := t.Date()
+	return Date{Year: y, Month: m, Day: d}
+}
+
+// Today returns the current date in UTC.
+func Today() Date {
+	return DateOf(time.Now().UTC())
+}
+
+// ParseDate parses a date written as YYYY-MM-DD.
+func ParseDate(s string) (Date, error) {
+	t, err := time.Parse(time.DateOnly, s)
+	if err != nil {
+		return Date{}, &ParseError{Value: s, Expected: "YYYY-MM-DD"}
+	}
+	return DateOf(t), nil
+}
+
+// parseLenient parses a date written as YYYY-MM-DD or as an RFC 3339
+// timestamp.
+func parseLenient(s string) (Date, error) {
+	if d, err := ParseDate(s); err == nil {
+		return d, nil
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return Date{}, &ParseError{Value: s, Expected: "YYYY-MM-DD or an RFC 3339 timestamp"}
+	}
+	return DateOf(t), nil
+}
+
+// String returns the date as YYYY-MM-DD.
+func (d Date) String() string {
+	return fmt.Sprintf("%04d-%02d-%02d", d.Year, d.Month, d.Day)
+}
+
+// IsZero reports whether d is the zero Date.
+func (d Date) IsZero() bool {
+	return d == Date{}
+}
+
+// IsValid reports whether d is a date that exists, so not 2023-02-29 or the
+// zero Date.
+func (d Date) IsValid() bool {
+	return !d.IsZero() && DateOf(d.In(time.UTC)) == d
+}
+
+// In returns the start of the day d in loc, or the zero time.Time for the
+// zero Date.
+func (d Date) In(loc *time.Location) time.Time {
+	if d.IsZero() {
+		return time.Time{}
+	}
+	return time.Date(d.Year, d.Month, d.Day, 0, 0, 0, 0, loc)
+}
+
+// AddDate returns d plus the given years, months and days, normalized like
+// time.Time.AddDate.
+func (d Date) AddDate(years, months, days int) Date {
+	return DateOf(d.In(time.UTC).AddDate(years, months, days))
+}
+
+// Compare returns -1 if d is before other, 0 if they are the same date and
+// +1 if d is after other.
+func (d Date) Compare(other Date) int {
+	switch {
+	case d.Year != other.Year:
+		return cmpInt(d.Year, other.Year)
+	case d.Month != other.Month:
+		return cmpInt(int(d.Month), int(other.Month))
+	}
+	return cmpInt(d.Day, other.Day)
+}
+
+func cmpInt(a, b int) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// Before reports whether d is before other.
+func (d Date) Before(other Date) bool {
+	return d.Compare(other) < 0
+}
+
+// After reports whether d is after other.
+func (d Date) After(other Date) bool {
+	return d.Compare(other) > 0
+}
+
+// MarshalText writes d as YYYY-MM-DD. It is used for JSON too.
+func (d Date) MarshalText() ([]byte, error) {
+	return []byte(d.String()), nil
+}
+
+// UnmarshalText reads a date written as YYYY-MM-DD or, for compatibility,
+// as an RFC 3339 timestamp. It is used for JSON too.
+func (d *Date) UnmarshalText(text []byte) error {
+	parsed, err := parseLenient(string(text))
+	if err != nil {
+		return err
+	}
+	*d = parsed
+	return nil
+}
+
+// Value implements driver.Valuer. A date is stored as the start of its day
+// in UTC, which SQL drivers map to DATE columns; the zero Date is NULL.
+func (d Date) Value() (driver.Value, error) {
+	if d.IsZero() {
+		return nil, nil
+	}
+	return d.In(time.UTC), nil
+}
+
+// Scan implements sql.Scanner for DATE columns, which drivers return as
+// time.Time, string or []byte. NULL scans to the zero Date.
+func (d *Date) Scan(src any) error {
+	switch v := src.(type) {
+	case nil:
+		*d = Date{}
+	case time.Time:
+		*d = DateOf(v)
+	case string:
+		return d.UnmarshalText([]byte(v))
+	case []byte:
+		return d.UnmarshalText(v)
+	default:
+		return fmt.Errorf("civil: can't scan %T into a Date", src)
+	}
+	return nil
+}
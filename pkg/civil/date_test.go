@@ -0,0 +1,78 @@
+package civil
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDateJSON(t *testing.T) {
+	d := Date{1985, time.December, 31}
+	b, err := json.Marshal(map[string]Date{"dateOfBirth": d})
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"dateOfBirth":"1985-12-31"}`, string(b))
+
+	tests := []struct {
+		in   string
+		want Date
+	}{
+		{`"1985-12-31"`, d},
+		{`"1985-12-31T00:00:00Z"`, d},
+		// Legacy timestamps keep the date in their own offset.
+		{`"1985-12-31T23:00:00-05:00"`, d},
+		{`"1986-01-01T00:30:00+01:00"`, Date{1986, time.January, 1}},
+		// Go's zero time meant "unset".
+		{`"0001-01-01T00:00:00Z"`, Date{}},
+	}
+	for _, tt := range tests {
+		var got Date
+		require.NoError(t, json.Unmarshal([]byte(tt.in), &got), tt.in)
+		assert.Equal(t, tt.want, got, tt.in)
+	}
+
+	for _, in := range []string{`"31/12/1985"`, `"1985-02-30"`, `""`, `19851231`} {
+		var got Date
+		assert.Error(t, json.Unmarshal([]byte(in), &got), in)
+	}
+	var got Date
+	var parseErr *ParseError
+	require.ErrorAs(t, json.Unmarshal([]byte(`"yesterday"`), &got), &parseErr)
+	assert.Equal(t, "yesterday", parseErr.Value)
+}
+
+func TestDateCompare(t *testing.T) {
+	a := Date{2024, time.February, 29}
+	b := Date{2024, time.March, 1}
+	assert.True(t, a.Before(b))
+	assert.True(t, b.After(a))
+	assert.Equal(t, 0, a.Compare(a))
+	assert.Equal(t, b, a.AddDate(0, 0, 1))
+	assert.Equal(t, Date{2025, time.March, 1}, a.AddDate(1, 0, 0))
+	assert.True(t, a.IsValid())
+	assert.False(t, Date{2023, time.February, 29}.IsValid())
+	assert.True(t, Date{}.IsZero())
+	assert.False(t, Date{}.IsValid())
+}
+
+func TestDateSQL(t *testing.T) {
+	d := Date{1985, time.December, 31}
+	v, err := d.Value()
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(1985, time.December, 31, 0, 0, 0, 0, time.UTC), v)
+	v, err = Date{}.Value()
+	require.NoError(t, err)
+	assert.Nil(t, v)
+
+	for _, src := range []any{"1985-12-31", []byte("1985-12-31"), time.Date(1985, time.December, 31, 0, 0, 0, 0, time.FixedZone("", -5*3600))} {
+		var got Date
+		require.NoError(t, got.Scan(src))
+		assert.Equal(t, d, got)
+	}
+	got := d
+	require.NoError(t, got.Scan(nil))
+	assert.True(t, got.IsZero())
+	assert.Error(t, got.Scan(42))
+}
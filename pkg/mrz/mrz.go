@@ -0,0 +1,284 @@
+// Package mrz parses and generates the machine readable zone (MRZ) of
+// passports, the two 44-character lines of a TD3 document described in ICAO
+// Doc 9303 part 4.
+package mrz
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// LineLength is the length of each of the two lines of a TD3 MRZ.
+const LineLength = 44
+
+// Lengths of the variable-length fields. Shorter values are padded with the
+// filler character '<'.
+const (
+	namesLength          = 39
+	documentNumberLength = 9
+	personalNumberLength = 14
+)
+
+// Passport holds the fields of a TD3 MRZ. Text fields hold the characters of
+// the MRZ with fillers removed: names are separated by spaces and personal
+// numbers may be empty.
+type Passport struct {
+	// DocumentCode is "P", optionally followed by a letter for the type of
+	// passport, e.g. "PD" for diplomatic passports.
+	DocumentCode string
+	// IssuingState and Nationality are three-letter codes, e.g. GBR.
+	IssuingState   string
+	Surname        string
+	GivenNames     string
+	DocumentNumber string
+	Nationality    string
+	DateOfBirth    time.Time
+	// Sex is M, F or X; it is empty when unspecified.
+	Sex            string
+	DateOfExpiry   time.Time
+	PersonalNumber string
+}
+
+// now is the clock used to pick the century of two-digit years.
+var now = time.Now
+
+// Parse parses a TD3 MRZ: two lines of 44 characters, separated by a newline
+// or other whitespace. It verifies every check digit.
+func Parse(s string) (Passport, error) {
+	lines := strings.Fields(s)
+	if len(lines) != 2 {
+		return Passport{}, fmt.Errorf("must have 2 lines, got %d", len(lines))
+	}
+	for i, line := range lines {
+		if len(line) != LineLength {
+			return Passport{}, fmt.Errorf("line %d must be %d characters, got %d", i+1, LineLength, len(line))
+		}
+		if j := strings.IndexFunc(line, func(r rune) bool { return !isMRZChar(r) }); j >= 0 {
+			return Passport{}, fmt.Errorf("line %d, column %d: %q is not allowed; use A-Z, 0-9 and <", i+1, j+1, line[j])
+		}
+	}
+	l1, l2 := lines[0], lines[1]
+
+	var p Passport
+	if l1[0] != 'P' {
+		return Passport{}, fmt.Errorf("line 1: document code must start with P, got %q", l1[0])
+	}
+	p.DocumentCode = unpad(l1[0:2])
+	p.IssuingState = unpad(l1[2:5])
+	p.Surname, p.GivenNames = splitNames(l1[5:])
+
+	type check struct {
+		name  string
+		value string
+		digit byte
+	}
+	checks := []check{
+		{"document number", l2[0:9], l2[9]},
+		{"date of birth", l2[13:19], l2[19]},
+		{"date of expiry", l2[21:27], l2[27]},
+	}
+	// An empty personal number may have a filler as its check digit.
+	if l2[28:43] != strings.Repeat("<", personalNumberLength+1) {
+		checks = append(checks, check{"personal number", l2[28:42], l2[42]})
+	}
+	checks = append(checks, check{"composite", l2[0:10] + l2[13:20] + l2[21:43], l2[43]})
+	for _, c := range checks {
+		if want := CheckDigit(c.value); c.digit != want {
+			return Passport{}, fmt.Errorf("line 2: %s check digit is %q, expected %q", c.name, c.digit, want)
+		}
+	}
+
+	var err error
+	p.DocumentNumber = unpad(l2[0:9])
+	p.Nationality = unpad(l2[10:13])
+	if p.DateOfBirth, err = parseDate(l2[13:19], false); err != nil {
+		return Passport{}, fmt.Errorf("line 2: date of birth %w", err)
+	}
+	switch sex := l2[20]; sex {
+	case 'M', 'F', 'X':
+		p.Sex = string(sex)
+	case '<':
+	default:
+		return Passport{}, fmt.Errorf("line 2: sex must be M, F, X or <, got %q", sex)
+	}
+	if p.DateOfExpiry, err = parseDate(l2[21:27], true); err != nil {
+		return Passport{}, fmt.Errorf("line 2: date of expiry %w", err)
+	}
+	p.PersonalNumber = unpad(l2[28:42])
+	return p, nil
+}
+
+// Lines returns the two lines of the MRZ of p. Names are transliterated to
+// the MRZ character set and truncated to fit; other fields must already fit.
+func (p Passport) Lines() ([2]string, error) {
+	var errs []error
+	field := func(name, value string, minLen, maxLen int, letters bool) string {
+		if len(value) < minLen || len(value) > maxLen || strings.IndexFunc(value, func(r rune) bool {
+			return !isMRZChar(r) || r == '<' || (letters && !(r >= 'A' && r <= 'Z'))
+		}) >= 0 {
+			kind := "capital letters or digits"
+			if letters {
+				kind = "capital letters"
+			}
+			if minLen == maxLen {
+				errs = append(errs, fmt.Errorf("%s must be %d %s", name, maxLen, kind))
+			} else {
+				errs = append(errs, fmt.Errorf("%s must be %d to %d %s", name, minLen, maxLen, kind))
+			}
+		}
+		return pad(value, maxLen)
+	}
+	if len(p.DocumentCode) < 1 || len(p.DocumentCode) > 2 || p.DocumentCode[0] != 'P' ||
+		(len(p.DocumentCode) == 2 && !(p.DocumentCode[1] >= 'A' && p.DocumentCode[1] <= 'Z')) {
+		errs = append(errs, errors.New("document code must be P, optionally followed by a capital letter"))
+	}
+	code := pad(strings.TrimPrefix(p.DocumentCode, "P"), 1)
+	state := field("issuing state", p.IssuingState, 3, 3, true)
+	number := field("document number", p.DocumentNumber, 1, documentNumberLength, false)
+	nationality := field("nationality", p.Nationality, 3, 3, true)
+	personal := field("personal number", p.PersonalNumber, 0, personalNumberLength, false)
+	sex := "<"
+	switch p.Sex {
+	case "M", "F", "X":
+		sex = p.Sex
+	case "":
+	default:
+		errs = append(errs, fmt.Errorf("sex must be M, F, X or empty, got %q", p.Sex))
+	}
+	if p.DateOfBirth.IsZero() || p.DateOfExpiry.IsZero() {
+		errs = append(errs, errors.New("date of birth and date of expiry are required"))
+	}
+	if err := errors.Join(errs...); err != nil {
+		return [2]string{}, err
+	}
+
+	names := Transliterate(p.Surname) + "<<" + Transliterate(p.GivenNames)
+	line1 := "P" + code + state + pad(names[:min(len(names), namesLength)], namesLength)
+
+	dob := p.DateOfBirth.Format("060102")
+	doe := p.DateOfExpiry.Format("060102")
+	personalCheck := CheckDigit(personal)
+	if p.PersonalNumber == "" {
+		personalCheck = '<'
+	}
+	line2 := number + string(CheckDigit(number)) + nationality +
+		dob + string(CheckDigit(dob)) + sex +
+		doe + string(CheckDigit(doe)) +
+		personal + string(personalCheck)
+	composite := line2[0:10] + line2[13:20] + line2[21:43]
+	line2 += string(CheckDigit(composite))
+	return [2]string{line1, line2}, nil
+}
+
+// CheckDigit computes the check digit of s: the sum of its character values
+// (digits are themselves, A-Z are 10-35 and < is 0) weighted 7, 3, 1 in
+// turn, modulo 10.
+func CheckDigit(s string) byte {
+	weights := [3]int{7, 3, 1}
+	sum := 0
+	for i := 0; i < len(s); i++ {
+		var v int
+		switch c := s[i]; {
+		case c >= '0' && c <= '9':
+			v = int(c - '0')
+		case c >= 'A' && c <= 'Z':
+			v = int(c-'A') + 10
+		}
+		sum += v * weights[i%3]
+	}
+	return byte('0' + sum%10)
+}
+
+// transliterations are the ICAO recommended transliterations of Latin
+// characters that don't decompose into a letter and diacritics.
+var transliterations = map[rune]string{
+	'Æ': "AE", 'Ø': "OE", 'Œ': "OE", 'ß': "SS", 'Þ': "TH", 'Ð': "D", 'Đ': "D", 'Ł': "L", 'Ħ': "H", 'Ŋ': "N", 'Ŧ': "T",
+}
+
+// Transliterate converts a name to MRZ characters: letters are upper-cased
+// and stripped of diacritics, spaces and hyphens become '<', and other
+// characters, such as apostrophes, are dropped.
+func Transliterate(name string) string {
+	var b strings.Builder
+	for _, r := range norm.NFD.String(strings.TrimSpace(name)) {
+		if t, ok := transliterations[r]; ok {
+			b.WriteString(t)
+			continue
+		}
+		r = unicode.ToUpper(r)
+		switch {
+		case r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			b.WriteRune(r)
+		case r == ' ', r == '-':
+			b.WriteByte('<')
+		default:
+			if t, ok := transliterations[r]; ok {
+				b.WriteString(t)
+			}
+		}
+	}
+	return b.String()
+}
+
+func isMRZChar(r rune) bool {
+	return (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') || r == '<'
+}
+
+// pad fills s up to n characters with '<'.
+func pad(s string, n int) string {
+	return s + strings.Repeat("<", max(0, n-len(s)))
+}
+
+// unpad removes fillers from the end of s.
+func unpad(s string) string {
+	return strings.TrimRight(s, "<")
+}
+
+// splitNames splits the name field into the surname and given names, which
+// are separated by "<<", and replaces the fillers between names with spaces.
+func splitNames(field string) (string, string) {
+	surname, given, _ := strings.Cut(unpad(field), "<<")
+	spaces := func(s string) string {
+		return strings.Join(strings.FieldsFunc(s, func(r rune) bool { return r == '<' }), " ")
+	}
+	return spaces(surname), spaces(given)
+}
+
+// parseDate parses a YYMMDD date. Dates of birth are in the past, so they
+// are placed in the latest century that isn't in the future; expiry dates
+// are placed in the century that puts them closest to today.
+func parseDate(s string, expiry bool) (time.Time, error) {
+	yy, err := strconv.Atoi(s[0:2])
+	if err != nil {
+		return time.Time{}, fmt.Errorf("must be YYMMDD, got %q", s)
+	}
+	month, err1 := strconv.Atoi(s[2:4])
+	day, err2 := strconv.Atoi(s[4:6])
+	if err1 != nil || err2 != nil {
+		return time.Time{}, fmt.Errorf("must be YYMMDD, got %q", s)
+	}
+	today := now().UTC()
+	century := today.Year() / 100 * 100
+	year := century + yy
+	if expiry {
+		if year-today.Year() > 50 {
+			year -= 100
+		} else if today.Year()-year > 50 {
+			year += 100
+		}
+	}
+	t := time.Date(year, time.Month(month), day, 0, 0, 0, 0, time.UTC)
+	if t.Month() != time.Month(month) || t.Day() != day {
+		return time.Time{}, fmt.Errorf("%q is not a valid date", s)
+	}
+	if !expiry && t.After(today) {
+		t = t.AddDate(-100, 0, 0)
+	}
+	return t, nil
+}
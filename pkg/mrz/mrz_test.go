@@ -0,0 +1,136 @@
+package mrz
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// specimen is the example passport of ICAO Doc 9303 part 4.
+const specimen = "P<UTOERIKSSON<<ANNA<MARIA<<<<<<<<<<<<<<<<<<<\n" +
+	"L898902C36UTO7408122F1204159ZE184226B<<<<<10"
+
+func date(s string) time.Time {
+	t, _ := time.Parse(time.DateOnly, s)
+	return t
+}
+
+func TestCheckDigit(t *testing.T) {
+	assert.Equal(t, byte('6'), CheckDigit("L898902C3"))
+	assert.Equal(t, byte('2'), CheckDigit("740812"))
+	assert.Equal(t, byte('0'), CheckDigit("<<<<<<<<<<<<<<"))
+}
+
+func TestParse(t *testing.T) {
+	p, err := Parse(specimen)
+	require.NoError(t, err)
+	assert.Equal(t, Passport{
+		DocumentCode:   "P",
+		IssuingState:   "UTO",
+		Surname:        "ERIKSSON",
+		GivenNames:     "ANNA MARIA",
+		DocumentNumber: "L898902C3",
+		Nationality:    "UTO",
+		DateOfBirth:    date("1974-08-12"),
+		Sex:            "F",
+		DateOfExpiry:   date("2012-04-15"),
+		PersonalNumber: "ZE184226B",
+	}, p)
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		mrz  string
+		err  string
+	}{
+		{"one line", "P<UTOERIKSSON<<ANNA<MARIA<<<<<<<<<<<<<<<<<<<", "must have 2 lines, got 1"},
+		{"short line", specimen[:44] + "\nL898902C36UTO", "line 2 must be 44 characters, got 13"},
+		{"lower case", "p" + specimen[1:], `line 1, column 1: 'p' is not allowed; use A-Z, 0-9 and <`},
+		{"not a passport", "I" + specimen[1:], "line 1: document code must start with P, got 'I'"},
+		{"document number", specimen[:45] + "L898902C46UTO7408122F1204159ZE184226B<<<<<10", `line 2: document number check digit is '6', expected '7'`},
+		{"composite", specimen[:len(specimen)-1] + "1", `line 2: composite check digit is '1', expected '0'`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse(tt.mrz)
+			assert.EqualError(t, err, tt.err)
+		})
+	}
+}
+
+func TestLinesRoundTrip(t *testing.T) {
+	p, err := Parse(specimen)
+	require.NoError(t, err)
+	lines, err := p.Lines()
+	require.NoError(t, err)
+	assert.Equal(t, specimen, lines[0]+"\n"+lines[1])
+}
+
+func TestLinesWithoutPersonalNumber(t *testing.T) {
+	lines, err := Passport{
+		DocumentCode:   "P",
+		IssuingState:   "GBR",
+		Surname:        "O'Brien-Doe",
+		GivenNames:     "Zoë Ægir",
+		DocumentNumber: "123456789",
+		Nationality:    "GBR",
+		DateOfBirth:    date("1985-12-31"),
+		DateOfExpiry:   date("2030-01-15"),
+	}.Lines()
+	require.NoError(t, err)
+	assert.Equal(t, "P<GBROBRIEN<DOE<<ZOE<AEGIR<<<<<<<<<<<<<<<<<<", lines[0])
+	assert.Equal(t, "1234567897GBR8512316<3001156<<<<<<<<<<<<<<<8", lines[1])
+
+	p, err := Parse(lines[0] + "\n" + lines[1])
+	require.NoError(t, err)
+	assert.Equal(t, "OBRIEN DOE", p.Surname)
+	assert.Equal(t, "", p.Sex)
+	assert.Equal(t, "", p.PersonalNumber)
+}
+
+func TestLinesErrors(t *testing.T) {
+	_, err := Passport{
+		DocumentCode:   "V",
+		IssuingState:   "GB",
+		DocumentNumber: "1234567890",
+		Nationality:    "GBR",
+		Sex:            "Q",
+	}.Lines()
+	assert.EqualError(t, err, "document code must be P, optionally followed by a capital letter\n"+
+		"issuing state must be 3 capital letters\n"+
+		"document number must be 1 to 9 capital letters or digits\n"+
+		`sex must be M, F, X or empty, got "Q"`+"\n"+
+		"date of birth and date of expiry are required")
+}
+
+func TestTransliterate(t *testing.T) {
+	assert.Equal(t, "DOE", Transliterate(" Doe "))
+	assert.Equal(t, "STRASSE<MULLER", Transliterate("Straße-Müller"))
+	assert.Equal(t, "LOPEZ<GARCIA", Transliterate("López García"))
+	assert.Equal(t, "OESTERGAARD", Transliterate("Østergaard"))
+}
+
+func TestParseDateCenturies(t *testing.T) {
+	defer func(orig func() time.Time) { now = orig }(now)
+	now = func() time.Time { return date("2026-10-19") }
+
+	dob, err := parseDate("261020", false)
+	require.NoError(t, err)
+	assert.Equal(t, date("1926-10-20"), dob)
+	dob, err = parseDate("261019", false)
+	require.NoError(t, err)
+	assert.Equal(t, date("2026-10-19"), dob)
+
+	doe, err := parseDate("750101", true)
+	require.NoError(t, err)
+	assert.Equal(t, date("2075-01-01"), doe)
+	doe, err = parseDate("800101", true)
+	require.NoError(t, err)
+	assert.Equal(t, date("1980-01-01"), doe)
+
+	_, err = parseDate("250230", true)
+	assert.EqualError(t, err, `"250230" is not a valid date`)
+}
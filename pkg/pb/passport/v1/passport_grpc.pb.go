@@ -0,0 +1,538 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             (unknown)
+// source: passport/v1/passport.proto
+
+// Package passport.v1 is the gRPC API for users and passports. It offers the
+// same operations as the REST API on top of the same storage.
+
+package passportv1
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	UserService_ListUsers_FullMethodName  = "/passport.v1.UserService/ListUsers"
+	UserService_GetUser_FullMethodName    = "/passport.v1.UserService/GetUser"
+	UserService_CreateUser_FullMethodName = "/passport.v1.UserService/CreateUser"
+	UserService_UpdateUser_FullMethodName = "/passport.v1.UserService/UpdateUser"
+	UserService_DeleteUser_FullMethodName = "/passport.v1.UserService/DeleteUser"
+)
+
+// UserServiceClient is the client API for UserService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// UserService manages users.
+type UserServiceClient interface {
+	ListUsers(ctx context.Context, in *ListUsersRequest, opts ...grpc.CallOption) (*ListUsersResponse, error)
+	GetUser(ctx context.Context, in *GetUserRequest, opts ...grpc.CallOption) (*User, error)
+	CreateUser(ctx context.Context, in *CreateUserRequest, opts ...grpc.CallOption) (*User, error)
+	UpdateUser(ctx context.Context, in *UpdateUserRequest, opts ...grpc.CallOption) (*User, error)
+	DeleteUser(ctx context.Context, in *DeleteUserRequest, opts ...grpc.CallOption) (*DeleteUserResponse, error)
+}
+
+type userServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewUserServiceClient(cc grpc.ClientConnInterface) UserServiceClient {
+	return &userServiceClient{cc}
+}
+
+func (c *userServiceClient) ListUsers(ctx context.Context, in *ListUsersRequest, opts ...grpc.CallOption) (*ListUsersResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListUsersResponse)
+	err := c.cc.Invoke(ctx, UserService_ListUsers_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *userServiceClient) GetUser(ctx context.Context, in *GetUserRequest, opts ...grpc.CallOption) (*User, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(User)
+	err := c.cc.Invoke(ctx, UserService_GetUser_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *userServiceClient) CreateUser(ctx context.Context, in *CreateUserRequest, opts ...grpc.CallOption) (*User, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(User)
+	err := c.cc.Invoke(ctx, UserService_CreateUser_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *userServiceClient) UpdateUser(ctx context.Context, in *UpdateUserRequest, opts ...grpc.CallOption) (*User, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(User)
+	err := c.cc.Invoke(ctx, UserService_UpdateUser_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *userServiceClient) DeleteUser(ctx context.Context, in *DeleteUserRequest, opts ...grpc.CallOption) (*DeleteUserResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(DeleteUserResponse)
+	err := c.cc.Invoke(ctx, UserService_DeleteUser_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// UserServiceServer is the server API for UserService service.
+// All implementations must embed UnimplementedUserServiceServer
+// for forward compatibility.
+//
+// UserService manages users.
+type UserServiceServer interface {
+	ListUsers(context.Context, *ListUsersRequest) (*ListUsersResponse, error)
+	GetUser(context.Context, *GetUserRequest) (*User, error)
+	CreateUser(context.Context, *CreateUserRequest) (*User, error)
+	UpdateUser(context.Context, *UpdateUserRequest) (*User, error)
+	DeleteUser(context.Context, *DeleteUserRequest) (*DeleteUserResponse, error)
+	mustEmbedUnimplementedUserServiceServer()
+}
+
+// UnimplementedUserServiceServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedUserServiceServer struct{}
+
+func (UnimplementedUserServiceServer) ListUsers(context.Context, *ListUsersRequest) (*ListUsersResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListUsers not implemented")
+}
+func (UnimplementedUserServiceServer) GetUser(context.Context, *GetUserRequest) (*User, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetUser not implemented")
+}
+func (UnimplementedUserServiceServer) CreateUser(context.Context, *CreateUserRequest) (*User, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CreateUser not implemented")
+}
+func (UnimplementedUserServiceServer) UpdateUser(context.Context, *UpdateUserRequest) (*User, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UpdateUser not implemented")
+}
+func (UnimplementedUserServiceServer) DeleteUser(context.Context, *DeleteUserRequest) (*DeleteUserResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteUser not implemented")
+}
+func (UnimplementedUserServiceServer) mustEmbedUnimplementedUserServiceServer() {}
+func (UnimplementedUserServiceServer) testEmbeddedByValue()                     {}
+
+// UnsafeUserServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to UserServiceServer will
+// result in compilation errors.
+type UnsafeUserServiceServer interface {
+	mustEmbedUnimplementedUserServiceServer()
+}
+
+func RegisterUserServiceServer(s grpc.ServiceRegistrar, srv UserServiceServer) {
+	// If the following call pancis, it indicates UnimplementedUserServiceServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&UserService_ServiceDesc, srv)
+}
+
+func _UserService_ListUsers_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListUsersRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(UserServiceServer).ListUsers(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: UserService_ListUsers_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(UserServiceServer).ListUsers(ctx, req.(*ListUsersRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _UserService_GetUser_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetUserRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(UserServiceServer).GetUser(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: UserService_GetUser_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(UserServiceServer).GetUser(ctx, req.(*GetUserRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _UserService_CreateUser_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CreateUserRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(UserServiceServer).CreateUser(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: UserService_CreateUser_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(UserServiceServer).CreateUser(ctx, req.(*CreateUserRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _UserService_UpdateUser_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UpdateUserRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(UserServiceServer).UpdateUser(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: UserService_UpdateUser_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(UserServiceServer).UpdateUser(ctx, req.(*UpdateUserRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _UserService_DeleteUser_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteUserRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(UserServiceServer).DeleteUser(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: UserService_DeleteUser_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(UserServiceServer).DeleteUser(ctx, req.(*DeleteUserRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// UserService_ServiceDesc is the grpc.ServiceDesc for UserService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var UserService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "passport.v1.UserService",
+	HandlerType: (*UserServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "ListUsers",
+			Handler:    _UserService_ListUsers_Handler,
+		},
+		{
+			MethodName: "GetUser",
+			Handler:    _UserService_GetUser_Handler,
+		},
+		{
+			MethodName: "CreateUser",
+			Handler:    _UserService_CreateUser_Handler,
+		},
+		{
+			MethodName: "UpdateUser",
+			Handler:    _UserService_UpdateUser_Handler,
+		},
+		{
+			MethodName: "DeleteUser",
+			Handler:    _UserService_DeleteUser_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "passport/v1/passport.proto",
+}
+
+const (
+	PassportService_ListPassports_FullMethodName  = "/passport.v1.PassportService/ListPassports"
+	PassportService_GetPassport_FullMethodName    = "/passport.v1.PassportService/GetPassport"
+	PassportService_CreatePassport_FullMethodName = "/passport.v1.PassportService/CreatePassport"
+	PassportService_UpdatePassport_FullMethodName = "/passport.v1.PassportService/UpdatePassport"
+	PassportService_DeletePassport_FullMethodName = "/passport.v1.PassportService/DeletePassport"
+)
+
+// PassportServiceClient is the client API for PassportService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// PassportService manages passports.
+type PassportServiceClient interface {
+	ListPassports(ctx context.Context, in *ListPassportsRequest, opts ...grpc.CallOption) (*ListPassportsResponse, error)
+	GetPassport(ctx context.Context, in *GetPassportRequest, opts ...grpc.CallOption) (*Passport, error)
+	CreatePassport(ctx context.Context, in *CreatePassportRequest, opts ...grpc.CallOption) (*Passport, error)
+	UpdatePassport(ctx context.Context, in *UpdatePassportRequest, opts ...grpc.CallOption) (*Passport, error)
+	DeletePassport(ctx context.Context, in *DeletePassportRequest, opts ...grpc.CallOption) (*DeletePassportResponse, error)
+}
+
+type passportServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewPassportServiceClient(cc grpc.ClientConnInterface) PassportServiceClient {
+	return &passportServiceClient{cc}
+}
+
+func (c *passportServiceClient) ListPassports(ctx context.Context, in *ListPassportsRequest, opts ...grpc.CallOption) (*ListPassportsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListPassportsResponse)
+	err := c.cc.Invoke(ctx, PassportService_ListPassports_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *passportServiceClient) GetPassport(ctx context.Context, in *GetPassportRequest, opts ...grpc.CallOption) (*Passport, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Passport)
+	err := c.cc.Invoke(ctx, PassportService_GetPassport_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *passportServiceClient) CreatePassport(ctx context.Context, in *CreatePassportRequest, opts ...grpc.CallOption) (*Passport, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Passport)
+	err := c.cc.Invoke(ctx, PassportService_CreatePassport_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *passportServiceClient) UpdatePassport(ctx context.Context, in *UpdatePassportRequest, opts ...grpc.CallOption) (*Passport, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Passport)
+	err := c.cc.Invoke(ctx, PassportService_UpdatePassport_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *passportServiceClient) DeletePassport(ctx context.Context, in *DeletePassportRequest, opts ...grpc.CallOption) (*DeletePassportResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(DeletePassportResponse)
+	err := c.cc.Invoke(ctx, PassportService_DeletePassport_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// PassportServiceServer is the server API for PassportService service.
+// All implementations must embed UnimplementedPassportServiceServer
+// for forward compatibility.
+//
+// PassportService manages passports.
+type PassportServiceServer interface {
+	ListPassports(context.Context, *ListPassportsRequest) (*ListPassportsResponse, error)
+	GetPassport(context.Context, *GetPassportRequest) (*Passport, error)
+	CreatePassport(context.Context, *CreatePassportRequest) (*Passport, error)
+	UpdatePassport(context.Context, *UpdatePassportRequest) (*Passport, error)
+	DeletePassport(context.Context, *DeletePassportRequest) (*DeletePassportResponse, error)
+	mustEmbedUnimplementedPassportServiceServer()
+}
+
+// UnimplementedPassportServiceServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedPassportServiceServer struct{}
+
+func (UnimplementedPassportServiceServer) ListPassports(context.Context, *ListPassportsRequest) (*ListPassportsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListPassports not implemented")
+}
+func (UnimplementedPassportServiceServer) GetPassport(context.Context, *GetPassportRequest) (*Passport, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetPassport not implemented")
+}
+func (UnimplementedPassportServiceServer) CreatePassport(context.Context, *CreatePassportRequest) (*Passport, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CreatePassport not implemented")
+}
+func (UnimplementedPassportServiceServer) UpdatePassport(context.Context, *UpdatePassportRequest) (*Passport, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UpdatePassport not implemented")
+}
+func (UnimplementedPassportServiceServer) DeletePassport(context.Context, *DeletePassportRequest) (*DeletePassportResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeletePassport not implemented")
+}
+func (UnimplementedPassportServiceServer) mustEmbedUnimplementedPassportServiceServer() {}
+func (UnimplementedPassportServiceServer) testEmbeddedByValue()                         {}
+
+// UnsafePassportServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to PassportServiceServer will
+// result in compilation errors.
+type UnsafePassportServiceServer interface {
+	mustEmbedUnimplementedPassportServiceServer()
+}
+
+func RegisterPassportServiceServer(s grpc.ServiceRegistrar, srv PassportServiceServer) {
+	// If the following call pancis, it indicates UnimplementedPassportServiceServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&PassportService_ServiceDesc, srv)
+}
+
+func _PassportService_ListPassports_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListPassportsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PassportServiceServer).ListPassports(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: PassportService_ListPassports_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PassportServiceServer).ListPassports(ctx, req.(*ListPassportsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PassportService_GetPassport_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetPassportRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PassportServiceServer).GetPassport(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: PassportService_GetPassport_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PassportServiceServer).GetPassport(ctx, req.(*GetPassportRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PassportService_CreatePassport_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CreatePassportRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PassportServiceServer).CreatePassport(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: PassportService_CreatePassport_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PassportServiceServer).CreatePassport(ctx, req.(*CreatePassportRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PassportService_UpdatePassport_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UpdatePassportRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PassportServiceServer).UpdatePassport(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: PassportService_UpdatePassport_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PassportServiceServer).UpdatePassport(ctx, req.(*UpdatePassportRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PassportService_DeletePassport_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeletePassportRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PassportServiceServer).DeletePassport(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: PassportService_DeletePassport_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PassportServiceServer).DeletePassport(ctx, req.(*DeletePassportRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// PassportService_ServiceDesc is the grpc.ServiceDesc for PassportService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var PassportService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "passport.v1.PassportService",
+	HandlerType: (*PassportServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "ListPassports",
+			Handler:    _PassportService_ListPassports_Handler,
+		},
+		{
+			MethodName: "GetPassport",
+			Handler:    _PassportService_GetPassport_Handler,
+		},
+		{
+			MethodName: "CreatePassport",
+			Handler:    _PassportService_CreatePassport_Handler,
+		},
+		{
+			MethodName: "UpdatePassport",
+			Handler:    _PassportService_UpdatePassport_Handler,
+		},
+		{
+			MethodName: "DeletePassport",
+			Handler:    _PassportService_DeletePassport_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "passport/v1/passport.proto",
+}
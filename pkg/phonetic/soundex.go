@@ -0,0 +1,46 @@
+// Package phonetic encodes names by how they sound, so that spellings of
+// the same name, such as Jon and John, can be matched.
+package phonetic
+
+// soundexCodes are the Soundex digits of the letters A to Z. Vowels and Y
+// are '0', which separates letters with the same code; H and W are '-',
+// which doesn't.
+const soundexCodes = "0123012-02245501262301-202"
+
+// Soundex returns the American Soundex code of a word: its first letter
+// followed by three digits, such as R163 for Robert and Rupert. Characters
+// other than the letters A to Z, in either case, are ignored; a word
+// without letters has an empty code.
+func Soundex(word string) string {
+	out := make([]byte, 0, 4)
+	var last byte
+	for i := 0; i < len(word) && len(out) < 4; i++ {
+		c := word[i]
+		if c >= 'a' && c <= 'z' {
+			c -= 'a' - 'A'
+		}
+		if c < 'A' || c > 'Z' {
+			continue
+		}
+		code := soundexCodes[c-'A']
+		if len(out) == 0 {
+			out = append(out, c)
+			last = code
+			continue
+		}
+		switch {
+		case code == '-':
+			continue
+		case code != '0' && code != last:
+			out = append(out, code)
+		}
+		last = code
+	}
+	if len(out) == 0 {
+		return ""
+	}
+	for len(out) < 4 {
+		out = append(out, '0')
+	}
+	return string(out)
+}
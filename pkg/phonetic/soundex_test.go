@@ -0,0 +1,29 @@
+package phonetic
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSoundex(t *testing.T) {
+	tests := map[string]string{
+		"Robert":   "R163",
+		"Rupert":   "R163",
+		"Rubin":    "R150",
+		"Ashcraft": "A261",
+		"Ashcroft": "A261",
+		"Tymczak":  "T522",
+		"Pfister":  "P236",
+		"Honeyman": "H555",
+		"Lee":      "L000",
+		"John":     "J500",
+		"Jon":      "J500",
+		"O'Brien":  "O165",
+		"":         "",
+		"123":      "",
+	}
+	for word, want := range tests {
+		assert.Equal(t, want, Soundex(word), word)
+	}
+}
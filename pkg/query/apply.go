@@ -0,0 +1,145 @@
+package query
+
+import (
+	"cmp"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/leeprovoost/go-rest-api-template/pkg/civil"
+)
+
+// Apply returns the elements of list that satisfy every filter in q, ordered
+// by q.Sort. Elements that compare equal keep their relative order, so callers
+// should pass list in their default order.
+func Apply[T any](q Query, list []T) []T {
+	out := make([]T, 0, len(list))
+	for _, v := range list {
+		if q.Match(v) {
+			out = append(out, v)
+		}
+	}
+	if len(q.Sort) > 0 {
+		sort.SliceStable(out, func(i, j int) bool {
+			return q.compare(out[i], out[j]) < 0
+		})
+	}
+	return out
+}
+
+// Match reports whether v, a struct or pointer to struct, satisfies every
+// filter in q.
+func (q Query) Match(v any) bool {
+	rv := reflect.Indirect(reflect.ValueOf(v))
+	for _, f := range q.Filters {
+		fv, ok := fieldByName(rv, f.Field)
+		if !ok || !matches(fv, f) {
+			return false
+		}
+	}
+	return true
+}
+
+func matches(fv reflect.Value, f Filter) bool {
+	if f.Op == OpContains {
+		want, _ := f.Value.(string)
+		return strings.Contains(strings.ToLower(fv.String()), strings.ToLower(want))
+	}
+	c := compareValue(fv, f.Value)
+	switch f.Op {
+	case OpEq:
+		return c == 0
+	case OpNe:
+		return c != 0
+	case OpGt:
+		return c > 0
+	case OpGte:
+		return c >= 0
+	case OpLt:
+		return c < 0
+	case OpLte:
+		return c <= 0
+	}
+	return false
+}
+
+// compareValue compares a struct field against a parsed filter value.
+func compareValue(fv reflect.Value, want any) int {
+	switch w := want.(type) {
+	case string:
+		return strings.Compare(fv.String(), w)
+	case int:
+		return cmp.Compare(fv.Int(), int64(w))
+	case time.Time:
+		return timeOf(fv).Compare(w)
+	}
+	return 0
+}
+
+// compare orders a and b by q.Sort.
+func (q Query) compare(a, b any) int {
+	ra := reflect.Indirect(reflect.ValueOf(a))
+	rb := reflect.Indirect(reflect.ValueOf(b))
+	for _, key := range q.Sort {
+		fa, _ := fieldByName(ra, key.Field)
+		fb, _ := fieldByName(rb, key.Field)
+		c := compareFields(fa, fb)
+		if key.Desc {
+			c = -c
+		}
+		if c != 0 {
+			return c
+		}
+	}
+	return 0
+}
+
+func compareFields(a, b reflect.Value) int {
+	if !a.IsValid() || !b.IsValid() {
+		return 0
+	}
+	switch kindOf(a.Type()) {
+	case KindString:
+		return strings.Compare(a.String(), b.String())
+	case KindInt:
+		return cmp.Compare(a.Int(), b.Int())
+	case KindDate:
+		return timeOf(a).Compare(timeOf(b))
+	}
+	return 0
+}
+
+// timeOf returns the value of a KindDate field as a time.Time.
+func timeOf(v reflect.Value) time.Time {
+	if d, ok := v.Interface().(civil.Date); ok {
+		return d.In(time.UTC)
+	}
+	return v.Interface().(time.Time)
+}
+
+// fieldIndexes caches the JSON-name-to-field-index mapping per struct type.
+var fieldIndexes sync.Map // map[reflect.Type]map[string]int
+
+func fieldByName(v reflect.Value, name string) (reflect.Value, bool) {
+	if v.Kind() != reflect.Struct {
+		return reflect.Value{}, false
+	}
+	t := v.Type()
+	idx, ok := fieldIndexes.Load(t)
+	if !ok {
+		m := make(map[string]int, t.NumField())
+		for i := 0; i < t.NumField(); i++ {
+			if n, ok := jsonName(t.Field(i)); ok {
+				m[n] = i
+			}
+		}
+		idx, _ = fieldIndexes.LoadOrStore(t, m)
+	}
+	i, ok := idx.(map[string]int)[name]
+	if !ok {
+		return reflect.Value{}, false
+	}
+	return v.Field(i), true
+}
@@ -0,0 +1,247 @@
+// Package query implements a small filtering and sorting language for list
+// endpoints, for example:
+//
+//	?lastName=Doe&dateOfBirth[gte]=1980-01-01&locationOfBirth[contains]=Lon&sort=-id
+//
+// Parse turns URL query parameters into a Query, validating field names,
+// operators and values against a Schema derived from a model struct. Storage
+// implementations execute the Query; Apply does so for in-memory slices.
+package query
+
+import (
+	"fmt"
+	"net/url"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/leeprovoost/go-rest-api-template/pkg/civil"
+)
+
+// Kind is the type of a filterable field.
+type Kind int
+
+// Field kinds. KindOther fields can be projected but not filtered or sorted.
+const (
+	KindOther Kind = iota
+	KindString
+	KindInt
+	KindDate
+)
+
+// Op is a comparison operator.
+type Op string
+
+// Supported operators.
+const (
+	OpEq       Op = "eq"
+	OpNe       Op = "ne"
+	OpGt       Op = "gt"
+	OpGte      Op = "gte"
+	OpLt       Op = "lt"
+	OpLte      Op = "lte"
+	OpContains Op = "contains"
+)
+
+// Filter is a single condition in a Query. Value holds a string, int or
+// time.Time depending on the field's Kind. KindDate fields may be time.Time
+// or civil.Date; a filter date is the start of its day in UTC.
+type Filter struct {
+	Field string
+	Op    Op
+	Value any
+}
+
+// SortKey orders results by a field.
+type SortKey struct {
+	Field string
+	Desc  bool
+}
+
+// Query is the parsed filter AST. Filters are combined with AND. The zero
+// Query matches everything and leaves the storage's default order intact.
+type Query struct {
+	Filters []Filter
+	Sort    []SortKey
+}
+
+// Schema maps JSON field names to their kind.
+type Schema map[string]Kind
+
+// SchemaOf derives a Schema from the JSON tags of model's struct type.
+func SchemaOf(model any) Schema {
+	t := reflect.TypeOf(model)
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	schema := make(Schema, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name, ok := jsonName(f)
+		if !ok {
+			continue
+		}
+		schema[name] = kindOf(f.Type)
+	}
+	return schema
+}
+
+var (
+	timeType = reflect.TypeOf(time.Time{})
+	dateType = reflect.TypeOf(civil.Date{})
+)
+
+func kindOf(t reflect.Type) Kind {
+	switch {
+	case t == timeType || t == dateType:
+		return KindDate
+	case t.Kind() == reflect.String:
+		return KindString
+	case t.Kind() >= reflect.Int && t.Kind() <= reflect.Int64:
+		return KindInt
+	default:
+		return KindOther
+	}
+}
+
+func jsonName(f reflect.StructField) (string, bool) {
+	if !f.IsExported() {
+		return "", false
+	}
+	name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+	if name == "-" {
+		return "", false
+	}
+	if name == "" {
+		name = f.Name
+	}
+	return name, true
+}
+
+// Parse builds a Query from URL query parameters. Parameters named in
+// reserved (e.g. offset, limit) are skipped. Filters use the form field=value
+// or field[op]=value; sort takes a comma-separated list of fields, each
+// optionally prefixed with "-" for descending order.
+func Parse(values url.Values, schema Schema, reserved ...string) (Query, error) {
+	skip := make(map[string]bool, len(reserved))
+	for _, k := range reserved {
+		skip[k] = true
+	}
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var q Query
+	for _, key := range keys {
+		if skip[key] {
+			continue
+		}
+		if key == "sort" {
+			for _, raw := range values[key] {
+				keys, err := parseSort(raw, schema)
+				if err != nil {
+					return Query{}, err
+				}
+				q.Sort = append(q.Sort, keys...)
+			}
+			continue
+		}
+		field, op, err := parseKey(key)
+		if err != nil {
+			return Query{}, err
+		}
+		for _, raw := range values[key] {
+			f, err := newFilter(schema, field, op, raw)
+			if err != nil {
+				return Query{}, err
+			}
+			q.Filters = append(q.Filters, f)
+		}
+	}
+	return q, nil
+}
+
+func parseKey(key string) (string, Op, error) {
+	field, rest, found := strings.Cut(key, "[")
+	if !found {
+		return key, OpEq, nil
+	}
+	op, ok := strings.CutSuffix(rest, "]")
+	if !ok || op == "" {
+		return "", "", fmt.Errorf("malformed filter %q", key)
+	}
+	return field, Op(op), nil
+}
+
+func newFilter(schema Schema, field string, op Op, raw string) (Filter, error) {
+	kind, ok := schema[field]
+	if !ok {
+		return Filter{}, fmt.Errorf("unknown field %q", field)
+	}
+	if kind == KindOther {
+		return Filter{}, fmt.Errorf("field %q cannot be filtered", field)
+	}
+	switch op {
+	case OpEq, OpNe, OpGt, OpGte, OpLt, OpLte:
+	case OpContains:
+		if kind != KindString {
+			return Filter{}, fmt.Errorf("operator %q is not supported for field %q", op, field)
+		}
+	default:
+		return Filter{}, fmt.Errorf("unknown operator %q for field %q", op, field)
+	}
+	value, err := parseValue(kind, raw)
+	if err != nil {
+		return Filter{}, fmt.Errorf("invalid value %q for field %q: %w", raw, field, err)
+	}
+	return Filter{Field: field, Op: op, Value: value}, nil
+}
+
+func parseValue(kind Kind, raw string) (any, error) {
+	switch kind {
+	case KindInt:
+		n, err := strconv.Atoi(raw)
+		if err != nil {
+			return nil, fmt.Errorf("expected an integer")
+		}
+		return n, nil
+	case KindDate:
+		if t, err := time.Parse(time.DateOnly, raw); err == nil {
+			return t, nil
+		}
+		t, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			return nil, fmt.Errorf("expected a date (YYYY-MM-DD) or RFC 3339 timestamp")
+		}
+		return t, nil
+	default:
+		return raw, nil
+	}
+}
+
+func parseSort(raw string, schema Schema) ([]SortKey, error) {
+	var keys []SortKey
+	for _, part := range strings.Split(raw, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		key := SortKey{Field: part}
+		if name, ok := strings.CutPrefix(part, "-"); ok {
+			key = SortKey{Field: name, Desc: true}
+		}
+		kind, ok := schema[key.Field]
+		if !ok {
+			return nil, fmt.Errorf("unknown sort field %q", key.Field)
+		}
+		if kind == KindOther {
+			return nil, fmt.Errorf("field %q cannot be sorted", key.Field)
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
@@ -0,0 +1,131 @@
+package query
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/leeprovoost/go-rest-api-template/pkg/civil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type person struct {
+	ID   int       `json:"id"`
+	Name string    `json:"name"`
+	Born time.Time `json:"born"`
+	Tags []string  `json:"tags"`
+}
+
+func date(s string) time.Time {
+	t, _ := time.Parse(time.DateOnly, s)
+	return t
+}
+
+var people = []person{
+	{ID: 1, Name: "Alice", Born: date("1980-05-01")},
+	{ID: 2, Name: "Bob", Born: date("1975-01-01")},
+	{ID: 3, Name: "Alicia", Born: date("1990-12-31")},
+}
+
+func parse(t *testing.T, raw string) Query {
+	t.Helper()
+	values, err := url.ParseQuery(raw)
+	require.NoError(t, err)
+	q, err := Parse(values, SchemaOf(person{}), "offset", "limit")
+	require.NoError(t, err)
+	return q
+}
+
+func ids(list []person) []int {
+	out := make([]int, len(list))
+	for i, p := range list {
+		out[i] = p.ID
+	}
+	return out
+}
+
+func TestSchemaOf(t *testing.T) {
+	assert.Equal(t, Schema{
+		"id":   KindInt,
+		"name": KindString,
+		"born": KindDate,
+		"tags": KindOther,
+	}, SchemaOf(person{}))
+}
+
+func TestParseFilters(t *testing.T) {
+	q := parse(t, "name=Bob&born[gte]=1980-01-01&id[ne]=3&offset=5&limit=10")
+	assert.Equal(t, []Filter{
+		{Field: "born", Op: OpGte, Value: date("1980-01-01")},
+		{Field: "id", Op: OpNe, Value: 3},
+		{Field: "name", Op: OpEq, Value: "Bob"},
+	}, q.Filters)
+	assert.Empty(t, q.Sort)
+}
+
+func TestParseSort(t *testing.T) {
+	q := parse(t, "sort=-born,id")
+	assert.Equal(t, []SortKey{{Field: "born", Desc: true}, {Field: "id"}}, q.Sort)
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := map[string]string{
+		"unknown=1":          `unknown field "unknown"`,
+		"id[like]=1":         `unknown operator "like" for field "id"`,
+		"id[contains]=1":     `operator "contains" is not supported for field "id"`,
+		"id=abc":             `invalid value "abc" for field "id": expected an integer`,
+		"born[lt]=yesterday": `invalid value "yesterday" for field "born": expected a date (YYYY-MM-DD) or RFC 3339 timestamp`,
+		"name[eq=1":          `malformed filter "name[eq"`,
+		"tags=x":             `field "tags" cannot be filtered`,
+		"sort=-nope":         `unknown sort field "nope"`,
+	}
+	for raw, want := range tests {
+		t.Run(raw, func(t *testing.T) {
+			values, err := url.ParseQuery(raw)
+			require.NoError(t, err)
+			_, err = Parse(values, SchemaOf(person{}))
+			assert.EqualError(t, err, want)
+		})
+	}
+}
+
+func TestApplyZeroQueryKeepsOrder(t *testing.T) {
+	assert.Equal(t, []int{1, 2, 3}, ids(Apply(Query{}, people)))
+}
+
+func TestApplyFilters(t *testing.T) {
+	assert.Equal(t, []int{1, 3}, ids(Apply(parse(t, "name[contains]=ali"), people)))
+	assert.Equal(t, []int{2}, ids(Apply(parse(t, "born[lt]=1980-01-01"), people)))
+	assert.Equal(t, []int{1}, ids(Apply(parse(t, "born[gte]=1980-01-01&born[lte]=1985-01-01"), people)))
+	assert.Equal(t, []int{3}, ids(Apply(parse(t, "id[gt]=1&id[ne]=2"), people)))
+	assert.Empty(t, Apply(parse(t, "name=alice"), people))
+}
+
+func TestApplySort(t *testing.T) {
+	assert.Equal(t, []int{3, 1, 2}, ids(Apply(parse(t, "sort=-born"), people)))
+	assert.Equal(t, []int{1, 3, 2}, ids(Apply(parse(t, "sort=name"), people)))
+}
+
+func TestApplyCivilDates(t *testing.T) {
+	type event struct {
+		ID int        `json:"id"`
+		On civil.Date `json:"on"`
+	}
+	events := []event{
+		{ID: 1, On: civil.Date{Year: 2024, Month: time.March, Day: 2}},
+		{ID: 2, On: civil.Date{Year: 2024, Month: time.March, Day: 1}},
+	}
+	values, err := url.ParseQuery("on[gte]=2024-03-01&on[lt]=2024-03-02T00:00:00Z&sort=on")
+	require.NoError(t, err)
+	schema := SchemaOf(event{})
+	assert.Equal(t, KindDate, schema["on"])
+	q, err := Parse(values, schema)
+	require.NoError(t, err)
+	assert.Equal(t, []event{events[1]}, Apply(q, events))
+	assert.Equal(t, []event{events[1], events[0]}, Apply(Query{Sort: q.Sort}, events))
+}
+
+func TestMatchPointer(t *testing.T) {
+	assert.True(t, parse(t, "id=2").Match(&people[1]))
+}
@@ -0,0 +1,293 @@
+// Package search provides a small embedded full-text index with prefix
+// matching, relevance ranking and highlighting. It is intended for modest
+// in-process data sets; it is safe for concurrent use.
+package search
+
+import (
+	"html"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// Field is a named piece of text in a Document. Matches in fields with a
+// higher Boost rank higher; a zero Boost counts as 1.
+type Field struct {
+	Name  string
+	Text  string
+	Boost float64
+}
+
+// Document is the unit of indexing and retrieval.
+type Document struct {
+	ID     string
+	Fields []Field
+}
+
+// Hit is a single search result.
+type Hit struct {
+	ID         string            `json:"id"`
+	Score      float64           `json:"score"`
+	Highlights map[string]string `json:"highlights"`
+}
+
+// Result is a page of hits together with the total number of matches.
+type Result struct {
+	Hits  []Hit
+	Total int
+}
+
+// Highlight markers wrapped around matched words in Hit.Highlights. The rest
+// of the highlighted text is HTML-escaped.
+const (
+	HighlightPre  = "<mark>"
+	HighlightPost = "</mark>"
+)
+
+// prefixWeight discounts matches where the query token is only a prefix of
+// the indexed term, so exact matches rank first.
+const prefixWeight = 0.5
+
+// Index is an in-memory inverted index.
+type Index struct {
+	mu       sync.RWMutex
+	docs     map[string]Document
+	postings map[string]map[string]int // term -> doc ID -> term frequency
+	terms    []string                  // sorted vocabulary, for prefix lookups
+}
+
+// New returns an empty Index.
+func New() *Index {
+	return &Index{
+		docs:     make(map[string]Document),
+		postings: make(map[string]map[string]int),
+	}
+}
+
+// Index adds doc to the index, replacing any document with the same ID.
+func (ix *Index) Index(doc Document) {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	ix.remove(doc.ID)
+	ix.docs[doc.ID] = doc
+	for _, f := range doc.Fields {
+		for _, term := range Tokenize(f.Text) {
+			docs, ok := ix.postings[term]
+			if !ok {
+				docs = make(map[string]int)
+				ix.postings[term] = docs
+				ix.addTerm(term)
+			}
+			docs[doc.ID]++
+		}
+	}
+}
+
+// Delete removes the document with the given ID, if present.
+func (ix *Index) Delete(id string) {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	ix.remove(id)
+}
+
+// Len returns the number of indexed documents.
+func (ix *Index) Len() int {
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+	return len(ix.docs)
+}
+
+func (ix *Index) remove(id string) {
+	doc, ok := ix.docs[id]
+	if !ok {
+		return
+	}
+	delete(ix.docs, id)
+	for _, f := range doc.Fields {
+		for _, term := range Tokenize(f.Text) {
+			docs := ix.postings[term]
+			delete(docs, id)
+			if len(docs) == 0 {
+				delete(ix.postings, term)
+				ix.removeTerm(term)
+			}
+		}
+	}
+}
+
+func (ix *Index) addTerm(term string) {
+	i := sort.SearchStrings(ix.terms, term)
+	ix.terms = append(ix.terms, "")
+	copy(ix.terms[i+1:], ix.terms[i:])
+	ix.terms[i] = term
+}
+
+func (ix *Index) removeTerm(term string) {
+	i := sort.SearchStrings(ix.terms, term)
+	if i < len(ix.terms) && ix.terms[i] == term {
+		ix.terms = append(ix.terms[:i], ix.terms[i+1:]...)
+	}
+}
+
+// termsWithPrefix returns all indexed terms starting with prefix.
+func (ix *Index) termsWithPrefix(prefix string) []string {
+	i := sort.SearchStrings(ix.terms, prefix)
+	j := i
+	for j < len(ix.terms) && strings.HasPrefix(ix.terms[j], prefix) {
+		j++
+	}
+	return ix.terms[i:j]
+}
+
+// Search returns the documents that match every token in q, either exactly
+// or by prefix, ranked by relevance. offset and limit select a page of hits.
+func (ix *Index) Search(q string, offset, limit int) Result {
+	tokens := Tokenize(q)
+	if len(tokens) == 0 {
+		return Result{Hits: []Hit{}}
+	}
+
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+
+	n := float64(len(ix.docs))
+	scores := make(map[string]float64)
+	for i, tok := range tokens {
+		tokenScores := make(map[string]float64)
+		for _, term := range ix.termsWithPrefix(tok) {
+			docs := ix.postings[term]
+			idf := math.Log(1 + n/float64(len(docs)))
+			weight := 1.0
+			if term != tok {
+				weight = prefixWeight
+			}
+			for id, tf := range docs {
+				score := float64(tf) * idf * weight * ix.boost(id, term)
+				tokenScores[id] = max(tokenScores[id], score)
+			}
+		}
+		// Every token must match: intersect with the previous tokens.
+		if i == 0 {
+			scores = tokenScores
+			continue
+		}
+		for id := range scores {
+			if s, ok := tokenScores[id]; ok {
+				scores[id] += s
+			} else {
+				delete(scores, id)
+			}
+		}
+	}
+
+	hits := make([]Hit, 0, len(scores))
+	for id, score := range scores {
+		hits = append(hits, Hit{ID: id, Score: score})
+	}
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].Score != hits[j].Score {
+			return hits[i].Score > hits[j].Score
+		}
+		return hits[i].ID < hits[j].ID
+	})
+
+	total := len(hits)
+	if offset > total {
+		offset = total
+	}
+	end := min(offset+limit, total)
+	page := hits[offset:end]
+	for i := range page {
+		page[i].Highlights = highlight(ix.docs[page[i].ID], tokens)
+	}
+	return Result{Hits: page, Total: total}
+}
+
+// boost returns the highest boost among the fields of doc id containing term.
+func (ix *Index) boost(id, term string) float64 {
+	best := 0.0
+	for _, f := range ix.docs[id].Fields {
+		for _, t := range Tokenize(f.Text) {
+			if t == term {
+				b := f.Boost
+				if b == 0 {
+					b = 1
+				}
+				best = max(best, b)
+				break
+			}
+		}
+	}
+	return best
+}
+
+// highlight returns, for each field containing a match, the field text with
+// matching words wrapped in highlight markers. The text is HTML-escaped, so
+// the markers are the only markup in the result.
+func highlight(doc Document, tokens []string) map[string]string {
+	out := make(map[string]string)
+	for _, f := range doc.Fields {
+		var b strings.Builder
+		matched := false
+		last := 0
+		for _, span := range wordSpans(f.Text) {
+			word := strings.ToLower(f.Text[span[0]:span[1]])
+			if !matchesAny(word, tokens) {
+				continue
+			}
+			matched = true
+			b.WriteString(html.EscapeString(f.Text[last:span[0]]))
+			b.WriteString(HighlightPre)
+			b.WriteString(html.EscapeString(f.Text[span[0]:span[1]]))
+			b.WriteString(HighlightPost)
+			last = span[1]
+		}
+		if matched {
+			b.WriteString(html.EscapeString(f.Text[last:]))
+			out[f.Name] = b.String()
+		}
+	}
+	return out
+}
+
+func matchesAny(word string, tokens []string) bool {
+	for _, tok := range tokens {
+		if strings.HasPrefix(word, tok) {
+			return true
+		}
+	}
+	return false
+}
+
+// Tokenize splits text into lower-case terms on anything that is not a
+// letter or digit.
+func Tokenize(text string) []string {
+	spans := wordSpans(text)
+	terms := make([]string, len(spans))
+	for i, span := range spans {
+		terms[i] = strings.ToLower(text[span[0]:span[1]])
+	}
+	return terms
+}
+
+// wordSpans returns the byte offsets of each word in text.
+func wordSpans(text string) [][2]int {
+	var spans [][2]int
+	start := -1
+	for i, r := range text {
+		isWord := unicode.IsLetter(r) || unicode.IsDigit(r)
+		switch {
+		case isWord && start < 0:
+			start = i
+		case !isWord && start >= 0:
+			spans = append(spans, [2]int{start, i})
+			start = -1
+		}
+	}
+	if start >= 0 {
+		spans = append(spans, [2]int{start, len(text)})
+	}
+	return spans
+}
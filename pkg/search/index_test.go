@@ -0,0 +1,100 @@
+package search
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestIndex() *Index {
+	ix := New()
+	ix.Index(Document{ID: "1", Fields: []Field{
+		{Name: "name", Text: "John Doe", Boost: 2},
+		{Name: "city", Text: "London"},
+	}})
+	ix.Index(Document{ID: "2", Fields: []Field{
+		{Name: "name", Text: "Jane Doe", Boost: 2},
+		{Name: "city", Text: "Milton Keynes"},
+	}})
+	ix.Index(Document{ID: "3", Fields: []Field{
+		{Name: "name", Text: "Londo Mollari", Boost: 2},
+		{Name: "city", Text: "Centauri Prime"},
+	}})
+	return ix
+}
+
+func ids(res Result) []string {
+	var out []string
+	for _, h := range res.Hits {
+		out = append(out, h.ID)
+	}
+	return out
+}
+
+func TestTokenize(t *testing.T) {
+	assert.Equal(t, []string{"o", "brien", "milton", "keynes"}, Tokenize("O'Brien, Milton-Keynes"))
+	assert.Equal(t, []string{"zoë", "42"}, Tokenize("  Zoë 42! "))
+}
+
+func TestSearchPrefix(t *testing.T) {
+	ix := newTestIndex()
+	res := ix.Search("ja", 0, 10)
+	assert.Equal(t, []string{"2"}, ids(res))
+	assert.Equal(t, 1, res.Total)
+}
+
+func TestSearchAllTokensMustMatch(t *testing.T) {
+	ix := newTestIndex()
+	assert.Equal(t, []string{"1"}, ids(ix.Search("doe lon", 0, 10)))
+	assert.Empty(t, ids(ix.Search("doe centauri", 0, 10)))
+}
+
+func TestSearchRanksBoostedAndExactMatchesFirst(t *testing.T) {
+	ix := newTestIndex()
+	// "londo" is an exact, boosted name match for 3 and only a prefix
+	// match on the city of 1.
+	assert.Equal(t, []string{"3", "1"}, ids(ix.Search("londo", 0, 10)))
+}
+
+func TestSearchHighlights(t *testing.T) {
+	ix := newTestIndex()
+	res := ix.Search("mil", 0, 10)
+	assert.Equal(t, map[string]string{"city": "<mark>Milton</mark> Keynes"}, res.Hits[0].Highlights)
+}
+
+func TestSearchHighlightsEscapeHTML(t *testing.T) {
+	ix := New()
+	ix.Index(Document{ID: "1", Fields: []Field{
+		{Name: "name", Text: `Bobby <img src=x onerror="alert(1)"> & Tables`},
+	}})
+	res := ix.Search("bobby", 0, 10)
+	assert.Equal(t, map[string]string{
+		"name": "<mark>Bobby</mark> &lt;img src=x onerror=&#34;alert(1)&#34;&gt; &amp; Tables",
+	}, res.Hits[0].Highlights)
+}
+
+func TestSearchPagination(t *testing.T) {
+	ix := newTestIndex()
+	res := ix.Search("doe", 1, 1)
+	assert.Equal(t, 2, res.Total)
+	assert.Len(t, res.Hits, 1)
+	assert.Empty(t, ix.Search("doe", 5, 10).Hits)
+}
+
+func TestIndexReplaceAndDelete(t *testing.T) {
+	ix := newTestIndex()
+	ix.Index(Document{ID: "1", Fields: []Field{{Name: "name", Text: "John Smith"}}})
+	assert.Equal(t, []string{"2"}, ids(ix.Search("doe", 0, 10)))
+	assert.Equal(t, []string{"1"}, ids(ix.Search("smith", 0, 10)))
+
+	ix.Delete("1")
+	assert.Empty(t, ids(ix.Search("smith", 0, 10)))
+	assert.Equal(t, 2, ix.Len())
+}
+
+func TestSearchEmptyQuery(t *testing.T) {
+	ix := newTestIndex()
+	res := ix.Search(" ,, ", 0, 10)
+	assert.Equal(t, 0, res.Total)
+	assert.NotNil(t, res.Hits)
+}
@@ -0,0 +1,111 @@
+package status
+
+import "net/http"
+
+//go:generate go run gen_openapi.go
+
+// Code is a stable, machine-readable error identifier. Clients should match
+// on the code rather than on the human-readable message, which may change.
+type Code string
+
+// Error codes returned by the API.
+const (
+	CodeInvalidUserID              Code = "INVALID_USER_ID"
+	CodeInvalidVisaID              Code = "INVALID_VISA_ID"
+	CodeInvalidFields              Code = "INVALID_FIELDS"
+	CodeInvalidQuery               Code = "INVALID_QUERY"
+	CodeInvalidInclude             Code = "INVALID_INCLUDE"
+	CodeInvalidIdempotencyKey      Code = "INVALID_IDEMPOTENCY_KEY"
+	CodeUnsupportedMediaType       Code = "UNSUPPORTED_MEDIA_TYPE"
+	CodeRequestTooLarge            Code = "REQUEST_TOO_LARGE"
+	CodeMalformedRequest           Code = "MALFORMED_REQUEST"
+	CodeMalformedUser              Code = "MALFORMED_USER"
+	CodeMalformedPassport          Code = "MALFORMED_PASSPORT"
+	CodeMalformedVisa              Code = "MALFORMED_VISA"
+	CodeValidationFailed           Code = "VALIDATION_FAILED"
+	CodeUserNotFound               Code = "USER_NOT_FOUND"
+	CodePassportNotFound           Code = "PASSPORT_NOT_FOUND"
+	CodePassportDuplicate          Code = "PASSPORT_DUPLICATE"
+	CodePassportActiveExists       Code = "PASSPORT_ACTIVE_EXISTS"
+	CodeInvalidTransition          Code = "INVALID_TRANSITION"
+	CodeVisaNotFound               Code = "VISA_NOT_FOUND"
+	CodeAttachmentNotFound         Code = "ATTACHMENT_NOT_FOUND"
+	CodeAttachmentTypeUnsupported  Code = "ATTACHMENT_TYPE_UNSUPPORTED"
+	CodeMRZInvalid                 Code = "MRZ_INVALID"
+	CodeMRZUnavailable             Code = "MRZ_UNAVAILABLE"
+	CodeIdempotencyKeyReused       Code = "IDEMPOTENCY_KEY_REUSED"
+	CodeIdempotencyKeyInUse        Code = "IDEMPOTENCY_KEY_IN_USE"
+	CodeBatchAborted               Code = "BATCH_ABORTED"
+	CodeNotTransactional           Code = "NOT_TRANSACTIONAL"
+	CodeOperationNotFound          Code = "OPERATION_NOT_FOUND"
+	CodeOperationFinished          Code = "OPERATION_FINISHED"
+	CodeOperationResultUnavailable Code = "OPERATION_RESULT_UNAVAILABLE"
+	CodeRateLimited                Code = "RATE_LIMITED"
+	CodeVerificationLimited        Code = "VERIFICATION_LIMITED"
+	CodeInternal                   Code = "INTERNAL_ERROR"
+	CodeTransactionsUnsupported    Code = "TRANSACTIONS_UNSUPPORTED"
+)
+
+// CodeInfo describes an error code in the catalog.
+type CodeInfo struct {
+	Code        Code   `json:"code"`
+	Status      int    `json:"status"`
+	Description string `json:"description"`
+}
+
+// catalog is the single source of truth for error codes. The ErrorCode schema
+// in api/openapi.yaml is generated from it.
+var catalog = []CodeInfo{
+	{CodeInvalidUserID, http.StatusBadRequest, "The user ID in the path is not a valid integer."},
+	{CodeInvalidVisaID, http.StatusBadRequest, "The visa ID in the path is not a valid integer."},
+	{CodeInvalidFields, http.StatusBadRequest, "The fields query parameter names a field that does not exist on the resource."},
+	{CodeInvalidQuery, http.StatusBadRequest, "A query parameter is missing, names an unknown field or operator, or has an invalid value."},
+	{CodeInvalidInclude, http.StatusBadRequest, "The include query parameter names a related resource that cannot be embedded."},
+	{CodeInvalidIdempotencyKey, http.StatusBadRequest, "The Idempotency-Key header is longer than 255 characters."},
+	{CodeUnsupportedMediaType, http.StatusUnsupportedMediaType, "The request body is not sent as application/json."},
+	{CodeRequestTooLarge, http.StatusRequestEntityTooLarge, "The request body is larger than the endpoint accepts."},
+	{CodeMalformedRequest, http.StatusBadRequest, "The request body could not be read, or is not valid JSON for the request; the message gives the line and column."},
+	{CodeMalformedUser, http.StatusBadRequest, "The request body is not valid JSON for a user; the message gives the line and column, and the field where known."},
+	{CodeMalformedPassport, http.StatusBadRequest, "The request body is not valid JSON for a passport; the message gives the line and column, and the field where known."},
+	{CodeMalformedVisa, http.StatusBadRequest, "The request body is not valid JSON for a visa; the message gives the line and column, and the field where known."},
+	{CodeValidationFailed, http.StatusUnprocessableEntity, "The request body failed validation; see errors for details."},
+	{CodeUserNotFound, http.StatusNotFound, "No user exists with the given ID."},
+	{CodePassportNotFound, http.StatusNotFound, "No passport exists with the given ID."},
+	{CodePassportDuplicate, http.StatusConflict, "A passport with the given ID already exists."},
+	{CodePassportActiveExists, http.StatusConflict, "The user already has an unexpired passport from the same issuing state; the message names it."},
+	{CodeInvalidTransition, http.StatusConflict, "The passport's current status can't change to the requested one."},
+	{CodeVisaNotFound, http.StatusNotFound, "No visa exists with the given ID in the given passport."},
+	{CodeAttachmentNotFound, http.StatusNotFound, "No attachment exists with the given ID on the given passport."},
+	{CodeAttachmentTypeUnsupported, http.StatusUnsupportedMediaType, "The uploaded file is not a JPEG, PNG or PDF document, judging by its content."},
+	{CodeMRZInvalid, http.StatusUnprocessableEntity, "The machine readable zone is not a valid TD3 MRZ, or a check digit doesn't match; the message says where."},
+	{CodeMRZUnavailable, http.StatusUnprocessableEntity, "The passport can't be written as a machine readable zone, for example because the issuing state of its authority isn't known."},
+	{CodeIdempotencyKeyReused, http.StatusUnprocessableEntity, "The Idempotency-Key was already used for a request with a different method, path or body."},
+	{CodeIdempotencyKeyInUse, http.StatusConflict, "A request with the same Idempotency-Key is still being processed; retry later."},
+	{CodeBatchAborted, http.StatusFailedDependency, "An earlier operation in an atomic batch failed, so this operation was not run."},
+	{CodeNotTransactional, http.StatusUnprocessableEntity, "The operation can't be rolled back, so it can't run in an atomic batch: attachment uploads and deletions, and starting or cancelling exports and imports."},
+	{CodeOperationNotFound, http.StatusNotFound, "No operation exists with the given ID, or it finished more than 24 hours ago."},
+	{CodeOperationFinished, http.StatusConflict, "The operation has already finished, so it can no longer be cancelled."},
+	{CodeOperationResultUnavailable, http.StatusConflict, "The operation has no result because it is still running, was cancelled or failed."},
+	{CodeRateLimited, http.StatusTooManyRequests, "The client has exceeded the rate limit."},
+	{CodeVerificationLimited, http.StatusTooManyRequests, "Too many verifications of this passport by the client failed recently; Retry-After says when to try again."},
+	{CodeInternal, http.StatusInternalServerError, "An unexpected error occurred on the server."},
+	{CodeTransactionsUnsupported, http.StatusNotImplemented, "The storage backend does not support transactions, so atomic batches, renewals and merges are unavailable."},
+}
+
+// Catalog returns all registered error codes in a stable order.
+func Catalog() []CodeInfo {
+	out := make([]CodeInfo, len(catalog))
+	copy(out, catalog)
+	return out
+}
+
+// HTTPStatus returns the HTTP status code associated with c, or 500 if c is
+// not registered.
+func (c Code) HTTPStatus() int {
+	for _, info := range catalog {
+		if info.Code == c {
+			return info.Status
+		}
+	}
+	return http.StatusInternalServerError
+}
@@ -0,0 +1,61 @@
+package status
+
+import (
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCatalogCodesUnique(t *testing.T) {
+	seen := make(map[Code]bool)
+	for _, info := range Catalog() {
+		assert.False(t, seen[info.Code], "duplicate code %s", info.Code)
+		seen[info.Code] = true
+		assert.NotEmpty(t, info.Description, "code %s has no description", info.Code)
+	}
+}
+
+func TestHTTPStatus(t *testing.T) {
+	assert.Equal(t, http.StatusNotFound, CodeUserNotFound.HTTPStatus())
+	assert.Equal(t, http.StatusTooManyRequests, CodeRateLimited.HTTPStatus())
+	assert.Equal(t, http.StatusInternalServerError, Code("UNKNOWN").HTTPStatus())
+}
+
+func TestNew(t *testing.T) {
+	resp := New(CodePassportDuplicate, "passport exists")
+	assert.Equal(t, "409", resp.Status)
+	assert.Equal(t, CodePassportDuplicate, resp.Code)
+	assert.Equal(t, "passport exists", resp.Message)
+}
+
+// TestOpenAPIErrorCodesInSync fails when api/openapi.yaml has not been
+// regenerated after changing the catalog. Fix it with `go generate ./pkg/status`.
+func TestOpenAPIErrorCodesInSync(t *testing.T) {
+	dat, err := os.ReadFile("../../api/openapi.yaml")
+	require.NoError(t, err)
+	spec := string(dat)
+
+	start := strings.Index(spec, "# BEGIN GENERATED ErrorCode")
+	end := strings.Index(spec, "# END GENERATED ErrorCode")
+	require.True(t, start >= 0 && end > start, "ErrorCode markers missing from spec")
+	block := spec[start:end]
+	enum := block[strings.Index(block, "enum:"):]
+
+	var documented []string
+	for _, line := range strings.Split(enum, "\n") {
+		line = strings.TrimSpace(line)
+		if code, ok := strings.CutPrefix(line, "- "); ok {
+			documented = append(documented, code)
+		}
+	}
+	var expected []string
+	for _, info := range Catalog() {
+		expected = append(expected, string(info.Code))
+		assert.Contains(t, block, info.Description, "description for %s is stale; run go generate ./pkg/status", info.Code)
+	}
+	assert.Equal(t, expected, documented, "ErrorCode enum is stale; run go generate ./pkg/status")
+}
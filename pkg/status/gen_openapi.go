@@ -0,0 +1,54 @@
+//go:build ignore
+
+// gen_openapi regenerates the ErrorCode schema in api/openapi.yaml from the
+// status code catalog. Run it with `go generate ./pkg/status`.
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/leeprovoost/go-rest-api-template/pkg/status"
+)
+
+const (
+	specPath    = "../../api/openapi.yaml"
+	beginMarker = "    # BEGIN GENERATED ErrorCode"
+	endMarker   = "    # END GENERATED ErrorCode"
+)
+
+func main() {
+	dat, err := os.ReadFile(specPath)
+	if err != nil {
+		log.Fatalf("reading spec: %v", err)
+	}
+	spec := string(dat)
+	start := strings.Index(spec, beginMarker)
+	end := strings.Index(spec, endMarker)
+	if start < 0 || end < start {
+		log.Fatalf("markers %q and %q not found in %s", beginMarker, endMarker, specPath)
+	}
+
+	var b strings.Builder
+	b.WriteString(beginMarker + " (generated by pkg/status/gen_openapi.go; DO NOT EDIT)\n")
+	b.WriteString("    ErrorCode:\n")
+	b.WriteString("      type: string\n")
+	b.WriteString("      description: |\n")
+	b.WriteString("        Stable machine-readable error code.\n\n")
+	b.WriteString("        | Code | HTTP status | Description |\n")
+	b.WriteString("        |------|-------------|-------------|\n")
+	for _, info := range status.Catalog() {
+		fmt.Fprintf(&b, "        | `%s` | %d | %s |\n", info.Code, info.Status, info.Description)
+	}
+	b.WriteString("      enum:\n")
+	for _, info := range status.Catalog() {
+		fmt.Fprintf(&b, "        - %s\n", info.Code)
+	}
+
+	out := spec[:start] + b.String() + spec[end:]
+	if err := os.WriteFile(specPath, []byte(out), 0644); err != nil {
+		log.Fatalf("writing spec: %v", err)
+	}
+}
@@ -1,9 +1,29 @@
 package status
 
+import (
+	"strconv"
+
+	"github.com/leeprovoost/go-rest-api-template/pkg/validate"
+)
+
 // Response is a custom error response sent back to the client.
-// It avoids leaking internal error details.
+// It avoids leaking internal error details. Validation failures list their
+// messages in Errors and the same failures, with the JSON pointer and rule of
+// each, in Fields.
 type Response struct {
-	Status  string   `json:"status"`
-	Message string   `json:"message"`
-	Errors  []string `json:"errors,omitempty"`
+	Status  string           `json:"status"`
+	Code    Code             `json:"code,omitempty"`
+	Message string           `json:"message"`
+	Errors  []string         `json:"errors,omitempty"`
+	Fields  []validate.Error `json:"fields,omitempty"`
+}
+
+// New returns a Response for the given error code, with the HTTP status
+// taken from the catalog.
+func New(code Code, message string) Response {
+	return Response{
+		Status:  strconv.Itoa(code.HTTPStatus()),
+		Code:    code,
+		Message: message,
+	}
 }
@@ -0,0 +1,442 @@
+// Package validate checks structs against rules declared in `validate`
+// struct tags, for example:
+//
+//	type User struct {
+//		FirstName   string    `json:"firstName" validate:"required,max=100"`
+//		DateOfBirth civil.Date `json:"dateOfBirth" validate:"required,past"`
+//	}
+//
+// Rules are separated by commas and checked in order:
+//
+//	required        the value must not be the zero value (or empty, for slices and maps)
+//	min=N, max=N    length of strings (in characters), slices and maps; value of numbers
+//	pattern=RE      strings must match the regular expression, which may not contain commas
+//	oneof=A B C     strings must be one of the space-separated values
+//	past, future    times and dates must be before or after now
+//	before=F        times must be before the time in sibling field F (the Go field name)
+//	after=F         times must be after the time in sibling field F
+//
+// Times are time.Time or civil.Date values; a date is taken as the start of
+// its day in UTC, so today's date is in the past.
+//
+// Rules other than required are skipped for zero values, so optional fields
+// only need to be valid when they are set. Nested structs, and slices and
+// pointers of them, are validated too, and the fields of exported embedded
+// structs are validated as the outer struct's own, as encoding/json treats
+// them. Rules that don't fit in a tag can be written as a Validate method;
+// see Validator.
+//
+// Failures are reported as Errors, each pointing at the offending field with
+// a JSON pointer (RFC 6901) built from the json tags.
+package validate
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/leeprovoost/go-rest-api-template/pkg/civil"
+)
+
+// Error is a failed rule.
+type Error struct {
+	// Pointer is the JSON pointer of the field, e.g. /users/0/lastName.
+	Pointer string `json:"pointer"`
+	// Rule is the name of the rule that failed, e.g. required.
+	Rule string `json:"rule"`
+	// Message describes the failure, starting with the field's path,
+	// e.g. "users[0].lastName is required".
+	Message string `json:"message"`
+}
+
+func (e Error) Error() string {
+	return e.Message
+}
+
+// Path returns the field's path in the dotted form used in messages, e.g.
+// users[0].lastName.
+func (e Error) Path() string {
+	return pathOf(e.Pointer)
+}
+
+// Errors is the list of rules a value failed, in field order.
+type Errors []Error
+
+func (errs Errors) Error() string {
+	return strings.Join(errs.Messages(), "; ")
+}
+
+// Messages returns the message of each error.
+func (errs Errors) Messages() []string {
+	msgs := make([]string, len(errs))
+	for i, e := range errs {
+		msgs[i] = e.Message
+	}
+	return msgs
+}
+
+// Validator is implemented by types with rules that struct tags can't
+// express, such as rules that depend on several fields or on lookup tables.
+// Validate is called after the tag rules of the type's fields have passed or
+// failed, and reports failures through s.
+type Validator interface {
+	Validate(s *Scope)
+}
+
+// Scope collects the errors of one struct at one position in the document.
+type Scope struct {
+	pointer string
+	errs    *Errors
+}
+
+// Fail records that the field with the given JSON name failed rule. The
+// message is appended to the field's path, e.g. Fail("dateOfExpiry",
+// "after", "must be after dateOfIssue"). An empty field means the struct
+// itself.
+func (s *Scope) Fail(field, rule, message string) {
+	pointer := s.pointer
+	if field != "" {
+		pointer += "/" + escape(field)
+	}
+	s.fail(pointer, rule, message)
+}
+
+func (s *Scope) fail(pointer, rule, message string) {
+	path := pathOf(pointer)
+	if path == "" {
+		path = "value"
+	}
+	*s.errs = append(*s.errs, Error{Pointer: pointer, Rule: rule, Message: path + " " + message})
+}
+
+// Struct validates v, which must be a struct or a pointer to one, and
+// returns nil if it is valid. It panics if a tag names an unknown rule, as
+// that is a programming error.
+func Struct(v any) Errors {
+	var errs Errors
+	walk(reflect.ValueOf(v), &Scope{errs: &errs})
+	return errs
+}
+
+// walk validates the structs in v, at the position of s.
+func walk(v reflect.Value, s *Scope) {
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if !v.IsNil() {
+			walk(v.Elem(), s)
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			walk(v.Index(i), &Scope{pointer: s.pointer + "/" + strconv.Itoa(i), errs: s.errs})
+		}
+	case reflect.Struct:
+		if v.Type() == timeType {
+			return
+		}
+		walkFields(v, s)
+		if val, ok := validatorOf(v); ok {
+			val.Validate(s)
+		}
+	}
+}
+
+// walkFields checks the tag rules of the fields of struct v and walks into
+// them. The fields of embedded structs are checked as if they were v's own;
+// their Validate method, if any, is promoted to v and so runs once, for v.
+func walkFields(v reflect.Value, s *Scope) {
+	for _, f := range fieldsOf(v.Type()) {
+		fv := v.FieldByIndex(f.index)
+		if f.embedded {
+			walkFields(fv, s)
+			continue
+		}
+		fs := &Scope{pointer: s.pointer + "/" + escape(f.name), errs: s.errs}
+		for _, r := range f.rules {
+			if !r.check(v, fv, fs) {
+				break
+			}
+		}
+		walk(fv, fs)
+	}
+}
+
+// validatorOf returns v as a Validator, if it or a pointer to it is one.
+func validatorOf(v reflect.Value) (Validator, bool) {
+	if val, ok := v.Interface().(Validator); ok {
+		return val, true
+	}
+	if v.CanAddr() {
+		val, ok := v.Addr().Interface().(Validator)
+		return val, ok
+	}
+	p := reflect.New(v.Type())
+	p.Elem().Set(v)
+	val, ok := p.Interface().(Validator)
+	return val, ok
+}
+
+var (
+	timeType = reflect.TypeOf(time.Time{})
+	dateType = reflect.TypeOf(civil.Date{})
+)
+
+// isTime reports whether the past, future, before and after rules apply to
+// fields of type t.
+func isTime(t reflect.Type) bool {
+	return t == timeType || t == dateType
+}
+
+// timeOf returns the time.Time or civil.Date in v as a time.Time.
+func timeOf(v reflect.Value) time.Time {
+	if d, ok := v.Interface().(civil.Date); ok {
+		return d.In(time.UTC)
+	}
+	return v.Interface().(time.Time)
+}
+
+// now is the clock for the past and future rules.
+var now = time.Now
+
+// --- Struct metadata ---
+
+// field is a struct field with its rules.
+type field struct {
+	index    []int
+	name     string // JSON name
+	embedded bool   // an embedded struct whose fields are promoted
+	rules    []rule
+}
+
+var fieldCache sync.Map // reflect.Type -> []field
+
+// fieldsOf returns the validated fields of struct type t.
+func fieldsOf(t reflect.Type) []field {
+	if fields, ok := fieldCache.Load(t); ok {
+		return fields.([]field)
+	}
+	var fields []field
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if !sf.IsExported() {
+			continue
+		}
+		name, _, _ := strings.Cut(sf.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if sf.Anonymous && name == "" && sf.Type.Kind() == reflect.Struct {
+			fields = append(fields, field{index: sf.Index, embedded: true})
+			continue
+		}
+		if name == "" {
+			name = sf.Name
+		}
+		fields = append(fields, field{index: sf.Index, name: name, rules: parseRules(t, sf)})
+	}
+	fieldCache.Store(t, fields)
+	return fields
+}
+
+// jsonName returns the JSON name of the field of t with Go name goName.
+func jsonName(t reflect.Type, goName string) string {
+	sf, _ := t.FieldByName(goName)
+	if name, _, _ := strings.Cut(sf.Tag.Get("json"), ","); name != "" && name != "-" {
+		return name
+	}
+	return goName
+}
+
+// --- Rules ---
+
+// rule checks one constraint on a field. parent is the struct holding the
+// field, for cross-field rules. check returns false to stop checking the
+// field's remaining rules.
+type rule struct {
+	name  string
+	check func(parent, v reflect.Value, s *Scope) bool
+}
+
+func parseRules(t reflect.Type, sf reflect.StructField) []rule {
+	tag := sf.Tag.Get("validate")
+	if tag == "" {
+		return nil
+	}
+	var rules []rule
+	for _, part := range strings.Split(tag, ",") {
+		name, arg, _ := strings.Cut(strings.TrimSpace(part), "=")
+		r, err := newRule(t, sf, name, arg)
+		if err != nil {
+			panic(fmt.Sprintf("validate: %s.%s: %v", t.Name(), sf.Name, err))
+		}
+		rules = append(rules, r)
+	}
+	return rules
+}
+
+func newRule(t reflect.Type, sf reflect.StructField, name, arg string) (rule, error) {
+	r := rule{name: name}
+	switch name {
+	case "required":
+		r.check = func(_, v reflect.Value, s *Scope) bool {
+			if isZero(v) {
+				s.fail(s.pointer, name, "is required")
+				return false
+			}
+			return true
+		}
+	case "min", "max":
+		limit, err := strconv.ParseFloat(arg, 64)
+		if err != nil {
+			return r, fmt.Errorf("%s needs a number, got %q", name, arg)
+		}
+		r.check = skipZero(func(_, v reflect.Value, s *Scope) bool {
+			n, unit := measure(v)
+			if (name == "min" && n < limit) || (name == "max" && n > limit) {
+				bound := "at least"
+				if name == "max" {
+					bound = "at most"
+				}
+				s.fail(s.pointer, name, fmt.Sprintf("must %s %s %s%s", unitVerb(unit), bound, arg, unit))
+			}
+			return true
+		})
+	case "pattern":
+		re, err := regexp.Compile(arg)
+		if err != nil {
+			return r, fmt.Errorf("pattern: %w", err)
+		}
+		r.check = skipZero(func(_, v reflect.Value, s *Scope) bool {
+			if !re.MatchString(v.String()) {
+				s.fail(s.pointer, name, "has an invalid format")
+			}
+			return true
+		})
+	case "oneof":
+		allowed := strings.Fields(arg)
+		if len(allowed) == 0 {
+			return r, fmt.Errorf("oneof needs at least one value")
+		}
+		r.check = skipZero(func(_, v reflect.Value, s *Scope) bool {
+			got := fmt.Sprint(v.Interface())
+			for _, a := range allowed {
+				if got == a {
+					return true
+				}
+			}
+			s.fail(s.pointer, name, "must be one of "+strings.Join(allowed, ", "))
+			return true
+		})
+	case "past", "future":
+		if !isTime(sf.Type) {
+			return r, fmt.Errorf("%s only applies to time.Time and civil.Date", name)
+		}
+		r.check = skipZero(func(_, v reflect.Value, s *Scope) bool {
+			tv := timeOf(v)
+			if name == "past" && !tv.Before(now()) {
+				s.fail(s.pointer, name, "must be in the past")
+			}
+			if name == "future" && !tv.After(now()) {
+				s.fail(s.pointer, name, "must be in the future")
+			}
+			return true
+		})
+	case "before", "after":
+		other, ok := t.FieldByName(arg)
+		if !ok || !isTime(sf.Type) || other.Type != sf.Type {
+			return r, fmt.Errorf("%s needs a sibling field of the same time type, got %q", name, arg)
+		}
+		otherName := jsonName(t, arg)
+		r.check = skipZero(func(parent, v reflect.Value, s *Scope) bool {
+			tv := timeOf(v)
+			o := timeOf(parent.FieldByIndex(other.Index))
+			if o.IsZero() {
+				return true
+			}
+			if (name == "before" && !tv.Before(o)) || (name == "after" && !tv.After(o)) {
+				s.fail(s.pointer, name, "must be "+name+" "+otherName)
+			}
+			return true
+		})
+	default:
+		return r, fmt.Errorf("unknown rule %q", name)
+	}
+	return r, nil
+}
+
+// skipZero wraps a check so that it passes zero values, leaving them to
+// the required rule.
+func skipZero(check func(parent, v reflect.Value, s *Scope) bool) func(parent, v reflect.Value, s *Scope) bool {
+	return func(parent, v reflect.Value, s *Scope) bool {
+		if isZero(v) {
+			return true
+		}
+		return check(parent, v, s)
+	}
+}
+
+func isZero(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Slice, reflect.Map:
+		return v.Len() == 0
+	default:
+		return v.IsZero()
+	}
+}
+
+// measure returns the size of v that min and max compare, and its unit.
+func measure(v reflect.Value) (float64, string) {
+	switch v.Kind() {
+	case reflect.String:
+		return float64(utf8.RuneCountInString(v.String())), " characters"
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return float64(v.Len()), " items"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), ""
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(v.Uint()), ""
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), ""
+	default:
+		panic("validate: min and max don't apply to " + v.Type().String())
+	}
+}
+
+func unitVerb(unit string) string {
+	if unit == " items" {
+		return "contain"
+	}
+	return "be"
+}
+
+// --- Paths ---
+
+// escape escapes a JSON pointer reference token.
+func escape(token string) string {
+	return strings.NewReplacer("~", "~0", "/", "~1").Replace(token)
+}
+
+// pathOf converts a JSON pointer to the dotted form, e.g. /users/0/lastName
+// to users[0].lastName.
+func pathOf(pointer string) string {
+	if pointer == "" {
+		return ""
+	}
+	var b strings.Builder
+	for _, token := range strings.Split(pointer[1:], "/") {
+		token = strings.NewReplacer("~1", "/", "~0", "~").Replace(token)
+		if _, err := strconv.Atoi(token); err == nil {
+			b.WriteString("[" + token + "]")
+			continue
+		}
+		if b.Len() > 0 {
+			b.WriteByte('.')
+		}
+		b.WriteString(token)
+	}
+	return b.String()
+}
@@ -0,0 +1,145 @@
+package validate
+
+import (
+	"testing"
+	"time"
+
+	"github.com/leeprovoost/go-rest-api-template/pkg/civil"
+	"github.com/stretchr/testify/assert"
+)
+
+func date(s string) time.Time {
+	t, _ := time.Parse(time.DateOnly, s)
+	return t
+}
+
+type document struct {
+	ID      string    `json:"id" validate:"required,pattern=^[0-9]{9}$"`
+	Kind    string    `json:"kind" validate:"oneof=passport visa"`
+	Issued  time.Time `json:"issued" validate:"required,past"`
+	Expires time.Time `json:"expires" validate:"required,after=Issued"`
+	Pages   int       `json:"pages" validate:"min=16,max=64"`
+	Notes   string    `json:"notes,omitempty" validate:"max=5"`
+}
+
+// Named is embedded in person, so its fields are validated as person's own.
+type Named struct {
+	Name string `json:"name" validate:"required"`
+}
+
+type person struct {
+	Named
+	Tags      []string   `json:"tags" validate:"max=2"`
+	Documents []document `json:"documents" validate:"required"`
+	Primary   *document  `json:"primary"`
+	Ignored   string     `json:"-" validate:"required"`
+	Backup    any        `json:"backup"`
+}
+
+// Validate reports a person with more than one document of the same kind.
+func (p person) Validate(s *Scope) {
+	seen := map[string]bool{}
+	for _, d := range p.Documents {
+		if d.Kind != "" && seen[d.Kind] {
+			s.Fail("documents", "unique", "must not contain two documents of kind "+d.Kind)
+		}
+		seen[d.Kind] = true
+	}
+}
+
+func TestStructValid(t *testing.T) {
+	p := person{
+		Named:     Named{Name: "Jane"},
+		Documents: []document{{ID: "012345678", Kind: "passport", Issued: date("2020-01-01"), Expires: date("2030-01-01")}},
+	}
+	assert.Nil(t, Struct(p))
+	assert.Nil(t, Struct(&p))
+}
+
+func TestStructRules(t *testing.T) {
+	errs := Struct(document{
+		ID:      "12-34",
+		Kind:    "licence",
+		Issued:  time.Now().Add(time.Hour),
+		Expires: time.Now(),
+		Pages:   100,
+		Notes:   "far too long",
+	})
+	assert.Equal(t, Errors{
+		{Pointer: "/id", Rule: "pattern", Message: "id has an invalid format"},
+		{Pointer: "/kind", Rule: "oneof", Message: "kind must be one of passport, visa"},
+		{Pointer: "/issued", Rule: "past", Message: "issued must be in the past"},
+		{Pointer: "/expires", Rule: "after", Message: "expires must be after issued"},
+		{Pointer: "/pages", Rule: "max", Message: "pages must be at most 64"},
+		{Pointer: "/notes", Rule: "max", Message: "notes must be at most 5 characters"},
+	}, errs)
+}
+
+func TestStructCivilDates(t *testing.T) {
+	type stay struct {
+		Arrival   civil.Date `json:"arrival" validate:"required,past"`
+		Departure civil.Date `json:"departure" validate:"after=Arrival"`
+	}
+	today := civil.DateOf(time.Now().UTC())
+	assert.Nil(t, Struct(stay{Arrival: today}))
+	assert.Nil(t, Struct(stay{Arrival: today.AddDate(0, 0, -1), Departure: today}))
+	assert.Equal(t, []string{
+		"arrival must be in the past",
+		"departure must be after arrival",
+	}, Struct(stay{Arrival: today.AddDate(0, 0, 1), Departure: today}).Messages())
+	assert.Equal(t, []string{"arrival is required"}, Struct(stay{}).Messages())
+}
+
+func TestStructRequiredSkipsOtherRules(t *testing.T) {
+	errs := Struct(document{})
+	assert.Equal(t, []string{"id is required", "issued is required", "expires is required"}, errs.Messages())
+}
+
+func TestStructNested(t *testing.T) {
+	errs := Struct(&person{
+		Tags: []string{"a", "b", "c"},
+		Documents: []document{
+			{ID: "012345678", Kind: "visa", Issued: date("2020-01-01"), Expires: date("2030-01-01")},
+			{ID: "012345679", Kind: "visa", Issued: date("2020-01-01"), Expires: date("2019-01-01")},
+		},
+		Primary: &document{Kind: "visa", Issued: date("2020-01-01"), Expires: date("2030-01-01")},
+		Backup:  &document{ID: "x", Issued: date("2020-01-01"), Expires: date("2030-01-01")},
+	})
+	assert.Equal(t, Errors{
+		{Pointer: "/name", Rule: "required", Message: "name is required"},
+		{Pointer: "/tags", Rule: "max", Message: "tags must contain at most 2 items"},
+		{Pointer: "/documents/1/expires", Rule: "after", Message: "documents[1].expires must be after issued"},
+		{Pointer: "/primary/id", Rule: "required", Message: "primary.id is required"},
+		{Pointer: "/backup/id", Rule: "pattern", Message: "backup.id has an invalid format"},
+		{Pointer: "/documents", Rule: "unique", Message: "documents must not contain two documents of kind visa"},
+	}, errs)
+	assert.Equal(t, "documents[1].expires", errs[2].Path())
+}
+
+func TestStructEmptySlice(t *testing.T) {
+	errs := Struct(person{Named: Named{Name: "Jane"}, Documents: []document{}})
+	assert.Equal(t, []string{"documents is required"}, errs.Messages())
+}
+
+func TestScopeFailStruct(t *testing.T) {
+	var errs Errors
+	(&Scope{errs: &errs}).Fail("", "custom", "is not allowed")
+	assert.Equal(t, Errors{{Pointer: "", Rule: "custom", Message: "value is not allowed"}}, errs)
+	assert.Equal(t, "value is not allowed", errs.Error())
+}
+
+func TestPointerEscaping(t *testing.T) {
+	type odd struct {
+		Value string `json:"a/b~c" validate:"required"`
+	}
+	errs := Struct(odd{})
+	assert.Equal(t, "/a~1b~0c", errs[0].Pointer)
+	assert.Equal(t, "a/b~c", errs[0].Path())
+}
+
+func TestUnknownRulePanics(t *testing.T) {
+	type bad struct {
+		Value string `json:"value" validate:"shiny"`
+	}
+	assert.PanicsWithValue(t, `validate: bad.Value: unknown rule "shiny"`, func() { Struct(bad{}) })
+}